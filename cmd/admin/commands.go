@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/service/ingest"
+	"rag-test/internal/service/snapshot"
+)
+
+// previewRunes is how much of a chunk text is printed without -full.
+const previewRunes = 200
+
+// errEnough stops a scan once -limit chunks were printed.
+var errEnough = errors.New("enough chunks")
+
+type collectionView struct {
+	Name            string         `json:"name"`
+	Alias           string         `json:"alias,omitempty"`
+	Dim             int            `json:"dim"`
+	MaxPayloadBytes int            `json:"max_payload_bytes"`
+	Metric          string         `json:"metric"`
+	Index           string         `json:"index"`
+	IndexParams     map[string]int `json:"index_params,omitempty"`
+	Count           int64          `json:"count"`
+}
+
+type sourceView struct {
+	DataSource string `json:"data_source"`
+	Chunks     int    `json:"chunks"`
+}
+
+type chunkView struct {
+	ID         int64                    `json:"id"`
+	DataSource string                   `json:"data_source"`
+	Payload    string                   `json:"payload"`
+	Metadata   milvusrepo.ChunkMetadata `json:"metadata"`
+}
+
+type resultView struct {
+	Command    string `json:"command"`
+	Collection string `json:"collection"`
+	DataSource string `json:"data_source,omitempty"`
+	Chunks     int    `json:"chunks,omitempty"`
+}
+
+func (a *admin) collections(ctx context.Context) error {
+	names, err := a.repo.ListCollections(ctx)
+	if err != nil {
+		return err
+	}
+
+	views := make([]collectionView, 0, len(names))
+	for _, name := range names {
+		info, err := a.repo.DescribeCollection(ctx, name)
+		if err != nil {
+			return err
+		}
+		views = append(views, a.view(info))
+	}
+	if jsonOutput {
+		return printJSON(views)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tALIAS\tDIM\tMETRIC\tINDEX\tCOUNT")
+	for _, v := range views {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%d\n", v.Name, v.Alias, v.Dim, v.Metric, v.Index, v.Count)
+	}
+	return w.Flush()
+}
+
+func (a *admin) describe(ctx context.Context) error {
+	info, err := a.repo.DescribeCollection(ctx, a.collection)
+	if err != nil {
+		return err
+	}
+	v := a.view(info)
+	if jsonOutput {
+		return printJSON(v)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "name\t%s\n", v.Name)
+	if v.Alias != "" {
+		fmt.Fprintf(w, "alias\t%s\n", v.Alias)
+	}
+	fmt.Fprintf(w, "dim\t%d\n", v.Dim)
+	fmt.Fprintf(w, "payload max_length\t%d bytes\n", v.MaxPayloadBytes)
+	fmt.Fprintf(w, "metric\t%s\n", v.Metric)
+	fmt.Fprintf(w, "index\t%s\n", v.Index)
+	for _, key := range slices.Sorted(maps.Keys(v.IndexParams)) {
+		fmt.Fprintf(w, "  %s\t%d\n", key, v.IndexParams[key])
+	}
+	fmt.Fprintf(w, "count\t%d\n", v.Count)
+	return w.Flush()
+}
+
+func (a *admin) view(info milvusrepo.CollectionInfo) collectionView {
+	cfg := info.Config
+	v := collectionView{
+		Name:            info.Name,
+		Dim:             cfg.Dim,
+		MaxPayloadBytes: cfg.MaxPayloadBytes,
+		Metric:          string(cfg.Metric),
+		Index:           string(cfg.Index),
+		Count:           info.Count,
+	}
+	if info.Name == a.collection {
+		v.Alias = a.alias
+	}
+
+	switch cfg.Index {
+	case milvusrepo.IndexIVFFlat, milvusrepo.IndexIVFSQ8:
+		v.IndexParams = map[string]int{"nlist": cfg.NList, "nprobe": cfg.NProbe}
+	case milvusrepo.IndexHNSW:
+		v.IndexParams = map[string]int{"M": cfg.M, "efConstruction": cfg.EfConstruction, "ef": cfg.Ef}
+	}
+	return v
+}
+
+func (a *admin) sources(ctx context.Context) error {
+	counts := make(map[string]int)
+	req := milvusrepo.ScanRequest{OutputFields: []milvusrepo.Field{milvusrepo.FieldDataSource}}
+	err := a.repo.Scan(ctx, a.collection, req, func(items []milvusrepo.VectorItem) error {
+		for _, item := range items {
+			counts[item.DataSource]++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	views := make([]sourceView, 0, len(counts))
+	for dataSource, chunks := range counts {
+		views = append(views, sourceView{DataSource: dataSource, Chunks: chunks})
+	}
+	sort.Slice(views, func(i, j int) bool {
+		if views[i].Chunks != views[j].Chunks {
+			return views[i].Chunks > views[j].Chunks
+		}
+		return views[i].DataSource < views[j].DataSource
+	})
+	if jsonOutput {
+		return printJSON(views)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CHUNKS\tDATA SOURCE")
+	for _, v := range views {
+		fmt.Fprintf(w, "%d\t%s\n", v.Chunks, v.DataSource)
+	}
+	return w.Flush()
+}
+
+// chunks prints stored chunks in ID order. -source is applied by the store,
+// -grep to the full texts it returns.
+func (a *admin) chunks(ctx context.Context) error {
+	var pattern *regexp.Regexp
+	if grep != "" {
+		var err error
+		if pattern, err = regexp.Compile(grep); err != nil {
+			return fmt.Errorf("parse -grep: %w", err)
+		}
+	}
+	var req milvusrepo.ScanRequest
+	if source != "" {
+		req.Filter = milvusrepo.Eq(milvusrepo.FieldDataSource, source)
+	}
+
+	views := make([]chunkView, 0)
+	err := a.repo.Scan(ctx, a.collection, req, func(items []milvusrepo.VectorItem) error {
+		for _, item := range items {
+			if pattern != nil && !pattern.MatchString(item.Payload) {
+				continue
+			}
+			views = append(views, chunkView{ID: item.ID, DataSource: item.DataSource, Payload: item.Payload, Metadata: item.Metadata})
+			if limit > 0 && len(views) >= limit {
+				return errEnough
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errEnough) {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(views)
+	}
+
+	for _, v := range views {
+		location := v.Metadata.Title
+		if len(v.Metadata.HeadingPath) > 0 {
+			location = strings.Join(v.Metadata.HeadingPath, " > ")
+		}
+		fmt.Printf("#%d  %s  %s\n", v.ID, v.DataSource, location)
+		text := v.Payload
+		if !fullText {
+			text = preview(text)
+		}
+		fmt.Printf("    %s\n\n", text)
+	}
+	fmt.Printf("%d chunks\n", len(views))
+	return nil
+}
+
+// deleteSource removes a document the way ingestion removes a deleted file,
+// so chunks it shares with other documents stay. A source the manifest does
+// not know, e.g. one left over from a crashed run, is deleted directly.
+func (a *admin) deleteSource(ctx context.Context, dataSource string) error {
+	err := ingest.NewRemover(a.repo, a.manifest, a.texts, a.collection).RemoveSource(ctx, dataSource)
+	if err == nil {
+		return a.report(resultView{Command: "delete-source", Collection: a.collection, DataSource: dataSource})
+	}
+	if !errors.Is(err, ingest.ErrUnknownSource) {
+		return err
+	}
+
+	deleted := 0
+	for _, collection := range a.withQuestions(ctx) {
+		req := milvusrepo.ScanRequest{
+			Filter:       milvusrepo.Eq(milvusrepo.FieldDataSource, dataSource),
+			OutputFields: []milvusrepo.Field{milvusrepo.FieldDataSource},
+		}
+		err := a.repo.Scan(ctx, collection, req, func(items []milvusrepo.VectorItem) error {
+			// The filter also matches chunks shared with other documents,
+			// which have to stay.
+			ids := make([]int64, 0, len(items))
+			for _, item := range items {
+				if item.DataSource == dataSource {
+					ids = append(ids, item.ID)
+				}
+			}
+			if err := a.repo.Delete(ctx, collection, ids); err != nil {
+				return err
+			}
+			deleted += len(ids)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if deleted == 0 {
+		return fmt.Errorf("collection %s has no chunks of %s", a.collection, dataSource)
+	}
+	return a.report(resultView{Command: "delete-source", Collection: a.collection, DataSource: dataSource, Chunks: deleted})
+}
+
+// drop deletes the collection with its questions collection, local stores
+// and manifest, so the next ingestion starts from scratch. A version of an
+// alias is dropped through the reindex service, which refuses the active
+// one and takes it out of the alias history.
+func (a *admin) drop(ctx context.Context) error {
+	if !confirmed {
+		return fmt.Errorf("dropping %s deletes all its chunks, rerun with -yes", a.collection)
+	}
+
+	alias, versioned := a.alias, a.alias != ""
+	if a.versions != nil && !versioned {
+		var err error
+		if alias, versioned, err = a.versions.Owner(a.collection); err != nil {
+			return err
+		}
+	}
+	if versioned {
+		if err := a.versions.Drop(ctx, alias, a.collection); err != nil {
+			return err
+		}
+		return a.report(resultView{Command: "drop", Collection: a.collection})
+	}
+
+	for _, collection := range a.withQuestions(ctx) {
+		if err := a.repo.DropCollection(ctx, collection); err != nil {
+			return err
+		}
+	}
+	if err := a.manifest.Remove(a.collection); err != nil {
+		return err
+	}
+	return a.report(resultView{Command: "drop", Collection: a.collection})
+}
+
+func (a *admin) compact(ctx context.Context) error {
+	for _, collection := range a.withQuestions(ctx) {
+		if err := a.repo.Compact(ctx, collection); err != nil {
+			return err
+		}
+	}
+	return a.report(resultView{Command: "compact", Collection: a.collection})
+}
+
+// withQuestions returns the collection and, when it exists, its questions
+// collection.
+func (a *admin) withQuestions(ctx context.Context) []string {
+	collections := []string{a.collection}
+	names, err := a.repo.ListCollections(ctx)
+	if err == nil && slices.Contains(names, milvusrepo.QuestionsCollection(a.collection)) {
+		collections = append(collections, milvusrepo.QuestionsCollection(a.collection))
+	}
+	return collections
+}
+
+func (a *admin) showManifest() error {
+	m, err := a.manifest.Load(a.collection)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(m)
+	}
+
+	paths := slices.Sorted(maps.Keys(m.Files))
+	fmt.Printf("manifest %s of %s, %d files\n\n", a.manifest.Path(), m.Collection, len(paths))
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tCHUNKS\tQUESTIONS\tSECTIONS\tSPLITTER\tMODEL\tDIM\tINGESTED")
+	for _, path := range paths {
+		entry := m.Files[path]
+		fmt.Fprintf(
+			w,
+			"%s\t%d\t%d\t%d\t%s\t%s\t%d\t%s\n",
+			path,
+			len(entry.ChunkIDs),
+			len(entry.QuestionIDs),
+			len(entry.SectionIDs),
+			entry.Splitter,
+			entry.EmbeddingModel,
+			entry.Dim,
+			entry.IngestedAt.Local().Format(time.DateTime),
+		)
+	}
+	return w.Flush()
+}
+
+func (a *admin) export(ctx context.Context, dir string) error {
+	svc := snapshot.NewService(a.repo, a.texts, a.manifest, a.versions)
+	m, err := svc.Export(ctx, dir, snapshot.ExportOptions{Collection: a.collection, Format: snapshot.Format(format)})
+	if err != nil {
+		return err
+	}
+	return a.printSnapshot("exported", dir, a.collection, m)
+}
+
+// importSnapshot loads into -collection; an alias keeps its name and gets
+// the imported collection as its version. The embedding model of the
+// snapshot is recorded with that version, a plain collection needs it
+// passed to the embeddings command.
+func (a *admin) importSnapshot(ctx context.Context, dir string) error {
+	target := a.collection
+	if a.alias != "" {
+		target = a.alias
+	}
+	svc := snapshot.NewService(a.repo, a.texts, a.manifest, a.versions)
+	m, err := svc.Import(ctx, dir, snapshot.ImportOptions{Collection: target, Replace: replace})
+	if err != nil {
+		return err
+	}
+	return a.printSnapshot("imported", dir, target, m)
+}
+
+func (a *admin) printSnapshot(action, dir, collection string, m *snapshot.Manifest) error {
+	if jsonOutput {
+		return printJSON(m)
+	}
+
+	fmt.Printf("%s %s: collection %s, model %s, dim %d, metric %s, format %s\n\n", action, dir, collection, m.EmbeddingModel, m.Dim, m.Metric, m.Format)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tKIND\tCOUNT\tBYTES\tSHA256")
+	for _, file := range m.Files {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", file.Name, file.Kind, file.Count, file.Bytes, file.SHA256)
+	}
+	return w.Flush()
+}
+
+func (a *admin) report(result resultView) error {
+	if jsonOutput {
+		return printJSON(result)
+	}
+
+	switch {
+	case result.DataSource != "" && result.Chunks > 0:
+		fmt.Printf("%s: deleted %d chunks of %s from %s\n", result.Command, result.Chunks, result.DataSource, result.Collection)
+	case result.DataSource != "":
+		fmt.Printf("%s: removed %s from %s\n", result.Command, result.DataSource, result.Collection)
+	default:
+		fmt.Printf("%s: %s done\n", result.Command, result.Collection)
+	}
+	return nil
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// preview cuts a chunk text to one line of previewRunes.
+func preview(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= previewRunes {
+		return text
+	}
+	return string(runes[:previewRunes]) + "…"
+}
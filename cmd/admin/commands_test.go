@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"rag-test/internal/repository/memory"
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/repository/textstore"
+	"rag-test/internal/service/snapshot"
+)
+
+// useMemoryStore points the command flags at a memory vector store and
+// local stores under a temporary directory, and restores them afterwards.
+func useMemoryStore(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+
+	savedCollection, savedManifest, savedTexts, savedLexical, savedState := collectionName, manifestPath, textStoreDir, lexicalDir, stateDir
+	savedVectors, savedFormat := vectors, format
+	t.Cleanup(func() {
+		collectionName, manifestPath, textStoreDir, lexicalDir, stateDir = savedCollection, savedManifest, savedTexts, savedLexical, savedState
+		vectors, format = savedVectors, savedFormat
+		jsonOutput, source, limit, confirmed, replace = false, "", 20, false, false
+	})
+
+	collectionName = "kb"
+	manifestPath = filepath.Join(dir, "manifest.json")
+	textStoreDir = filepath.Join(dir, "texts")
+	lexicalDir = filepath.Join(dir, "lexical")
+	stateDir = dir
+	vectors.Backend = "memory"
+	vectors.MemorySnapshot = filepath.Join(dir, "vectors.json")
+	jsonOutput = true
+	source, limit, confirmed, replace = "", 0, false, false
+	format = string(snapshot.FormatJSONL)
+}
+
+// seed stores items in collection through the text store, as ingestion
+// does.
+func seed(t *testing.T, collection string, items ...milvusrepo.VectorItem) {
+	t.Helper()
+	ctx := context.Background()
+	base, err := memory.NewRepository(memory.Config{SnapshotPath: vectors.MemorySnapshot})
+	if err != nil {
+		t.Fatalf("NewRepository: %v", err)
+	}
+	texts, err := textstore.NewStore(textStoreDir)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	repo := textstore.NewRepository(base, texts)
+	if err := repo.EnsureCollection(ctx, collection, milvusrepo.CollectionConfig{Dim: 2, Metric: milvusrepo.MetricCosine}); err != nil {
+		t.Fatalf("EnsureCollection: %v", err)
+	}
+	if err := repo.Upsert(ctx, collection, items); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func chunk(id int64, source, text string) milvusrepo.VectorItem {
+	return milvusrepo.VectorItem{ID: id, Embedding: []float32{1, float32(id)}, Payload: text, DataSource: source}
+}
+
+// runJSON runs command and decodes what it prints into out.
+func runJSON(t *testing.T, out any, command, arg string) {
+	t.Helper()
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	runErr := run(context.Background(), command, arg)
+	os.Stdout = stdout
+	w.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	if runErr != nil {
+		t.Fatalf("%s %s: %v", command, arg, runErr)
+	}
+	if err := json.Unmarshal(buf.Bytes(), out); err != nil {
+		t.Fatalf("decode output of %s %s: %v\n%s", command, arg, err, buf.String())
+	}
+}
+
+func collectionCounts(t *testing.T) map[string]int64 {
+	t.Helper()
+	var views []collectionView
+	runJSON(t, &views, "collections", "")
+	counts := make(map[string]int64, len(views))
+	for _, v := range views {
+		counts[v.Name] = v.Count
+	}
+	return counts
+}
+
+func sourceCounts(t *testing.T, collection string) map[string]int {
+	t.Helper()
+	var views []sourceView
+	runJSON(t, &views, "sources", collection)
+	counts := make(map[string]int, len(views))
+	for _, v := range views {
+		counts[v.DataSource] = v.Chunks
+	}
+	return counts
+}
+
+func TestCommands(t *testing.T) {
+	useMemoryStore(t)
+	seed(t, "kb", chunk(1, "a.md", "chairs have legs"), chunk(2, "a.md", "tables have legs"), chunk(3, "b.md", "lamps give light"))
+	seed(t, milvusrepo.QuestionsCollection("kb"), chunk(11, "a.md", "what has legs?"))
+
+	if got, want := collectionCounts(t), map[string]int64{"kb": 3, "kb_questions": 1}; !maps.Equal(got, want) {
+		t.Fatalf("collections = %v, want %v", got, want)
+	}
+
+	var info collectionView
+	runJSON(t, &info, "describe", "")
+	if info.Name != "kb" || info.Dim != 2 || info.Metric != string(milvusrepo.MetricCosine) || info.Count != 3 {
+		t.Errorf("describe = %+v", info)
+	}
+
+	if got, want := sourceCounts(t, ""), map[string]int{"a.md": 2, "b.md": 1}; !maps.Equal(got, want) {
+		t.Errorf("sources = %v, want %v", got, want)
+	}
+
+	var chunks []chunkView
+	source = "a.md"
+	runJSON(t, &chunks, "chunks", "")
+	source = ""
+	ids := make([]int64, 0, len(chunks))
+	for _, c := range chunks {
+		ids = append(ids, c.ID)
+	}
+	if !slices.Equal(ids, []int64{1, 2}) || chunks[0].Payload != "chairs have legs" {
+		t.Errorf("chunks of a.md = %+v", chunks)
+	}
+
+	// The manifest does not know a.md, so its chunks and questions are
+	// deleted directly.
+	var result resultView
+	runJSON(t, &result, "delete-source", "a.md")
+	if result.Chunks != 3 {
+		t.Errorf("delete-source deleted %d chunks, want 3", result.Chunks)
+	}
+	if got, want := collectionCounts(t), map[string]int64{"kb": 1, "kb_questions": 0}; !maps.Equal(got, want) {
+		t.Errorf("collections after delete-source = %v, want %v", got, want)
+	}
+
+	runJSON(t, &result, "compact", "")
+	if err := run(context.Background(), "delete-source", "missing.md"); err == nil {
+		t.Error("delete-source accepted a document the collection does not have")
+	}
+}
+
+func TestDropNeedsConfirmation(t *testing.T) {
+	useMemoryStore(t)
+	seed(t, "kb", chunk(1, "a.md", "chairs have legs"))
+	seed(t, milvusrepo.QuestionsCollection("kb"), chunk(11, "a.md", "what has legs?"))
+	seed(t, "other", chunk(1, "b.md", "lamps give light"))
+
+	if err := run(context.Background(), "drop", ""); err == nil {
+		t.Fatal("drop deleted the collection without -yes")
+	}
+	if got := collectionCounts(t); len(got) != 3 {
+		t.Fatalf("collections after an unconfirmed drop = %v", got)
+	}
+
+	confirmed = true
+	var result resultView
+	runJSON(t, &result, "drop", "")
+	if got, want := collectionCounts(t), map[string]int64{"other": 1}; !maps.Equal(got, want) {
+		t.Errorf("collections after drop = %v, want %v", got, want)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	useMemoryStore(t)
+	seed(t, "kb", chunk(1, "a.md", "chairs have legs"), chunk(2, "b.md", "lamps give light"))
+	dir := filepath.Join(t.TempDir(), "snapshot")
+
+	var m snapshot.Manifest
+	runJSON(t, &m, "export", dir)
+
+	collectionName = "restored"
+	runJSON(t, &m, "import", dir)
+	if got, want := sourceCounts(t, "restored"), map[string]int{"a.md": 1, "b.md": 1}; !maps.Equal(got, want) {
+		t.Errorf("sources of the import = %v, want %v", got, want)
+	}
+	if err := run(context.Background(), "import", dir); err == nil {
+		t.Error("import overwrote a collection without -replace")
+	}
+}
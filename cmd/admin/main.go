@@ -0,0 +1,193 @@
+// Command admin inspects and maintains the vector store the embeddings
+// command writes to, through the same repositories:
+//
+//	admin collections              collections with their index and size
+//	admin describe [collection]    schema, index and metric of one collection
+//	admin sources [collection]     chunks per data_source
+//	admin chunks [collection]      stored chunks, narrowed by -source and -grep
+//	admin delete-source <path>     a document with its chunks and questions
+//	admin drop [collection]        a collection with its local stores, needs -yes
+//	admin compact [collection]     reclaims the space of deleted chunks
+//	admin manifest                 the ingestion manifest
+//	admin export <dir>             a snapshot of the collection with its vectors
+//	admin import <dir>             a snapshot into the collection, -replace overwrites
+//
+// The collection defaults to -collection. With -json every command prints
+// JSON instead of text.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"rag-test/internal/repository/lexical"
+	"rag-test/internal/repository/manifest"
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/repository/textstore"
+	"rag-test/internal/repository/vectorstore"
+	"rag-test/internal/service/reindex"
+	"rag-test/internal/service/snapshot"
+)
+
+var (
+	collectionName = "testcollection"
+	manifestPath   = ".rag/manifest.json"
+	textStoreDir   = ".rag/texts"
+	lexicalDir     = ".rag/lexical"
+	stateDir       = ".rag"
+
+	vectors = vectorstore.DefaultConfig()
+
+	jsonOutput = false
+	source     = ""
+	grep       = ""
+	limit      = 20
+	fullText   = false
+	confirmed  = false
+	format     = string(snapshot.FormatJSONL)
+	replace    = false
+)
+
+// admin holds the stores a command works on. collection is the physical
+// collection behind -collection or the argument; alias is the name it was
+// reached under when that differs.
+type admin struct {
+	repo       milvusrepo.VectorRepository
+	texts      *textstore.Store
+	manifest   *manifest.Repository
+	versions   *reindex.Service
+	collection string
+	alias      string
+}
+
+func main() {
+	flag.StringVar(&collectionName, "collection", collectionName, "collection the commands work on")
+	flag.StringVar(&manifestPath, "manifest", manifestPath, "ingestion manifest of a collection without versions")
+	flag.StringVar(&textStoreDir, "text-store", textStoreDir, "directory of the full chunk texts")
+	flag.StringVar(&lexicalDir, "lexical-dir", lexicalDir, "directory of the BM25 indexes")
+	flag.StringVar(&stateDir, "state-dir", stateDir, "directory of the per-version manifests")
+	vectors.RegisterFlags(flag.CommandLine)
+	flag.BoolVar(&jsonOutput, "json", jsonOutput, "print JSON instead of text")
+	flag.StringVar(&source, "source", source, "chunks: only chunks of this data_source")
+	flag.StringVar(&grep, "grep", grep, "chunks: only chunks whose text matches this regular expression")
+	flag.IntVar(&limit, "limit", limit, "chunks: largest number of chunks printed, 0 prints all")
+	flag.BoolVar(&fullText, "full", fullText, "chunks: print whole chunk texts instead of their start")
+	flag.BoolVar(&confirmed, "yes", confirmed, "drop: confirm deleting the collection")
+	flag.StringVar(&format, "format", format, "export: record format, jsonl or binary")
+	flag.BoolVar(&replace, "replace", replace, "import: replace an existing collection once the snapshot is loaded")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: admin [flags] collections|describe|sources|chunks|delete-source|drop|compact|manifest|export|import [arg]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(context.Background(), flag.Arg(0), flag.Arg(1)); err != nil {
+		slog.Error("command failed", slog.String("command", flag.Arg(0)), slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, command, arg string) error {
+	name := collectionName
+	switch command {
+	case "delete-source", "export", "import":
+	default:
+		if arg != "" {
+			name = arg
+		}
+	}
+
+	a, err := open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer a.repo.Close()
+
+	switch command {
+	case "collections":
+		return a.collections(ctx)
+	case "describe":
+		return a.describe(ctx)
+	case "sources":
+		return a.sources(ctx)
+	case "chunks":
+		return a.chunks(ctx)
+	case "delete-source":
+		if arg == "" {
+			return errors.New("delete-source needs the data_source to delete")
+		}
+		return a.deleteSource(ctx, arg)
+	case "drop":
+		return a.drop(ctx)
+	case "compact":
+		return a.compact(ctx)
+	case "manifest":
+		return a.showManifest()
+	case "export", "import":
+		if arg == "" {
+			return fmt.Errorf("%s needs the snapshot directory", command)
+		}
+		if command == "export" {
+			return a.export(ctx, arg)
+		}
+		return a.importSnapshot(ctx, arg)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// open wraps the vector store like the embeddings command does, so chunks
+// come back with their full text and deletes reach the local stores.
+func open(ctx context.Context, name string) (*admin, error) {
+	base, err := vectorstore.Open(ctx, vectors)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &admin{repo: base, collection: name}
+	path := manifestPath
+	if milvus, ok := base.(*milvusrepo.MilvusRepository); ok {
+		target, err := milvus.ResolveAlias(ctx, name)
+		if err != nil {
+			base.Close()
+			return nil, err
+		}
+		if target != "" && target != name {
+			a.collection, a.alias = target, name
+			path = reindex.ManifestPath(stateDir, target)
+		}
+	}
+
+	if a.texts, err = textstore.NewStore(textStoreDir); err != nil {
+		base.Close()
+		return nil, err
+	}
+	if a.repo, err = lexical.NewRepository(textstore.NewRepository(base, a.texts), lexicalDir, a.collection); err != nil {
+		base.Close()
+		return nil, err
+	}
+	if a.manifest, err = manifest.NewRepository(path); err != nil {
+		base.Close()
+		return nil, err
+	}
+	if milvus, ok := base.(*milvusrepo.MilvusRepository); ok {
+		history, err := reindex.NewHistoryStore(filepath.Join(stateDir, "aliases"))
+		if err != nil {
+			base.Close()
+			return nil, err
+		}
+		stores := reindex.Stores{Texts: a.texts, LexicalDir: lexicalDir, StateDir: stateDir, LegacyManifest: manifestPath}
+		a.versions = reindex.NewService(milvus, history, stores, reindex.Config{})
+	}
+	return a, nil
+}
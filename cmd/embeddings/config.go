@@ -0,0 +1,200 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"rag-test/internal/repository/embeddings"
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/repository/vectorstore"
+	"rag-test/internal/service/rag"
+	"rag-test/internal/service/reindex"
+)
+
+// config is the command line of the embeddings command.
+type config struct {
+	Collection   string
+	DocumentsDir string
+	Token        string
+
+	// The local stores next to the vector store. A collection with versions
+	// keeps the manifest and checkpoints of each one under StateDir.
+	ManifestPath    string
+	TextStoreDir    string
+	LexicalDir      string
+	CheckpointDir   string
+	ContextCacheDir string
+	StateDir        string
+	AliasesDir      string
+
+	Ingest    ingestConfig
+	Crawl     crawlConfig
+	Index     indexConfig
+	Retrieval retrievalConfig
+	Vectors   vectorstore.Config
+	Reindex   reindexConfig
+}
+
+type ingestConfig struct {
+	Converter         string
+	Resume            bool
+	Workers           int
+	EmbedWorkers      int
+	LLMWorkers        int
+	EmbedBatchTokens  int
+	EmbedRPS          float64
+	QuestionsPerChunk int
+	ContextualHeaders bool
+	ContextInPayload  bool
+	SectionMaxTokens  int
+	LoaderSplitters   string
+	FileSplitters     string
+	SemanticMinTokens int
+	SemanticMaxTokens int
+}
+
+type crawlConfig struct {
+	URLs           string
+	Sitemaps       string
+	AllowedDomains string
+	Depth          int
+	MaxPages       int
+	Delay          time.Duration
+	Converter      string
+}
+
+// indexConfig describes a new collection or version. The settings fixed at
+// creation are left zero unless given on the command line, so an existing
+// collection keeps its own and only an explicit conflicting one is an error.
+type indexConfig struct {
+	EmbeddingModel  string
+	Dim             int
+	MaxPayloadBytes int
+	Metric          string
+	Index           string
+	Params          milvusrepo.CollectionConfig
+}
+
+type retrievalConfig struct {
+	Mode          string
+	Fusion        string
+	RRFK          int
+	LexicalWeight float64
+}
+
+type reindexConfig struct {
+	Enabled  bool
+	Version  string
+	Rollback bool
+	Drop     string
+	Validate reindex.Config
+}
+
+func parseFlags() config {
+	cfg := config{
+		Collection:      "testcollection",
+		DocumentsDir:    "documents",
+		Token:           os.Getenv("OPENAI_TOKEN"),
+		ManifestPath:    ".rag/manifest.json",
+		TextStoreDir:    ".rag/texts",
+		LexicalDir:      ".rag/lexical",
+		CheckpointDir:   ".rag/checkpoints",
+		ContextCacheDir: ".rag/contexts",
+		StateDir:        ".rag",
+		AliasesDir:      ".rag/aliases",
+		Vectors:         vectorstore.DefaultConfig(),
+	}
+
+	ing := &cfg.Ingest
+	flag.StringVar(&ing.Converter, "converter", "docling", "document converter: docling or native")
+	flag.IntVar(&ing.Workers, "ingest-workers", 4, "workers converting and splitting documents")
+	flag.IntVar(&ing.EmbedWorkers, "embed-workers", 2, "concurrent embeddings requests")
+	flag.IntVar(&ing.LLMWorkers, "llm-workers", 2, "concurrent chat model requests of the context and questions stages")
+	flag.IntVar(&ing.EmbedBatchTokens, "embed-batch-tokens", 8000, "estimated token budget of one embeddings request")
+	flag.Float64Var(&ing.EmbedRPS, "embed-rps", 2, "embeddings requests per second, 0 disables the limit")
+	flag.StringVar(&ing.LoaderSplitters, "loader-splitters", "", "splitter per loader, e.g. docx=semantic,csv=fixed")
+	flag.StringVar(&ing.FileSplitters, "file-splitters", "", "splitter per file name pattern, e.g. Выжимка*=semantic")
+	flag.IntVar(&ing.SemanticMinTokens, "semantic-min-tokens", 60, "smallest chunk the semantic splitter closes at a topic shift")
+	flag.IntVar(&ing.SemanticMaxTokens, "semantic-max-tokens", 400, "largest chunk the semantic splitter produces")
+	flag.BoolVar(&ing.Resume, "resume", false, "continue files from the embeddings saved by an interrupted run")
+	flag.IntVar(&ing.QuestionsPerChunk, "questions", 0, "generated questions indexed per chunk, 0 disables the stage")
+	flag.BoolVar(&ing.ContextualHeaders, "context-headers", false, "embed every chunk with an LLM-written context from its whole document")
+	flag.BoolVar(&ing.ContextInPayload, "context-in-payload", false, "also store the generated context in front of the chunk text")
+	flag.IntVar(&ing.SectionMaxTokens, "section-tokens", 0, "group chunks into parent sections of up to this many tokens for answering, 0 disables")
+
+	idx := &cfg.Index
+	flag.StringVar(&idx.EmbeddingModel, "embedding-model", embeddings.DefaultModel, "embedding model of a new collection or version; an existing version keeps its own")
+	flag.IntVar(&idx.Dim, "dim", embeddings.DefaultDim, "vector size of a new collection or version")
+	flag.IntVar(&idx.MaxPayloadBytes, "max-payload-bytes", milvusrepo.DefaultMaxPayloadBytes, "payload max_length of a new collection; longer chunks are kept in the text store")
+	flag.StringVar(&idx.Metric, "metric", string(milvusrepo.MetricL2), "similarity metric of a new collection: L2, IP or COSINE")
+	flag.StringVar(&idx.Index, "index", string(milvusrepo.IndexIVFFlat), "Milvus index of a new collection: FLAT, IVF_FLAT, IVF_SQ8 or HNSW")
+	flag.IntVar(&idx.Params.NList, "nlist", 128, "clusters of an IVF index")
+	flag.IntVar(&idx.Params.NProbe, "nprobe", 64, "clusters an IVF search visits")
+	flag.IntVar(&idx.Params.M, "index-m", 16, "links per node of a Milvus HNSW index")
+	flag.IntVar(&idx.Params.EfConstruction, "index-ef-construction", 200, "beam width of a Milvus HNSW index while building")
+	flag.IntVar(&idx.Params.Ef, "index-ef", 64, "beam width of a Milvus HNSW search")
+
+	crawl := &cfg.Crawl
+	flag.StringVar(&crawl.URLs, "urls", "", "comma-separated seed URLs to crawl next to the documents directory")
+	flag.StringVar(&crawl.Sitemaps, "sitemaps", "", "comma-separated sitemap URLs whose pages are crawled")
+	flag.StringVar(&crawl.AllowedDomains, "allow-domains", "", "comma-separated domains the crawler may visit, default the hosts of -urls and -sitemaps")
+	flag.IntVar(&crawl.Depth, "crawl-depth", 2, "how many links deep the crawler follows from a seed")
+	flag.IntVar(&crawl.MaxPages, "crawl-pages", 200, "largest number of pages one crawl collects")
+	flag.DurationVar(&crawl.Delay, "crawl-delay", 500*time.Millisecond, "pause between two requests to one host")
+	flag.StringVar(&crawl.Converter, "web-converter", "native", "web page converter: native or docling")
+
+	ret := &cfg.Retrieval
+	flag.StringVar(&ret.Mode, "retrieval", "hybrid", "chunk search: hybrid (BM25 and vectors) or dense")
+	flag.StringVar(&ret.Fusion, "fusion", rag.FusionRRF, "how hybrid rankings are merged: rrf or weighted")
+	flag.IntVar(&ret.RRFK, "rrf-k", 60, "rank constant of reciprocal rank fusion")
+	flag.Float64Var(&ret.LexicalWeight, "lexical-weight", 0.3, "share of the BM25 score in weighted fusion")
+
+	cfg.Vectors.RegisterFlags(flag.CommandLine)
+
+	re := &cfg.Reindex
+	flag.BoolVar(&re.Enabled, "reindex", false, "build a new version of the collection, validate it and switch the alias to it, then exit")
+	flag.StringVar(&re.Version, "reindex-version", time.Now().UTC().Format("20060102150405"), "version suffix of the collection -reindex builds; rerun with the same one to resume")
+	flag.BoolVar(&re.Rollback, "rollback", false, "switch the alias back to the previous version and exit")
+	flag.StringVar(&re.Drop, "drop-version", "", "delete an inactive version collection with its local stores and exit")
+	flag.IntVar(&re.Validate.SampleSize, "validate-sample", 50, "chunks of a new version used as validation queries")
+	flag.IntVar(&re.Validate.TopK, "validate-top-k", 10, "hits compared per validation query")
+	flag.Float64Var(&re.Validate.MinOverlap, "min-overlap", 0.5, "mean overlap with the documents of the active version's top hits a new one needs")
+	flag.Float64Var(&re.Validate.MaxCountDrop, "max-count-drop", 0.05, "share of the active version's entities a new one may lack")
+
+	flag.Parse()
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["max-payload-bytes"] {
+		idx.MaxPayloadBytes = 0
+	}
+	if !set["metric"] {
+		idx.Metric = ""
+	}
+	if !set["index"] {
+		idx.Index = ""
+	}
+	if !set["nlist"] {
+		idx.Params.NList = 0
+	}
+	if !set["index-m"] {
+		idx.Params.M = 0
+	}
+	if !set["index-ef-construction"] {
+		idx.Params.EfConstruction = 0
+	}
+	return cfg
+}
+
+// collectionConfig is the config of a new collection searched with vectors
+// of dim.
+func (c indexConfig) collectionConfig(dim int) milvusrepo.CollectionConfig {
+	cfg := c.Params
+	cfg.Dim = dim
+	cfg.MaxPayloadBytes = c.MaxPayloadBytes
+	cfg.Metric = milvusrepo.Metric(strings.ToUpper(c.Metric))
+	cfg.Index = milvusrepo.IndexType(strings.ToUpper(c.Index))
+	return cfg
+}
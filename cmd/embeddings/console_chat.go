@@ -21,12 +21,18 @@ const (
 	defaultScanLimit = 1024 * 1024
 )
 
+// answerer is the rag service the chat asks, or versionedChat in front of
+// it.
+type answerer interface {
+	Answer(ctx context.Context, req rag.Request) (*rag.Response, error)
+}
+
 type chatState struct {
 	history []rag.DialogMessage
 	topK    int
 }
 
-func runConsoleChat(ctx context.Context, ragSvc *rag.Service) error {
+func runConsoleChat(ctx context.Context, ragSvc answerer) error {
 	if ragSvc == nil {
 		return fmt.Errorf("rag service is nil")
 	}
@@ -235,7 +241,10 @@ func printCitations(out io.Writer, citations []rag.Citation) {
 		if source == "" {
 			source = "unknown"
 		}
-		fmt.Fprintf(out, "- [%s] source=%s | %s\n", c.ID, source, quote)
+		if section := rag.Breadcrumb(c.Title, c.HeadingPath); section != "" {
+			source += " section=" + section
+		}
+		fmt.Fprintf(out, "- [%s] record=%d source=%s | %s\n", c.ID, c.RecordID, source, quote)
 	}
 }
 
@@ -247,12 +256,28 @@ func printChunks(out io.Writer, chunks []rag.Chunk) {
 	fmt.Fprintln(out, "Контекстные чанки:")
 	for _, c := range chunks {
 		text := truncate(singleLine(c.Text), maxPreviewRunes)
-		source := strings.TrimSpace(c.DataSource)
+		source := strings.TrimSpace(strings.Join(c.Sources, ", "))
 		if source == "" {
 			source = "unknown"
 		}
-		fmt.Fprintf(out, "- [%s] source=%s | %s\n", c.ID, source, text)
+		if section := rag.Breadcrumb(c.Title, c.HeadingPath); section != "" {
+			source += " section=" + section
+		}
+		fmt.Fprintf(out, "- [%s] record=%d source=%s %s | %s\n", c.ID, c.RecordID, source, formatScores(c.Scores), text)
+	}
+}
+
+// formatScores shows the rank of a chunk in each search, "-" when that
+// search missed it.
+func formatScores(scores rag.HitScores) string {
+	dense, lexical := "-", "-"
+	if scores.DenseRank > 0 {
+		dense = fmt.Sprintf("#%d/%.4f", scores.DenseRank, scores.Dense)
+	}
+	if scores.LexicalRank > 0 {
+		lexical = fmt.Sprintf("#%d/%.3f", scores.LexicalRank, scores.Lexical)
 	}
+	return fmt.Sprintf("dense=%s lexical=%s fused=%.4f", dense, lexical, scores.Fused)
 }
 
 func printValidation(out io.Writer, validation rag.ValidationResult) {
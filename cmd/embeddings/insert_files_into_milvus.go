@@ -2,83 +2,185 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
-	"rag-test/internal/helpers"
-	milvusrepo "rag-test/internal/repository/milvus"
+	"os"
+	"os/signal"
 	"strings"
+
+	"rag-test/internal/crawler"
+	"rag-test/internal/helpers"
+	"rag-test/internal/loader"
+	"rag-test/internal/repository/checkpoint"
+	"rag-test/internal/repository/contexts"
+	"rag-test/internal/repository/manifest"
+	"rag-test/internal/service/ingest"
 )
 
-func processAllFiles(ctx context.Context) error {
-	if !needMigration {
-		return nil
+func (a *app) processAllFiles(ctx context.Context, collection string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	manifestRepo, err := manifest.NewRepository(a.cfg.ManifestPath)
+	if err != nil {
+		slog.Error("failed to create manifest repository", slog.String("error", err.Error()))
+		return err
 	}
 
-	var counter = 0
-	files, err := listDocumentFiles("documents")
+	checkpoints, err := checkpoint.NewRepository(a.cfg.CheckpointDir)
 	if err != nil {
-		slog.Error("failed to list documents", slog.String("error", err.Error()))
+		slog.Error("failed to create checkpoint repository", slog.String("error", err.Error()))
 		return err
 	}
 
-	for _, file := range files {
-		lgr := slog.With(
-			slog.String("path", file.Path),
-			slog.String("name", file.Name),
-		)
-		lgr.Info("❗❗processing file❗❗")
-
-		markdown, err := docling.ConvertOneFileToMarkdown(file.Path)
-		if err != nil {
-			lgr.Error(
-				"failed to convert docx to markdown",
-				slog.String("error", err.Error()),
-			)
-			return err
-		}
+	contextsRepo, err := contexts.NewRepository(a.cfg.ContextCacheDir)
+	if err != nil {
+		slog.Error("failed to create context cache", slog.String("error", err.Error()))
+		return err
+	}
 
-		markdown = strings.ToLower(markdown)
+	converter, err := a.selectConverter(a.cfg.Ingest.Converter)
+	if err != nil {
+		slog.Error("failed to select converter", slog.String("error", err.Error()))
+		return err
+	}
 
-		split, err := helpers.SplitTextByChunks(markdown)
-		if err != nil {
-			lgr.Error(
-				"failed to split text by chunks",
-				slog.String("error", err.Error()),
-			)
-			return err
-		}
+	loaderSplitters, err := parseAssignments(a.cfg.Ingest.LoaderSplitters)
+	if err != nil {
+		slog.Error("failed to parse -loader-splitters", slog.String("error", err.Error()))
+		return err
+	}
+	fileSplitters, err := parseAssignments(a.cfg.Ingest.FileSplitters)
+	if err != nil {
+		slog.Error("failed to parse -file-splitters", slog.String("error", err.Error()))
+		return err
+	}
 
-		embs, err := embedRepo.CreateEmbeddings(ctx, split...)
-		if err != nil {
-			lgr.Error(
-				"failed to create embeddings",
-				slog.String("error", err.Error()),
-			)
-			return err
-		}
+	loaders := loader.NewDefaultRegistry(converter, a.docling)
 
-		var items []milvusrepo.VectorItem
-		for i, emb := range embs {
-			vi := milvusrepo.VectorItem{
-				ID:         int64(counter),
-				Embedding:  emb,
-				Payload:    split[i],
-				DataSource: file.Path,
-			}
-
-			items = append(items, vi)
-			counter++
-		}
+	webCrawler, err := a.newCrawler()
+	if err != nil {
+		slog.Error("failed to create crawler", slog.String("error", err.Error()))
+		return err
+	}
 
-		if err = vectorRepo.Upsert(ctx, collectionName, items); err != nil {
-			lgr.Error(
-				"failed to upsert documents",
-				slog.String("error", err.Error()),
-			)
-			return err
-		}
+	deps := ingest.Dependencies{
+		Loaders:     loaders,
+		Crawler:     webCrawler,
+		Embeddings:  a.embedRepo,
+		Vectors:     a.vectorRepo,
+		Manifest:    manifestRepo,
+		Checkpoints: checkpoints,
+		OpenAI:      a.openaiRepo,
+		Contexts:    contextsRepo,
+		Sections:    a.textStore,
+	}
+	ing := a.cfg.Ingest
+	ingestSvc, err := ingest.NewService(deps, ingest.Config{
+		Collection:        collection,
+		DocumentsDir:      a.cfg.DocumentsDir,
+		LoadWorkers:       ing.Workers,
+		SplitWorkers:      ing.Workers,
+		EmbedWorkers:      ing.EmbedWorkers,
+		LLMWorkers:        ing.LLMWorkers,
+		BatchTokens:       ing.EmbedBatchTokens,
+		RequestsPerSecond: ing.EmbedRPS,
+		ContextualHeaders: ing.ContextualHeaders,
+		ContextInPayload:  ing.ContextInPayload,
+		SectionMaxTokens:  ing.SectionMaxTokens,
+		QuestionsPerChunk: ing.QuestionsPerChunk,
+		Resume:            ing.Resume,
+		LoaderSplitters:   loaderSplitters,
+		FileSplitters:     fileSplitters,
+		Semantic: helpers.SemanticOptions{
+			MinTokens: ing.SemanticMinTokens,
+			MaxTokens: ing.SemanticMaxTokens,
+		},
+	})
+	if err != nil {
+		return err
+	}
 
-		lgr.Info("✅✅processed file✅✅")
+	report, err := ingestSvc.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, failed := range report.Failed {
+		slog.Warn(
+			"file was not ingested",
+			slog.String("path", failed.Path),
+			slog.String("stage", failed.Stage),
+			slog.String("error", failed.Err.Error()),
+		)
+	}
+
+	duplicates := 0
+	for _, dup := range report.Duplicates {
+		slog.Info(
+			"near-duplicate chunks linked",
+			slog.String("path", dup.Path),
+			slog.String("of", dup.Of),
+			slog.Int("chunks", dup.Chunks),
+		)
+		duplicates += dup.Chunks
 	}
 
+	slog.Info(
+		"ingestion finished",
+		slog.Int("added", len(report.Added)),
+		slog.Int("changed", len(report.Changed)),
+		slog.Int("removed", len(report.Removed)),
+		slog.Int("unchanged", len(report.Unchanged)),
+		slog.Int("skipped", len(report.Skipped)),
+		slog.Int("failed", len(report.Failed)),
+		slog.Int("chunks", report.Chunks),
+		slog.Int("duplicates", duplicates),
+		slog.Int("resumed_embeddings", report.Resumed),
+		slog.Int("batches", report.Batches),
+		slog.String("duration", report.Duration.String()),
+	)
+
 	return nil
 }
+
+// newCrawler returns nil unless -urls or -sitemaps are set. Pages missing
+// from a later crawl are removed from the index like deleted files.
+func (a *app) newCrawler() (*crawler.Crawler, error) {
+	crawl := a.cfg.Crawl
+	seeds, sitemaps := parseList(crawl.URLs), parseList(crawl.Sitemaps)
+	if len(seeds) == 0 && len(sitemaps) == 0 {
+		return nil, nil
+	}
+
+	urlConverter, err := a.selectURLConverter(crawl.Converter)
+	if err != nil {
+		return nil, err
+	}
+
+	return crawler.New(nil, urlConverter, crawler.Config{
+		Seeds:          seeds,
+		Sitemaps:       sitemaps,
+		AllowedDomains: parseList(crawl.AllowedDomains),
+		MaxDepth:       crawl.Depth,
+		MaxPages:       crawl.MaxPages,
+		Delay:          crawl.Delay,
+	}), nil
+}
+
+// parseAssignments reads "key=value,key=value" flag values.
+func parseAssignments(value string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		out[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return out, nil
+}
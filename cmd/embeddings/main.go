@@ -2,38 +2,48 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
+	"rag-test/internal/converter/docx"
+	"rag-test/internal/crawler"
+	"rag-test/internal/loader"
 	"rag-test/internal/repository/embeddings"
+	"rag-test/internal/repository/lexical"
 	milvusrepo "rag-test/internal/repository/milvus"
 	openairepo "rag-test/internal/repository/openai"
+	"rag-test/internal/repository/textstore"
+	"rag-test/internal/repository/vectorstore"
 	"rag-test/internal/service/rag"
+	"strings"
 
 	docling_bridge "github.com/Dsouza10082/go-docling-bridge"
 )
 
-var (
-	collectionName = "testcollection"
-
-	needMigration = false
-	token         = os.Getenv("OPENAI_TOKEN")
+// app holds the configuration and the repositories a run of the command
+// shares between ingestion, reindexing and the chat.
+type app struct {
+	cfg config
 
+	// base is the vector store without the local stores wrapped around it.
+	base       milvusrepo.VectorRepository
+	vectorRepo *lexical.Repository
+	textStore  *textstore.Store
 	embedRepo  *embeddings.Repository
-	docling    = docling_bridge.NewDoclingBridge()
-	vectorRepo milvusrepo.VectorRepository
-	defaultDim = 384
-
-	milvusAddres = "localhost:19530"
-)
+	openaiRepo *openairepo.Repository
+	docling    *docling_bridge.DoclingBridge
+}
 
 func main() {
+	a := &app{cfg: parseFlags(), docling: docling_bridge.NewDoclingBridge()}
+
 	jsonHandler := slog.NewJSONHandler(os.Stdout, nil)
 	logger := slog.New(jsonHandler)
 
 	slog.SetDefault(logger)
 	slog.SetLogLoggerLevel(slog.LevelDebug)
 
-	if token == "" {
+	if a.cfg.Token == "" {
 		slog.Error("failed to get OPENAI_TOKEN")
 		return
 	}
@@ -43,37 +53,175 @@ func main() {
 		err error
 	)
 
-	embedRepo, err = embeddings.NewRepository(token)
+	a.base, err = vectorstore.Open(ctx, a.cfg.Vectors)
+	if err != nil {
+		slog.Error("failed to init vector repository", slog.String("err", err.Error()))
+		return
+	}
+
+	a.textStore, err = textstore.NewStore(a.cfg.TextStoreDir)
+	if err != nil {
+		slog.Error("failed to create text store", slog.String("err", err.Error()))
+		return
+	}
+
+	versions, err := a.newReindexService()
+	if err != nil {
+		slog.Error("failed to create reindex service", slog.String("err", err.Error()))
+		return
+	}
+	re := a.cfg.Reindex
+	if versions == nil && (re.Enabled || re.Rollback || re.Drop != "") {
+		slog.Error("collection versions need the milvus vector store", slog.String("vector_store", a.cfg.Vectors.Backend))
+		return
+	}
+	if re.Rollback || re.Drop != "" {
+		err := a.manageVersions(ctx, versions)
+		a.base.Close()
+		if err != nil {
+			slog.Error("failed to change collection versions", slog.String("err", err.Error()))
+		}
+		return
+	}
+
+	collection, embedCfg, active, err := a.selectCollection(ctx, versions)
+	if err != nil {
+		slog.Error("failed to select collection", slog.String("err", err.Error()))
+		return
+	}
+
+	a.embedRepo, err = embeddings.NewRepository(a.cfg.Token, embedCfg)
 	if err != nil {
 		slog.Error("failed to create embeddings repository", slog.String("error", err.Error()))
 		return
 	}
 
-	vectorRepo, err = milvusrepo.NewMilvusRepository(ctx, milvusAddres)
+	a.vectorRepo, err = a.newLexicalRepository(collection)
 	if err != nil {
-		slog.Error("failed to init milvus repository", slog.String("err", err.Error()))
+		slog.Error("failed to create lexical index", slog.String("err", err.Error()))
 		return
 	}
+	defer a.vectorRepo.Close()
 
-	if err := vectorRepo.EnsureCollection(ctx, collectionName, defaultDim); err != nil {
-		slog.Error("failed to ensure collection", slog.String("err", err.Error()))
+	switch a.cfg.Retrieval.Mode {
+	case "hybrid", "dense":
+	default:
+		slog.Error("unknown retrieval mode", slog.String("retrieval", a.cfg.Retrieval.Mode))
 		return
 	}
 
-	if err := processAllFiles(ctx); err != nil {
-		slog.Error("failed to process all files", slog.String("err", err.Error()))
+	collectionCfg := a.cfg.Index.collectionConfig(a.embedRepo.Dim())
+	if err := a.vectorRepo.EnsureCollection(ctx, collection, collectionCfg); err != nil {
+		slog.Error("failed to ensure collection", slog.String("err", err.Error()))
 		return
 	}
+	if a.cfg.Ingest.QuestionsPerChunk > 0 {
+		if err := a.vectorRepo.EnsureCollection(ctx, milvusrepo.QuestionsCollection(collection), collectionCfg); err != nil {
+			slog.Error("failed to ensure questions collection", slog.String("err", err.Error()))
+			return
+		}
+	}
 
-	openaiRepo, err := openairepo.NewRepository(token)
+	a.openaiRepo, err = openairepo.NewRepository(a.cfg.Token)
 	if err != nil {
 		slog.Error("failed to create openaii repository", slog.String("error", err.Error()))
 		return
 	}
 
-	ragSvc := rag.NewService(openaiRepo, embedRepo, vectorRepo, collectionName, 10)
+	if err := a.processAllFiles(ctx, collection); err != nil {
+		slog.Error("failed to process all files", slog.String("err", err.Error()))
+		return
+	}
+
+	if re.Enabled {
+		if err := a.finishReindex(ctx, versions, active, collection); err != nil {
+			slog.Error("failed to reindex", slog.String("err", err.Error()))
+		}
+		return
+	}
+
+	ragSvc, err := a.newRAG(collection, embedCfg)
+	if err != nil {
+		slog.Error("failed to create rag service", slog.String("error", err.Error()))
+		return
+	}
 
-	if err := runConsoleChat(ctx, ragSvc); err != nil {
+	var chat answerer = ragSvc
+	if versions != nil {
+		chat = &versionedChat{versions: versions, alias: a.cfg.Collection, build: a.newRAG, active: collection, svc: ragSvc}
+	}
+	if err := runConsoleChat(ctx, chat); err != nil {
 		slog.Error("chat failed", slog.String("error", err.Error()))
 	}
 }
+
+// newLexicalRepository wraps the vector store with the text store and the
+// BM25 index of collection.
+func (a *app) newLexicalRepository(collection string) (*lexical.Repository, error) {
+	return lexical.NewRepository(textstore.NewRepository(a.base, a.textStore), a.cfg.LexicalDir, collection)
+}
+
+// newRAG returns the chat over collection. Every collection gets its own
+// lexical repository, read from disk when the chat starts using it: the
+// version a swap makes active was indexed by another run, and an index
+// loaded here before that run would answer from stale chunks.
+func (a *app) newRAG(collection string, embedCfg embeddings.Config) (*rag.Service, error) {
+	embedder, err := embeddings.NewRepository(a.cfg.Token, embedCfg)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := a.newLexicalRepository(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var hybrid *lexical.Repository
+	if a.cfg.Retrieval.Mode == "hybrid" {
+		hybrid = repo
+	}
+	questionsCollection := ""
+	if a.cfg.Ingest.QuestionsPerChunk > 0 {
+		questionsCollection = milvusrepo.QuestionsCollection(collection)
+	}
+	ret := a.cfg.Retrieval
+	fusion := rag.FusionConfig{Method: ret.Fusion, RRFK: ret.RRFK, LexicalWeight: &ret.LexicalWeight}
+	if err := fusion.Validate(); err != nil {
+		return nil, err
+	}
+	return rag.NewService(a.openaiRepo, embedder, repo, a.textStore, hybrid, fusion, collection, questionsCollection, 10), nil
+}
+
+// selectURLConverter returns nil for the native converter, which makes the
+// crawler convert the pages it fetched itself.
+func (a *app) selectURLConverter(name string) (crawler.URLConverter, error) {
+	switch name {
+	case "native":
+		return nil, nil
+	case "docling":
+		return a.docling, nil
+	default:
+		return nil, fmt.Errorf("unknown web converter %q", name)
+	}
+}
+
+// parseList reads comma-separated flag values.
+func parseList(value string) []string {
+	out := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func (a *app) selectConverter(name string) (loader.Converter, error) {
+	switch name {
+	case "docling":
+		return a.docling, nil
+	case "native":
+		return docx.NewConverter(), nil
+	default:
+		return nil, fmt.Errorf("unknown converter %q", name)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"rag-test/internal/repository/embeddings"
+	"rag-test/internal/repository/manifest"
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/service/rag"
+	"rag-test/internal/service/reindex"
+)
+
+// newReindexService returns nil for the vector stores without aliases.
+func (a *app) newReindexService() (*reindex.Service, error) {
+	milvus, ok := a.base.(*milvusrepo.MilvusRepository)
+	if !ok {
+		return nil, nil
+	}
+
+	history, err := reindex.NewHistoryStore(a.cfg.AliasesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	stores := reindex.Stores{
+		Texts:          a.textStore,
+		LexicalDir:     a.cfg.LexicalDir,
+		StateDir:       a.cfg.StateDir,
+		LegacyManifest: a.cfg.ManifestPath,
+	}
+	return reindex.NewService(milvus, history, stores, a.cfg.Reindex.Validate), nil
+}
+
+// selectCollection returns the collection this run works on, the embedding
+// model it is searched with and the active version. Behind an alias that is
+// the active version at start; the chat follows later swaps through
+// versionedChat. With -reindex it is the new version.
+func (a *app) selectCollection(ctx context.Context, versions *reindex.Service) (string, embeddings.Config, reindex.Version, error) {
+	alias := a.cfg.Collection
+	cfg := embeddings.Config{Model: a.cfg.Index.EmbeddingModel, Dim: a.cfg.Index.Dim}
+	if versions == nil {
+		return alias, cfg, reindex.Version{}, nil
+	}
+
+	if a.cfg.Reindex.Enabled {
+		active, err := versions.Adopt(ctx, alias)
+		if err != nil {
+			return "", cfg, reindex.Version{}, err
+		}
+		next := reindex.VersionName(alias, a.cfg.Reindex.Version)
+		if next == active.Collection {
+			return "", cfg, reindex.Version{}, fmt.Errorf("version %s is already active", next)
+		}
+		a.useVersionState(next)
+		return next, cfg, active, nil
+	}
+
+	active, ok, err := versions.Active(ctx, alias)
+	if err != nil || !ok {
+		return alias, cfg, reindex.Version{}, err
+	}
+	a.useVersionState(active.Collection)
+	return active.Collection, embeddings.Config{Model: active.EmbeddingModel, Dim: active.Dim}, active, nil
+}
+
+// useVersionState keeps the manifest and checkpoints of a version apart
+// from the other versions.
+func (a *app) useVersionState(collection string) {
+	a.cfg.ManifestPath = reindex.ManifestPath(a.cfg.StateDir, collection)
+	a.cfg.CheckpointDir = reindex.CheckpointDir(a.cfg.StateDir, collection)
+}
+
+// finishReindex validates the version just built against the active one
+// and switches the alias to it when it passes. A version that fails is kept
+// for inspection until -drop-version removes it.
+func (a *app) finishReindex(ctx context.Context, versions *reindex.Service, active reindex.Version, next string) error {
+	manifestRepo, err := manifest.NewRepository(a.cfg.ManifestPath)
+	if err != nil {
+		return err
+	}
+	m, err := manifestRepo.Load(next)
+	if err != nil {
+		return err
+	}
+	var chunkIDs []int64
+	for _, entry := range m.Files {
+		chunkIDs = append(chunkIDs, entry.ChunkIDs...)
+	}
+
+	var old reindex.Target
+	if active.Collection != "" {
+		oldEmbedder, err := embeddings.NewRepository(a.cfg.Token, embeddings.Config{Model: active.EmbeddingModel, Dim: active.Dim})
+		if err != nil {
+			return err
+		}
+		old = reindex.Target{Collection: active.Collection, Embedder: oldEmbedder}
+	}
+
+	validation, err := versions.Validate(ctx, old, reindex.Target{Collection: next, Embedder: a.embedRepo}, chunkIDs)
+	if err != nil {
+		return err
+	}
+	slog.Info(
+		"new version validated",
+		slog.String("collection", next),
+		slog.String("active", active.Collection),
+		slog.Int64("active_count", validation.OldCount),
+		slog.Int64("count", validation.NewCount),
+		slog.Int("queries", validation.Queries),
+		slog.Float64("overlap", validation.Overlap),
+	)
+	if !validation.OK() {
+		for _, problem := range validation.Problems {
+			slog.Warn("validation failed", slog.String("collection", next), slog.String("problem", problem))
+		}
+		return fmt.Errorf("version %s failed validation and was not activated", next)
+	}
+
+	v := reindex.Version{Collection: next, EmbeddingModel: a.embedRepo.Model(), Dim: a.embedRepo.Dim()}
+	if err := versions.Activate(ctx, a.cfg.Collection, v); err != nil {
+		return err
+	}
+	slog.Info("alias switched", slog.String("alias", a.cfg.Collection), slog.String("collection", next), slog.String("previous", active.Collection))
+	return nil
+}
+
+func (a *app) manageVersions(ctx context.Context, versions *reindex.Service) error {
+	alias := a.cfg.Collection
+	if a.cfg.Reindex.Rollback {
+		prev, err := versions.Rollback(ctx, alias)
+		if err != nil {
+			return err
+		}
+		slog.Info("alias rolled back", slog.String("alias", alias), slog.String("collection", prev.Collection))
+		return nil
+	}
+
+	collection := reindex.VersionName(alias, a.cfg.Reindex.Drop)
+	if err := versions.Drop(ctx, alias, collection); err != nil {
+		return err
+	}
+	slog.Info("version dropped", slog.String("alias", alias), slog.String("collection", collection))
+	return nil
+}
+
+// versionedChat answers from the version the alias points at. It resolves
+// the alias before every question and rebuilds the rag service when a swap
+// or rollback made another version active, so a running chat follows them.
+type versionedChat struct {
+	versions *reindex.Service
+	alias    string
+	build    func(collection string, cfg embeddings.Config) (*rag.Service, error)
+	active   string
+	svc      *rag.Service
+}
+
+func (c *versionedChat) Answer(ctx context.Context, req rag.Request) (*rag.Response, error) {
+	if err := c.follow(ctx); err != nil {
+		// The known version keeps answering while the alias cannot be read.
+		slog.Warn("failed to follow collection alias", slog.String("alias", c.alias), slog.String("err", err.Error()))
+	}
+	return c.svc.Answer(ctx, req)
+}
+
+func (c *versionedChat) follow(ctx context.Context) error {
+	active, ok, err := c.versions.Active(ctx, c.alias)
+	if err != nil || !ok || active.Collection == c.active {
+		return err
+	}
+
+	svc, err := c.build(active.Collection, embeddings.Config{Model: active.EmbeddingModel, Dim: active.Dim})
+	if err != nil {
+		return err
+	}
+	slog.Info("collection alias moved", slog.String("alias", c.alias), slog.String("collection", active.Collection), slog.String("previous", c.active))
+	c.active, c.svc = active.Collection, svc
+	return nil
+}
@@ -0,0 +1,138 @@
+// Command hnswbench compares the hnsw vector store with the exact search of
+// the memory store on random vectors and reports recall and latency.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"time"
+
+	"rag-test/internal/repository/hnsw"
+	"rag-test/internal/repository/memory"
+	milvusrepo "rag-test/internal/repository/milvus"
+)
+
+const collectionName = "bench"
+
+var (
+	vectors   = 10000
+	queries   = 200
+	dim       = 384
+	topK      = 10
+	batchSize = 256
+	seed      = int64(1)
+
+	hnswConfig hnsw.Config
+)
+
+func main() {
+	flag.IntVar(&vectors, "n", vectors, "vectors in the collection")
+	flag.IntVar(&queries, "queries", queries, "queries to run")
+	flag.IntVar(&dim, "dim", dim, "vector dimension")
+	flag.IntVar(&topK, "k", topK, "hits per query")
+	flag.Int64Var(&seed, "seed", seed, "seed of the random vectors")
+	flag.IntVar(&hnswConfig.M, "m", 16, "links per node")
+	flag.IntVar(&hnswConfig.EfConstruction, "ef-construction", 200, "beam width while inserting")
+	flag.IntVar(&hnswConfig.EfSearch, "ef-search", 64, "beam width while searching")
+	flag.Parse()
+
+	if err := run(context.Background()); err != nil {
+		slog.Error("benchmark failed", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context) error {
+	dir, err := os.MkdirTemp("", "hnswbench")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	exact, err := memory.NewRepository(memory.Config{})
+	if err != nil {
+		return err
+	}
+	index, err := hnsw.NewRepository(dir, hnswConfig)
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+
+	rng := rand.New(rand.NewSource(seed))
+	items := make([]milvusrepo.VectorItem, vectors)
+	for i := range items {
+		items[i] = milvusrepo.VectorItem{
+			ID:         int64(i + 1),
+			Embedding:  randomVector(rng),
+			Payload:    fmt.Sprintf("item %d", i+1),
+			DataSource: "bench",
+		}
+	}
+	probes := make([][]float32, queries)
+	for i := range probes {
+		probes[i] = randomVector(rng)
+	}
+
+	cfg := milvusrepo.CollectionConfig{Dim: dim}
+	for name, repo := range map[string]milvusrepo.VectorRepository{"exact": exact, "hnsw": index} {
+		if err := repo.EnsureCollection(ctx, collectionName, cfg); err != nil {
+			return err
+		}
+		started := time.Now()
+		for start := 0; start < len(items); start += batchSize {
+			if err := repo.Upsert(ctx, collectionName, items[start:min(start+batchSize, len(items))]); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("%-6s build   %v\n", name, time.Since(started).Round(time.Millisecond))
+	}
+
+	var exactTime, indexTime time.Duration
+	found, total := 0, 0
+	for _, probe := range probes {
+		started := time.Now()
+		want, err := exact.Search(ctx, collectionName, milvusrepo.SearchRequest{Vector: probe, TopK: topK})
+		if err != nil {
+			return err
+		}
+		exactTime += time.Since(started)
+
+		started = time.Now()
+		got, err := index.Search(ctx, collectionName, milvusrepo.SearchRequest{Vector: probe, TopK: topK})
+		if err != nil {
+			return err
+		}
+		indexTime += time.Since(started)
+
+		expected := make(map[int64]struct{}, len(want))
+		for _, hit := range want {
+			expected[hit.ID] = struct{}{}
+		}
+		for _, hit := range got {
+			if _, ok := expected[hit.ID]; ok {
+				found++
+			}
+		}
+		total += len(want)
+	}
+
+	fmt.Printf("%-6s search  %v per query\n", "exact", exactTime/time.Duration(max(queries, 1)))
+	fmt.Printf("%-6s search  %v per query\n", "hnsw", indexTime/time.Duration(max(queries, 1)))
+	if total > 0 {
+		fmt.Printf("recall@%d %.3f\n", topK, float64(found)/float64(total))
+	}
+	return nil
+}
+
+func randomVector(rng *rand.Rand) []float32 {
+	v := make([]float32, dim)
+	for i := range v {
+		v[i] = float32(rng.NormFloat64())
+	}
+	return v
+}
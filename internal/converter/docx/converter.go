@@ -0,0 +1,383 @@
+package docx
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+const (
+	documentPart      = "word/document.xml"
+	stylesPart        = "word/styles.xml"
+	numberingPart     = "word/numbering.xml"
+	relationshipsPart = "word/_rels/document.xml.rels"
+)
+
+type Converter struct{}
+
+func NewConverter() *Converter {
+	return &Converter{}
+}
+
+func (c *Converter) ConvertOneFileToMarkdown(filePath string) (string, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return "", fmt.Errorf("docx: open %s: %w", filePath, err)
+	}
+	defer zr.Close()
+
+	return Convert(&zr.Reader)
+}
+
+func Convert(zr *zip.Reader) (string, error) {
+	document, err := readPart(zr, documentPart)
+	if err != nil {
+		return "", err
+	}
+	if document == nil {
+		return "", errors.New("docx: word/document.xml not found")
+	}
+
+	styles, err := readPart(zr, stylesPart)
+	if err != nil {
+		return "", err
+	}
+	numbering, err := readPart(zr, numberingPart)
+	if err != nil {
+		return "", err
+	}
+	rels, err := readPart(zr, relationshipsPart)
+	if err != nil {
+		return "", err
+	}
+
+	r := &renderer{
+		styles:    parseStyles(styles),
+		numbering: parseNumbering(numbering),
+		rels:      parseRelationships(rels),
+	}
+	r.walkBlocks(document.child("document").child("body"))
+
+	return r.markdown(), nil
+}
+
+func readPart(zr *zip.Reader, name string) (*node, error) {
+	f, err := zr.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("docx: open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	root, err := parseXML(f)
+	if err != nil {
+		return nil, fmt.Errorf("docx: parse %s: %w", name, err)
+	}
+	return root, nil
+}
+
+type block struct {
+	text     string
+	listItem bool
+}
+
+type segment struct {
+	text string
+	bold bool
+	link string
+}
+
+type renderer struct {
+	styles    map[string]styleInfo
+	numbering map[string]map[int]bool
+	rels      map[string]string
+	blocks    []block
+}
+
+func (r *renderer) markdown() string {
+	var b strings.Builder
+	for i, blk := range r.blocks {
+		if i > 0 {
+			if blk.listItem && r.blocks[i-1].listItem {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(blk.text)
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (r *renderer) walkBlocks(n *node) {
+	if n == nil {
+		return
+	}
+	for _, c := range n.children {
+		switch c.name {
+		case "p":
+			r.paragraph(c)
+		case "tbl":
+			r.table(c)
+		case "sectPr":
+		default:
+			r.walkBlocks(c)
+		}
+	}
+}
+
+func (r *renderer) paragraph(p *node) {
+	pPr := p.child("pPr")
+	style := r.styles[pPr.child("pStyle").attr("val")]
+
+	level := style.headingLevel
+	if l := outlineLevel(pPr); l > 0 {
+		level = l
+	}
+
+	if level > 0 {
+		text := singleLine(renderSegments(r.segments(p, false)))
+		if text != "" {
+			r.blocks = append(r.blocks, block{text: strings.Repeat("#", level) + " " + text})
+		}
+		return
+	}
+
+	text := strings.TrimSpace(renderSegments(r.segments(p, style.bold)))
+	if text == "" {
+		return
+	}
+
+	if numPr := pPr.child("numPr"); numPr != nil {
+		ilvl := atoiOrZero(numPr.child("ilvl").attr("val"))
+		marker := "-"
+		if levels, ok := r.numbering[numPr.child("numId").attr("val")]; ok && levels[ilvl] {
+			marker = "1."
+		}
+		r.blocks = append(r.blocks, block{
+			text:     strings.Repeat("  ", ilvl) + marker + " " + text,
+			listItem: true,
+		})
+		return
+	}
+
+	if rest, ok := trimBullet(text); ok {
+		r.blocks = append(r.blocks, block{text: "- " + rest, listItem: true})
+		return
+	}
+
+	r.blocks = append(r.blocks, block{text: text})
+}
+
+// table renders a Word table as a Markdown table. Only a first row marked as
+// a repeating header, or one whose cells are all bold, becomes the Markdown
+// header; otherwise the header is left empty so no data row is lost to it.
+func (r *renderer) table(tbl *node) {
+	trs := tbl.childrenNamed("tr")
+	rows := make([][]string, 0, len(trs))
+	cols := 0
+	for _, tr := range trs {
+		row := make([]string, 0)
+		for _, tc := range tr.childrenNamed("tc") {
+			row = append(row, r.cellText(tc))
+			span := atoiOrZero(tc.child("tcPr").child("gridSpan").attr("val"))
+			for i := 1; i < span; i++ {
+				row = append(row, "")
+			}
+		}
+		cols = max(cols, len(row))
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	if cols <= 1 {
+		for _, row := range rows {
+			if len(row) > 0 && row[0] != "" {
+				r.blocks = append(r.blocks, block{text: row[0]})
+			}
+		}
+		return
+	}
+
+	header := make([]string, cols)
+	if r.headerRow(trs[0]) {
+		copy(header, rows[0])
+		rows = rows[1:]
+	}
+	separator := make([]string, cols)
+	for i := range separator {
+		separator[i] = "---"
+	}
+
+	lines := make([]string, 0, len(rows)+2)
+	lines = append(lines, "| "+strings.Join(header, " | ")+" |")
+	lines = append(lines, "| "+strings.Join(separator, " | ")+" |")
+	for _, row := range rows {
+		for len(row) < cols {
+			row = append(row, "")
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+	}
+	r.blocks = append(r.blocks, block{text: strings.Join(lines, "\n")})
+}
+
+// headerRow reports whether a table row is a header: marked to repeat on
+// every page, or with bold text in every non-empty cell.
+func (r *renderer) headerRow(tr *node) bool {
+	if tr.child("trPr").flag("tblHeader") {
+		return true
+	}
+
+	hasText := false
+	for _, tc := range tr.childrenNamed("tc") {
+		for _, p := range tc.childrenNamed("p") {
+			style := r.styles[p.child("pPr").child("pStyle").attr("val")]
+			for _, seg := range r.segments(p, style.bold) {
+				if strings.TrimSpace(seg.text) == "" {
+					continue
+				}
+				if !seg.bold {
+					return false
+				}
+				hasText = true
+			}
+		}
+	}
+	return hasText
+}
+
+func (r *renderer) cellText(tc *node) string {
+	parts := make([]string, 0)
+	var collect func(n *node)
+	collect = func(n *node) {
+		for _, c := range n.children {
+			switch c.name {
+			case "p":
+				style := r.styles[c.child("pPr").child("pStyle").attr("val")]
+				if text := singleLine(renderSegments(r.segments(c, style.bold))); text != "" {
+					parts = append(parts, text)
+				}
+			case "tcPr":
+			default:
+				collect(c)
+			}
+		}
+	}
+	collect(tc)
+
+	return strings.ReplaceAll(strings.Join(parts, " "), "|", "\\|")
+}
+
+func (r *renderer) segments(p *node, bold bool) []segment {
+	segs := make([]segment, 0)
+	r.collectRuns(p, bold, "", &segs)
+	return segs
+}
+
+func (r *renderer) collectRuns(n *node, bold bool, link string, segs *[]segment) {
+	for _, c := range n.children {
+		switch c.name {
+		case "r":
+			r.run(c, bold, link, segs)
+		case "hyperlink":
+			target := link
+			if t := r.rels[c.attr("id")]; t != "" {
+				target = t
+			}
+			r.collectRuns(c, bold, target, segs)
+		case "pPr", "rPr", "del", "moveFrom":
+		default:
+			r.collectRuns(c, bold, link, segs)
+		}
+	}
+}
+
+func (r *renderer) run(run *node, bold bool, link string, segs *[]segment) {
+	if rPr := run.child("rPr"); rPr.child("b") != nil {
+		bold = rPr.flag("b")
+	}
+
+	var b strings.Builder
+	for _, c := range run.children {
+		switch c.name {
+		case "t":
+			b.WriteString(c.text.String())
+		case "tab":
+			b.WriteString(" ")
+		case "br", "cr":
+			b.WriteString("\n")
+		case "noBreakHyphen":
+			b.WriteString("-")
+		}
+	}
+	if b.Len() == 0 {
+		return
+	}
+
+	*segs = append(*segs, segment{text: b.String(), bold: bold, link: link})
+}
+
+func renderSegments(segs []segment) string {
+	merged := make([]segment, 0, len(segs))
+	for _, seg := range segs {
+		if n := len(merged); n > 0 && merged[n-1].bold == seg.bold && merged[n-1].link == seg.link {
+			merged[n-1].text += seg.text
+			continue
+		}
+		merged = append(merged, seg)
+	}
+
+	var b strings.Builder
+	for _, seg := range merged {
+		core := strings.TrimSpace(seg.text)
+		if core == "" {
+			b.WriteString(seg.text)
+			continue
+		}
+		lead := seg.text[:strings.Index(seg.text, core)]
+		trail := seg.text[len(lead)+len(core):]
+
+		if seg.bold {
+			core = "**" + core + "**"
+		}
+		if seg.link != "" {
+			core = "[" + core + "](" + seg.link + ")"
+		}
+		b.WriteString(lead)
+		b.WriteString(core)
+		b.WriteString(trail)
+	}
+	return b.String()
+}
+
+func trimBullet(text string) (string, bool) {
+	for _, bullet := range []string{"•", "·", "▪", "◦"} {
+		if rest, ok := strings.CutPrefix(text, bullet); ok {
+			rest = strings.TrimSpace(rest)
+			return rest, rest != ""
+		}
+	}
+	return "", false
+}
+
+func singleLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func atoiOrZero(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
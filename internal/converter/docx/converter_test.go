@@ -0,0 +1,133 @@
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestConvertDocuments converts every document shipped in documents/ and
+// compares the result with testdata/<name>.md. Run with -update after an
+// intended change to the output.
+func TestConvertDocuments(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "..", "..", "documents", "*.docx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no documents found")
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".docx")
+		t.Run(name, func(t *testing.T) {
+			got, err := NewConverter().ConvertOneFileToMarkdown(path)
+			if err != nil {
+				t.Fatalf("ConvertOneFileToMarkdown: %v", err)
+			}
+
+			golden := filepath.Join("testdata", name+".md")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden file (run with -update to create it): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("markdown differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestConvertTables(t *testing.T) {
+	const (
+		plain = `<w:tc><w:p><w:r><w:t>%s</w:t></w:r></w:p></w:tc>`
+		bold  = `<w:tc><w:p><w:r><w:rPr><w:b/></w:rPr><w:t>%s</w:t></w:r></w:p></w:tc>`
+	)
+	cell := func(format, text string) string { return strings.Replace(format, "%s", text, 1) }
+
+	tests := []struct {
+		name string
+		rows string
+		want string
+	}{
+		{
+			name: "first row is data",
+			rows: `<w:tr>` + cell(plain, "chair") + cell(plain, "a long description") + `</w:tr>` +
+				`<w:tr>` + cell(plain, "table") + cell(plain, "oak") + `</w:tr>`,
+			want: "|  |  |\n| --- | --- |\n| chair | a long description |\n| table | oak |\n",
+		},
+		{
+			name: "repeating header row",
+			rows: `<w:tr><w:trPr><w:tblHeader/></w:trPr>` + cell(plain, "Name") + cell(plain, "Price") + `</w:tr>` +
+				`<w:tr>` + cell(plain, "chair") + cell(plain, "10") + `</w:tr>`,
+			want: "| Name | Price |\n| --- | --- |\n| chair | 10 |\n",
+		},
+		{
+			name: "bold header row",
+			rows: `<w:tr>` + cell(bold, "Name") + cell(bold, "Price") + `</w:tr>` +
+				`<w:tr>` + cell(plain, "chair") + cell(plain, "10") + `</w:tr>`,
+			want: "| **Name** | **Price** |\n| --- | --- |\n| chair | 10 |\n",
+		},
+		{
+			name: "partly bold first row",
+			rows: `<w:tr>` + cell(bold, "chair") + cell(plain, "wood") + `</w:tr>`,
+			want: "|  |  |\n| --- | --- |\n| **chair** | wood |\n",
+		},
+		{
+			name: "header marker switched off",
+			rows: `<w:tr><w:trPr><w:tblHeader w:val="0"/></w:trPr>` + cell(plain, "chair") + cell(plain, "10") + `</w:tr>`,
+			want: "|  |  |\n| --- | --- |\n| chair | 10 |\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(documentArchive(t, `<w:tbl>`+tt.rows+`</w:tbl>`))
+			if err != nil {
+				t.Fatalf("Convert: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("markdown = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// documentArchive builds a minimal .docx holding body as word/document.xml.
+func documentArchive(t *testing.T, body string) *zip.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(documentPart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>` +
+		`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		body + `</w:body></w:document>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
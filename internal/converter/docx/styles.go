@@ -0,0 +1,94 @@
+package docx
+
+import (
+	"strconv"
+	"strings"
+)
+
+type styleInfo struct {
+	headingLevel int
+	bold         bool
+}
+
+func parseStyles(root *node) map[string]styleInfo {
+	styles := make(map[string]styleInfo)
+	for _, s := range root.child("styles").childrenNamed("style") {
+		if s.attr("type") != "paragraph" {
+			continue
+		}
+
+		info := styleInfo{
+			headingLevel: headingLevelFromName(s.child("name").attr("val")),
+			bold:         s.child("rPr").flag("b"),
+		}
+		if info.headingLevel == 0 {
+			info.headingLevel = outlineLevel(s.child("pPr"))
+		}
+		styles[s.attr("styleId")] = info
+	}
+	return styles
+}
+
+func headingLevelFromName(name string) int {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch {
+	case name == "title":
+		return 1
+	case strings.HasPrefix(name, "heading "):
+		level, err := strconv.Atoi(strings.TrimPrefix(name, "heading "))
+		if err != nil || level < 1 {
+			return 0
+		}
+		return min(level, 6)
+	default:
+		return 0
+	}
+}
+
+func outlineLevel(pPr *node) int {
+	value := pPr.child("outlineLvl").attr("val")
+	if value == "" {
+		return 0
+	}
+	level, err := strconv.Atoi(value)
+	if err != nil || level < 0 || level > 8 {
+		return 0
+	}
+	return min(level+1, 6)
+}
+
+// parseNumbering maps numId to the per-level "is ordered" flag of its abstract numbering.
+func parseNumbering(root *node) map[string]map[int]bool {
+	numbering := root.child("numbering")
+	abstract := make(map[string]map[int]bool)
+	for _, a := range numbering.childrenNamed("abstractNum") {
+		levels := make(map[int]bool)
+		for _, lvl := range a.childrenNamed("lvl") {
+			ilvl, err := strconv.Atoi(lvl.attr("ilvl"))
+			if err != nil {
+				continue
+			}
+			format := lvl.child("numFmt").attr("val")
+			levels[ilvl] = format != "" && format != "bullet" && format != "none"
+		}
+		abstract[a.attr("abstractNumId")] = levels
+	}
+
+	nums := make(map[string]map[int]bool)
+	for _, n := range numbering.childrenNamed("num") {
+		if levels, ok := abstract[n.child("abstractNumId").attr("val")]; ok {
+			nums[n.attr("numId")] = levels
+		}
+	}
+	return nums
+}
+
+func parseRelationships(root *node) map[string]string {
+	rels := make(map[string]string)
+	for _, rel := range root.child("Relationships").childrenNamed("Relationship") {
+		if strings.HasSuffix(rel.attr("Type"), "/hyperlink") {
+			rels[rel.attr("Id")] = rel.attr("Target")
+		}
+	}
+	return rels
+}
@@ -0,0 +1,96 @@
+package docx
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+type node struct {
+	name     string
+	attrs    map[string]string
+	children []*node
+	text     strings.Builder
+}
+
+func parseXML(r io.Reader) (*node, error) {
+	decoder := xml.NewDecoder(r)
+	root := &node{name: "#document"}
+	stack := []*node{root}
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, attr := range t.Attr {
+				n.attrs[attr.Name.Local] = attr.Value
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			stack[len(stack)-1].text.Write(t)
+		}
+	}
+
+	return root, nil
+}
+
+func (n *node) child(name string) *node {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *node) attr(name string) string {
+	if n == nil {
+		return ""
+	}
+	return n.attrs[name]
+}
+
+// flag reports whether an on/off property such as <w:b/> is switched on.
+func (n *node) flag(name string) bool {
+	c := n.child(name)
+	if c == nil {
+		return false
+	}
+	switch c.attr("val") {
+	case "0", "false", "off", "none":
+		return false
+	default:
+		return true
+	}
+}
+
+func (n *node) childrenNamed(name string) []*node {
+	if n == nil {
+		return nil
+	}
+	out := make([]*node, 0)
+	for _, c := range n.children {
+		if c.name == name {
+			out = append(out, c)
+		}
+	}
+	return out
+}
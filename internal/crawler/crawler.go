@@ -0,0 +1,432 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"rag-test/internal/loader"
+)
+
+const (
+	defaultMaxPages  = 200
+	defaultUserAgent = "rag-test-crawler/1.0"
+	maxBodyBytes     = 10 << 20
+	maxRedirects     = 10
+)
+
+// Config describes one crawl. Seeds and the pages listed in Sitemaps start
+// at depth 0; links are followed up to MaxDepth. AllowedDomains limits the
+// crawl to these hosts and their subdomains and defaults to the hosts of
+// the seeds and sitemaps.
+type Config struct {
+	Seeds          []string
+	Sitemaps       []string
+	AllowedDomains []string
+	MaxDepth       int
+	MaxPages       int
+	UserAgent      string
+	// Delay is the pause between two requests to one host; a larger
+	// Crawl-delay in robots.txt wins.
+	Delay time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.MaxDepth < 0 {
+		c.MaxDepth = 0
+	}
+	if c.MaxPages <= 0 {
+		c.MaxPages = defaultMaxPages
+	}
+	if c.UserAgent == "" {
+		c.UserAgent = defaultUserAgent
+	}
+	return c
+}
+
+// URLConverter renders a page to Markdown by its URL, e.g. the docling
+// bridge. Links are still taken from the page the crawler fetched.
+type URLConverter interface {
+	ConvertURLToMarkdown(url string) (string, error)
+}
+
+type Page struct {
+	URL       string
+	Title     string
+	Markdown  string
+	FetchedAt time.Time
+}
+
+type FetchError struct {
+	URL string
+	Err error
+}
+
+type Result struct {
+	Pages  []Page
+	Failed []FetchError
+	// Blocked lists the URLs robots.txt does not allow to fetch.
+	Blocked []string
+}
+
+type Crawler struct {
+	client *http.Client
+	// robotsClient fetches robots.txt without the redirect checks, which
+	// need robots.txt themselves.
+	robotsClient *http.Client
+	converter    URLConverter
+	cfg          Config
+	domains      []string
+
+	robots    map[string]*robotsRules
+	lastFetch map[string]time.Time
+}
+
+// New creates a crawler; a nil client means http.DefaultClient and a nil
+// converter means pages are converted with loader.ParseHTML. The crawler
+// works on a copy of the client whose redirects are checked hop by hop.
+func New(client *http.Client, converter URLConverter, cfg Config) *Crawler {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	cfg = cfg.withDefaults()
+
+	domains := make([]string, 0, len(cfg.AllowedDomains))
+	for _, domain := range cfg.AllowedDomains {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	if len(domains) == 0 {
+		for _, raw := range append(append([]string{}, cfg.Seeds...), cfg.Sitemaps...) {
+			if u, err := url.Parse(raw); err == nil && u.Hostname() != "" {
+				domains = append(domains, strings.ToLower(u.Hostname()))
+			}
+		}
+	}
+
+	c := &Crawler{
+		robotsClient: client,
+		converter:    converter,
+		cfg:          cfg,
+		domains:      domains,
+		robots:       make(map[string]*robotsRules),
+		lastFetch:    make(map[string]time.Time),
+	}
+	checked := *client
+	checked.CheckRedirect = c.checkRedirect
+	c.client = &checked
+	return c
+}
+
+type queuedURL struct {
+	url   string
+	depth int
+}
+
+// Crawl fetches pages breadth-first until the queue is empty or MaxPages
+// pages were collected. Pages that fail are reported and skipped; only a
+// cancelled context stops the crawl with an error.
+func (c *Crawler) Crawl(ctx context.Context) (Result, error) {
+	var result Result
+
+	queue := make([]queuedURL, 0)
+	visited := make(map[string]struct{})
+	// crawled holds the URLs after redirects, so two links redirecting to
+	// one page yield it once.
+	crawled := make(map[string]struct{})
+	enqueue := func(raw string, base *url.URL, depth int) {
+		normalized, ok := normalizeURL(raw, base)
+		if !ok || !c.allowedURL(normalized) {
+			return
+		}
+		if _, ok := visited[normalized]; ok {
+			return
+		}
+		visited[normalized] = struct{}{}
+		queue = append(queue, queuedURL{url: normalized, depth: depth})
+	}
+
+	for _, seed := range c.cfg.Seeds {
+		enqueue(seed, nil, 0)
+	}
+	for _, sitemap := range c.cfg.Sitemaps {
+		urls, err := c.sitemapURLs(ctx, sitemap, 0)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			if errors.Is(err, errBlocked) {
+				result.Blocked = append(result.Blocked, sitemap)
+				continue
+			}
+			slog.Warn("failed to read sitemap", slog.String("url", sitemap), slog.String("error", err.Error()))
+			result.Failed = append(result.Failed, FetchError{URL: sitemap, Err: err})
+			continue
+		}
+		for _, u := range urls {
+			enqueue(u, nil, 0)
+		}
+	}
+
+	for len(queue) > 0 && len(result.Pages) < c.cfg.MaxPages {
+		next := queue[0]
+		queue = queue[1:]
+
+		page, links, err := c.crawlPage(ctx, next.url)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			if errors.Is(err, errBlocked) {
+				result.Blocked = append(result.Blocked, next.url)
+				continue
+			}
+			slog.Warn("failed to crawl page", slog.String("url", next.url), slog.String("error", err.Error()))
+			result.Failed = append(result.Failed, FetchError{URL: next.url, Err: err})
+			continue
+		}
+		if page == nil {
+			continue
+		}
+
+		if _, ok := crawled[page.URL]; ok {
+			continue
+		}
+		crawled[page.URL] = struct{}{}
+		visited[page.URL] = struct{}{}
+		if strings.TrimSpace(page.Markdown) != "" {
+			result.Pages = append(result.Pages, *page)
+		}
+
+		if next.depth < c.cfg.MaxDepth {
+			base, _ := url.Parse(page.URL)
+			for _, link := range links {
+				enqueue(link, base, next.depth+1)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+var (
+	errBlocked   = errors.New("blocked by robots.txt")
+	errOffDomain = errors.New("redirected outside the allowed domains")
+)
+
+// crawlPage returns nil without an error for pages that are not HTML or
+// redirect outside the allowed domains.
+func (c *Crawler) crawlPage(ctx context.Context, pageURL string) (*Page, []string, error) {
+	body, finalURL, contentType, err := c.get(ctx, pageURL)
+	if errors.Is(err, errOffDomain) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, nil, nil
+	}
+
+	parsed, err := loader.ParseHTML(bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	markdown := parsed.Markdown
+	if c.converter != nil {
+		// The converter fetches the page once more, so it waits for the
+		// host like any other request.
+		u, err := url.Parse(finalURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := c.wait(ctx, u.Host, c.robotsFor(ctx, u)); err != nil {
+			return nil, nil, err
+		}
+		markdown, err = c.converter.ConvertURLToMarkdown(finalURL)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &Page{
+		URL:       finalURL,
+		Title:     parsed.Title,
+		Markdown:  markdown,
+		FetchedAt: time.Now().UTC(),
+	}, parsed.Links, nil
+}
+
+// get fetches rawURL once robots.txt allows it and the host delay passed.
+func (c *Crawler) get(ctx context.Context, rawURL string) ([]byte, string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	rules := c.robotsFor(ctx, u)
+	if !rules.allowed(u.RequestURI()) {
+		return nil, "", "", errBlocked
+	}
+	if err := c.wait(ctx, u.Host, rules); err != nil {
+		return nil, "", "", err
+	}
+	return c.fetch(ctx, rawURL)
+}
+
+// checkRedirect holds every redirect hop to the rules of the first
+// request: the allowed domains, robots.txt and the host delay.
+func (c *Crawler) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if !c.allowedURL(req.URL.String()) {
+		return errOffDomain
+	}
+	rules := c.robotsFor(req.Context(), req.URL)
+	if !rules.allowed(req.URL.RequestURI()) {
+		return errBlocked
+	}
+	return c.wait(req.Context(), req.URL.Host, rules)
+}
+
+// fetch returns the body, the URL after redirects and the content type.
+func (c *Crawler) fetch(ctx context.Context, rawURL string) ([]byte, string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	req.Header.Set("User-Agent", c.cfg.UserAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", "", fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes+1))
+	if err != nil {
+		return nil, "", "", err
+	}
+	if len(body) > maxBodyBytes {
+		return nil, "", "", fmt.Errorf("GET %s: body exceeds %d bytes", rawURL, maxBodyBytes)
+	}
+
+	finalURL := rawURL
+	if normalized, ok := normalizeURL(resp.Request.URL.String(), nil); ok {
+		finalURL = normalized
+	}
+
+	return body, finalURL, resp.Header.Get("Content-Type"), nil
+}
+
+// robotsFor loads robots.txt once per host. A missing file allows
+// everything; a server error or an unreachable host disallows the host.
+func (c *Crawler) robotsFor(ctx context.Context, u *url.URL) *robotsRules {
+	key := u.Scheme + "://" + u.Host
+	if rules, ok := c.robots[key]; ok {
+		return rules
+	}
+
+	rules := c.loadRobots(ctx, key+"/robots.txt")
+	c.robots[key] = rules
+	return rules
+}
+
+func (c *Crawler) loadRobots(ctx context.Context, robotsURL string) *robotsRules {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return disallowAll
+	}
+	req.Header.Set("User-Agent", c.cfg.UserAgent)
+
+	resp, err := c.robotsClient.Do(req)
+	if err != nil {
+		slog.Warn("failed to fetch robots.txt", slog.String("url", robotsURL), slog.String("error", err.Error()))
+		return disallowAll
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		slog.Warn("robots.txt is unavailable", slog.String("url", robotsURL), slog.String("status", resp.Status))
+		return disallowAll
+	case resp.StatusCode != http.StatusOK:
+		return allowAll
+	}
+
+	rules, err := parseRobots(io.LimitReader(resp.Body, maxBodyBytes), c.cfg.UserAgent)
+	if err != nil {
+		slog.Warn("failed to parse robots.txt", slog.String("url", robotsURL), slog.String("error", err.Error()))
+		return disallowAll
+	}
+	return rules
+}
+
+func (c *Crawler) wait(ctx context.Context, host string, rules *robotsRules) error {
+	delay := max(c.cfg.Delay, rules.crawlDelay)
+	if last, ok := c.lastFetch[host]; ok && delay > 0 {
+		if remaining := delay - time.Since(last); remaining > 0 {
+			timer := time.NewTimer(remaining)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	c.lastFetch[host] = time.Now()
+	return nil
+}
+
+func (c *Crawler) allowedURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range c.domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeURL resolves raw against base and drops the fragment, so one
+// page is fetched once; only http and https URLs are kept.
+func normalizeURL(raw string, base *url.URL) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return "", false
+	}
+
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Host = strings.ToLower(u.Host)
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String(), true
+}
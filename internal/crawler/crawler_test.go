@@ -0,0 +1,294 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// site serves HTML pages, robots.txt, sitemaps and redirects from one
+// httptest server and records the paths requested under each host. In the
+// page bodies {server} is the server URL and {localhost} the same server
+// under another host name.
+type site struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests map[string][]string
+}
+
+func newSite(t *testing.T, robots string, pages map[string]string) *site {
+	t.Helper()
+
+	s := &site{requests: make(map[string][]string)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := strings.Cut(r.Host, ":")
+		s.mu.Lock()
+		s.requests[host] = append(s.requests[host], r.URL.Path)
+		s.mu.Unlock()
+
+		if r.URL.Path == "/robots.txt" {
+			if robots == "" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, robots)
+			return
+		}
+
+		body, ok := pages[r.URL.Path]
+		body = strings.NewReplacer("{server}", s.URL, "{localhost}", s.localhostURL()).Replace(body)
+		switch {
+		case !ok:
+			http.NotFound(w, r)
+		case strings.HasPrefix(body, "redirect:"):
+			http.Redirect(w, r, strings.TrimPrefix(body, "redirect:"), http.StatusFound)
+		case strings.HasSuffix(r.URL.Path, ".xml"):
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprint(w, body)
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, body)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *site) requested(host string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests[host])
+}
+
+// localhostURL is the server under another host name, which the crawler
+// treats as a different domain.
+func (s *site) localhostURL() string {
+	return strings.Replace(s.URL, "127.0.0.1", "localhost", 1)
+}
+
+func page(title string, links ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<html><head><title>%s</title></head><body><p>Text of %s.</p>", title, title)
+	for _, link := range links {
+		fmt.Fprintf(&b, `<a href="%s">%s</a>`, link, link)
+	}
+	b.WriteString("</body></html>")
+	return b.String()
+}
+
+func crawl(t *testing.T, cfg Config) Result {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := New(nil, nil, cfg).Crawl(ctx)
+	if err != nil {
+		t.Fatalf("Crawl: %v", err)
+	}
+	return result
+}
+
+func pageURLs(result Result) []string {
+	urls := make([]string, 0, len(result.Pages))
+	for _, p := range result.Pages {
+		urls = append(urls, p.URL)
+	}
+	slices.Sort(urls)
+	return urls
+}
+
+func TestCrawlRobots(t *testing.T) {
+	s := newSite(t, "User-agent: *\nDisallow: /private\n", map[string]string{
+		"/":          page("Home", "/public", "/private/secret"),
+		"/public":    page("Public"),
+		"/private/x": page("Private"),
+	})
+
+	result := crawl(t, Config{Seeds: []string{s.URL}, MaxDepth: 2})
+
+	if got, want := pageURLs(result), []string{s.URL + "/", s.URL + "/public"}; !slices.Equal(got, want) {
+		t.Errorf("pages = %q, want %q", got, want)
+	}
+	if want := []string{s.URL + "/private/secret"}; !slices.Equal(result.Blocked, want) {
+		t.Errorf("blocked = %q, want %q", result.Blocked, want)
+	}
+	if slices.Contains(s.requested("127.0.0.1"), "/private/secret") {
+		t.Error("a page disallowed by robots.txt was requested")
+	}
+}
+
+func TestCrawlDepthAndHosts(t *testing.T) {
+	s := newSite(t, "", map[string]string{
+		"/":      page("Home", "/a", "{server}/a#section", "{localhost}/other"),
+		"/a":     page("A", "/b"),
+		"/b":     page("B", "/c"),
+		"/c":     page("C"),
+		"/other": page("Other"),
+	})
+
+	tests := []struct {
+		name     string
+		maxDepth int
+		maxPages int
+		want     []string
+	}{
+		{name: "seed only", maxDepth: 0, want: []string{"/"}},
+		{name: "one link deep", maxDepth: 1, want: []string{"/", "/a"}},
+		{name: "all pages", maxDepth: 5, want: []string{"/", "/a", "/b", "/c"}},
+		{name: "page limit", maxDepth: 5, maxPages: 2, want: []string{"/", "/a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := crawl(t, Config{Seeds: []string{s.URL}, MaxDepth: tt.maxDepth, MaxPages: tt.maxPages})
+
+			want := make([]string, 0, len(tt.want))
+			for _, path := range tt.want {
+				want = append(want, s.URL+path)
+			}
+			if got := pageURLs(result); !slices.Equal(got, want) {
+				t.Errorf("pages = %q, want %q", got, want)
+			}
+		})
+	}
+
+	if got := s.requested("localhost"); len(got) > 0 {
+		t.Errorf("requests outside the allowed domains: %q", got)
+	}
+}
+
+func TestCrawlRedirects(t *testing.T) {
+	s := newSite(t, "User-agent: *\nDisallow: /private\n", map[string]string{
+		"/":             page("Home", "/renamed", "/public", "/moved", "/away"),
+		"/public":       page("Public"),
+		"/renamed":      "redirect:/public",
+		"/moved":        "redirect:/private/page",
+		"/away":         "redirect:{localhost}/other",
+		"/private/page": page("Private"),
+		"/other":        page("Other"),
+	})
+
+	result := crawl(t, Config{Seeds: []string{s.URL}, MaxDepth: 1})
+
+	if got, want := pageURLs(result), []string{s.URL + "/", s.URL + "/public"}; !slices.Equal(got, want) {
+		t.Errorf("pages = %q, want %q", got, want)
+	}
+	if want := []string{s.URL + "/moved"}; !slices.Equal(result.Blocked, want) {
+		t.Errorf("blocked = %q, want %q", result.Blocked, want)
+	}
+	if slices.Contains(s.requested("127.0.0.1"), "/private/page") {
+		t.Error("a redirect to a page disallowed by robots.txt was followed")
+	}
+	if got := s.requested("localhost"); len(got) > 0 {
+		t.Errorf("a redirect outside the allowed domains was followed: %q", got)
+	}
+	if len(result.Failed) > 0 {
+		t.Errorf("failed = %v, want none", result.Failed)
+	}
+}
+
+func TestCrawlSitemaps(t *testing.T) {
+	s := newSite(t, "User-agent: *\nDisallow: /private\n", map[string]string{
+		"/sitemap.xml": `<?xml version="1.0"?>
+<sitemapindex>
+  <sitemap><loc>{server}/missing.xml</loc></sitemap>
+  <sitemap><loc>{server}/private/sitemap.xml</loc></sitemap>
+  <sitemap><loc>{server}/pages.xml</loc></sitemap>
+</sitemapindex>`,
+		"/pages.xml": `<?xml version="1.0"?>
+<urlset>
+  <url><loc>{server}/a</loc></url>
+  <url><loc>{server}/b</loc></url>
+</urlset>`,
+		"/private/sitemap.xml": `<urlset><url><loc>{server}/c</loc></url></urlset>`,
+		"/a":                   page("A", "/c"),
+		"/b":                   page("B"),
+		"/c":                   page("C"),
+	})
+
+	result := crawl(t, Config{Sitemaps: []string{s.URL + "/sitemap.xml"}})
+
+	if got, want := pageURLs(result), []string{s.URL + "/a", s.URL + "/b"}; !slices.Equal(got, want) {
+		t.Errorf("pages = %q, want %q", got, want)
+	}
+	if slices.Contains(s.requested("127.0.0.1"), "/private/sitemap.xml") {
+		t.Error("a sitemap disallowed by robots.txt was requested")
+	}
+	if len(result.Failed) > 0 {
+		t.Errorf("failed = %v, want the missing nested sitemap skipped", result.Failed)
+	}
+
+	blocked := crawl(t, Config{Sitemaps: []string{s.URL + "/private/sitemap.xml"}})
+	if want := []string{s.URL + "/private/sitemap.xml"}; !slices.Equal(blocked.Blocked, want) {
+		t.Errorf("blocked = %q, want %q", blocked.Blocked, want)
+	}
+}
+
+func TestCrawlDelay(t *testing.T) {
+	s := newSite(t, "User-agent: *\nCrawl-delay: 0.2\n", map[string]string{
+		"/":  page("Home", "/a"),
+		"/a": page("A"),
+	})
+
+	start := time.Now()
+	result := crawl(t, Config{Seeds: []string{s.URL}, MaxDepth: 1})
+	if len(result.Pages) != 2 {
+		t.Fatalf("got %d pages, want 2", len(result.Pages))
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("two pages of one host took %s, want at least the crawl delay", elapsed)
+	}
+}
+
+func TestParseRobots(t *testing.T) {
+	const robots = `
+User-agent: *
+Disallow: /private
+Allow: /private/open
+Crawl-delay: 2
+
+User-agent: rag-test-crawler
+User-agent: other
+Disallow: /*.pdf$
+Disallow: /tmp
+`
+	tests := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		{agent: "somebot/1.0", path: "/", want: true},
+		{agent: "somebot/1.0", path: "/private/x", want: false},
+		{agent: "somebot/1.0", path: "/private/open/x", want: true},
+		{agent: "rag-test-crawler/1.0", path: "/private/x", want: true},
+		{agent: "rag-test-crawler/1.0", path: "/docs/a.pdf", want: false},
+		{agent: "rag-test-crawler/1.0", path: "/docs/a.pdf?x=1", want: true},
+		{agent: "rag-test-crawler/1.0", path: "/tmp/a", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.agent+tt.path, func(t *testing.T) {
+			rules, err := parseRobots(strings.NewReader(robots), tt.agent)
+			if err != nil {
+				t.Fatalf("parseRobots: %v", err)
+			}
+			if got := rules.allowed(tt.path); got != tt.want {
+				t.Errorf("allowed(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	rules, err := parseRobots(strings.NewReader(robots), "somebot")
+	if err != nil {
+		t.Fatalf("parseRobots: %v", err)
+	}
+	if rules.crawlDelay != 2*time.Second {
+		t.Errorf("crawl delay = %s, want 2s", rules.crawlDelay)
+	}
+}
@@ -0,0 +1,130 @@
+package crawler
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robotsRules holds the robots.txt group that applies to the crawler.
+type robotsRules struct {
+	allow      []string
+	disallow   []string
+	crawlDelay time.Duration
+}
+
+var (
+	allowAll    = &robotsRules{}
+	disallowAll = &robotsRules{disallow: []string{"/"}}
+)
+
+// parseRobots reads robots.txt and keeps the group of the most specific
+// user-agent matching agent, falling back to the "*" group.
+func parseRobots(r io.Reader, agent string) (*robotsRules, error) {
+	agent = strings.ToLower(agent)
+
+	groups := make(map[string]*robotsRules)
+	current := make([]string, 0)
+	inRules := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if inRules {
+				current, inRules = current[:0], false
+			}
+			name := strings.ToLower(value)
+			current = append(current, name)
+			if groups[name] == nil {
+				groups[name] = &robotsRules{}
+			}
+			continue
+		}
+		if len(current) == 0 {
+			continue
+		}
+
+		inRules = true
+		for _, name := range current {
+			group := groups[name]
+			switch key {
+			case "allow":
+				if value != "" {
+					group.allow = append(group.allow, value)
+				}
+			case "disallow":
+				if value != "" {
+					group.disallow = append(group.disallow, value)
+				}
+			case "crawl-delay":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					group.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	best, bestLen := groups["*"], 0
+	for name, group := range groups {
+		if name != "*" && strings.Contains(agent, name) && len(name) > bestLen {
+			best, bestLen = group, len(name)
+		}
+	}
+	if best == nil {
+		return allowAll, nil
+	}
+	return best, nil
+}
+
+// allowed applies the longest matching rule; Allow wins a tie.
+func (r *robotsRules) allowed(path string) bool {
+	allowLen, disallowLen := -1, -1
+	for _, pattern := range r.allow {
+		if matchRobotsPattern(pattern, path) {
+			allowLen = max(allowLen, len(pattern))
+		}
+	}
+	for _, pattern := range r.disallow {
+		if matchRobotsPattern(pattern, path) {
+			disallowLen = max(disallowLen, len(pattern))
+		}
+	}
+	return allowLen >= disallowLen
+}
+
+// matchRobotsPattern matches a path prefix where "*" is any sequence and a
+// trailing "$" anchors the end.
+func matchRobotsPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 && anchored {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	return !anchored || rest == ""
+}
@@ -0,0 +1,65 @@
+package crawler
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// maxSitemapDepth bounds nested sitemap indexes.
+const maxSitemapDepth = 3
+
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapURLs returns the page URLs listed in a sitemap, following sitemap
+// indexes on allowed hosts. A nested sitemap that fails is logged and
+// skipped, so the others still count.
+func (c *Crawler) sitemapURLs(ctx context.Context, sitemapURL string, depth int) ([]string, error) {
+	if depth > maxSitemapDepth {
+		return nil, fmt.Errorf("sitemap %s: nested too deep", sitemapURL)
+	}
+
+	body, _, _, err := c.get(ctx, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc sitemapDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("sitemap %s: %w", sitemapURL, err)
+	}
+
+	urls := make([]string, 0, len(doc.URLs))
+	for _, loc := range doc.URLs {
+		if u := strings.TrimSpace(loc.Loc); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	for _, loc := range doc.Sitemaps {
+		nested := strings.TrimSpace(loc.Loc)
+		if nested == "" || !c.allowedURL(nested) {
+			continue
+		}
+		more, err := c.sitemapURLs(ctx, nested, depth+1)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			slog.Warn("failed to read nested sitemap", slog.String("url", nested), slog.String("error", err.Error()))
+			continue
+		}
+		urls = append(urls, more...)
+	}
+
+	return urls, nil
+}
@@ -0,0 +1,55 @@
+package helpers
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+)
+
+const (
+	shingleSize = 3
+
+	// NearDuplicateDistance is the largest SimHash Hamming distance at which
+	// two texts count as the same passage.
+	NearDuplicateDistance = 3
+)
+
+// SimHash fingerprints the normalized text over word shingles, so texts that
+// differ only in case, whitespace or a few words get close fingerprints.
+func SimHash(text string) uint64 {
+	words := strings.Fields(NormalizeText(text))
+	if len(words) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	size := min(shingleSize, len(words))
+	for i := 0; i+size <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+size], " ")))
+		sum := h.Sum64()
+		for bit := range 64 {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+func IsNearDuplicate(a, b uint64) bool {
+	return HammingDistance(a, b) <= NearDuplicateDistance
+}
+
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
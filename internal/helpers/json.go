@@ -1,11 +1,13 @@
-package rag
+package helpers
 
 import (
 	"encoding/json"
 	"strings"
 )
 
-func decodeJSON(raw string, target any) error {
+// DecodeJSON parses a model response, falling back to the outermost {...}
+// when the model wrapped the object in extra text.
+func DecodeJSON(raw string, target any) error {
 	trimmed := strings.TrimSpace(raw)
 	if err := json.Unmarshal([]byte(trimmed), target); err == nil {
 		return nil
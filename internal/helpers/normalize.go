@@ -0,0 +1,90 @@
+package helpers
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeText produces the matching form of a text: lower case, "ё" folded
+// to "е" and whitespace collapsed. It is meant for embedding inputs and
+// lexical indexes only; stored payloads keep the original text.
+func NormalizeText(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+
+	space := false
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(foldRune(r))
+	}
+
+	return b.String()
+}
+
+// FindOriginal locates fragment inside text ignoring case and whitespace
+// differences and returns the matching part of text as it was written.
+func FindOriginal(text, fragment string) (string, bool) {
+	source := []rune(text)
+	folded := make([]rune, 0, len(source))
+	positions := make([]int, 0, len(source))
+
+	space := false
+	for i, r := range source {
+		if unicode.IsSpace(r) {
+			space = len(folded) > 0
+			continue
+		}
+		if space {
+			folded = append(folded, ' ')
+			positions = append(positions, i-1)
+			space = false
+		}
+		folded = append(folded, foldRune(r))
+		positions = append(positions, i)
+	}
+
+	needle := []rune(NormalizeText(fragment))
+	if len(needle) == 0 {
+		return "", false
+	}
+
+	idx := indexRunes(folded, needle)
+	if idx == -1 {
+		return "", false
+	}
+
+	start := positions[idx]
+	end := positions[idx+len(needle)-1] + 1
+	return string(source[start:end]), true
+}
+
+func foldRune(r rune) rune {
+	r = unicode.ToLower(r)
+	if r == 'ё' {
+		return 'е'
+	}
+	return r
+}
+
+func indexRunes(haystack, needle []rune) int {
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"slices"
+	"strings"
+)
+
+// ParentSection is a larger span of a document made of consecutive chunks
+// under the same heading path. Children are indexes into the chunk list.
+type ParentSection struct {
+	Text        string
+	HeadingPath []string
+	StartOffset int
+	EndOffset   int
+	Children    []int
+}
+
+// GroupSections merges consecutive chunks that share a heading path into
+// sections of at most maxTokens. The section text is cut from the source
+// by the rune offsets of its chunks and carries the heading path.
+func GroupSections(text string, chunks []TextChunk, maxTokens int) []ParentSection {
+	source := []rune(strings.ReplaceAll(text, "\r\n", "\n"))
+
+	sections := make([]ParentSection, 0)
+	for i, chunk := range chunks {
+		if n := len(sections); n > 0 && slices.Equal(sections[n-1].HeadingPath, chunk.HeadingPath) {
+			current := &sections[n-1]
+			start := min(current.StartOffset, chunk.StartOffset)
+			end := max(current.EndOffset, chunk.EndOffset)
+			if EstimateTokens(string(source[start:end])) <= maxTokens {
+				current.StartOffset, current.EndOffset = start, end
+				current.Children = append(current.Children, i)
+				continue
+			}
+		}
+
+		sections = append(sections, ParentSection{
+			HeadingPath: chunk.HeadingPath,
+			StartOffset: chunk.StartOffset,
+			EndOffset:   chunk.EndOffset,
+			Children:    []int{i},
+		})
+	}
+
+	for i := range sections {
+		section := &sections[i]
+		body := strings.TrimSpace(string(source[section.StartOffset:section.EndOffset]))
+		if len(section.Children) == 1 || body == "" {
+			section.Text = chunks[section.Children[0]].Text
+			continue
+		}
+		var b strings.Builder
+		for level, heading := range section.HeadingPath {
+			b.WriteString(strings.Repeat("#", min(level+1, 6)))
+			b.WriteString(" ")
+			b.WriteString(heading)
+			b.WriteString("\n")
+		}
+		b.WriteString(body)
+		section.Text = b.String()
+	}
+
+	return sections
+}
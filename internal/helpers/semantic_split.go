@@ -0,0 +1,259 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultSemanticMinTokens  = 60
+	defaultSemanticMaxTokens  = 400
+	defaultSemanticPercentile = 90
+	defaultSemanticWindow     = 1
+)
+
+// EmbedFunc embeds texts in order; the caller owns batching and rate limits.
+type EmbedFunc func(ctx context.Context, texts []string) ([][]float32, error)
+
+// SemanticOptions tune SplitTextSemantically. BreakpointPercentile selects
+// the distance between neighbouring sentences above which a new chunk
+// starts; Window is the number of neighbours embedded with each sentence.
+type SemanticOptions struct {
+	MinTokens            int
+	MaxTokens            int
+	BreakpointPercentile float64
+	Window               int
+}
+
+func (o SemanticOptions) withDefaults() SemanticOptions {
+	if o.MinTokens <= 0 {
+		o.MinTokens = defaultSemanticMinTokens
+	}
+	if o.MaxTokens <= 0 {
+		o.MaxTokens = defaultSemanticMaxTokens
+	}
+	if o.MinTokens > o.MaxTokens {
+		o.MinTokens = o.MaxTokens
+	}
+	if o.BreakpointPercentile <= 0 || o.BreakpointPercentile > 100 {
+		o.BreakpointPercentile = defaultSemanticPercentile
+	}
+	if o.Window < 0 {
+		o.Window = 0
+	} else if o.Window == 0 {
+		o.Window = defaultSemanticWindow
+	}
+	return o
+}
+
+type sentenceSpan struct {
+	start  int
+	end    int
+	tokens int
+}
+
+// SplitTextSemantically cuts prose where the topic shifts: every sentence is
+// embedded together with its neighbours and a chunk ends where the cosine
+// distance to the next sentence is in the top percentile, as long as the
+// chunk stays within the token limits. Headings and tables are handled as
+// in SplitTextByChunks.
+func SplitTextSemantically(ctx context.Context, text string, embed EmbedFunc, opts SemanticOptions) ([]TextChunk, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	opts = opts.withDefaults()
+
+	sections := parseMarkdownSections(text)
+	sentences := make([][]sentenceSpan, len(sections))
+	inputs := make([]string, 0)
+	for i, section := range sections {
+		if section.table != nil {
+			continue
+		}
+		sentences[i] = splitSentences(section.body)
+		for j := range sentences[i] {
+			inputs = append(inputs, NormalizeText(sentenceWindow(section.body, sentences[i], j, opts.Window)))
+		}
+	}
+
+	vectors := make([][]float32, 0)
+	if len(inputs) > 0 {
+		var err error
+		vectors, err = embed(ctx, inputs)
+		if err != nil {
+			return nil, err
+		}
+		if len(vectors) != len(inputs) {
+			return nil, fmt.Errorf("semantic split: got %d embeddings for %d sentences", len(vectors), len(inputs))
+		}
+	}
+
+	distances := make([][]float64, len(sections))
+	all := make([]float64, 0, len(inputs))
+	next := 0
+	for i := range sections {
+		spans := sentences[i]
+		distances[i] = make([]float64, max(len(spans)-1, 0))
+		for j := 0; j+1 < len(spans); j++ {
+			distances[i][j] = 1 - cosineSimilarity(vectors[next+j], vectors[next+j+1])
+			all = append(all, distances[i][j])
+		}
+		next += len(spans)
+	}
+	threshold := percentile(all, opts.BreakpointPercentile)
+
+	split := make([]TextChunk, 0)
+	for i, section := range sections {
+		if section.table != nil {
+			split = append(split, splitTableRows(section)...)
+			continue
+		}
+
+		prefix := headingLines(section.headings, section.levels)
+		for _, group := range groupSentences(sentences[i], distances[i], threshold, opts) {
+			start, end := group[0].start, group[len(group)-1].end
+			split = append(split, TextChunk{
+				Text:        prefix + section.body[start:end],
+				HeadingPath: copyStrings(section.headings),
+				StartOffset: section.start + start,
+				EndOffset:   section.start + end,
+			})
+		}
+	}
+
+	return finalizeChunks(text, split), nil
+}
+
+// groupSentences closes a group at a breakpoint once it has MinTokens, and
+// always before it would exceed MaxTokens. A short tail joins the previous
+// group when it fits.
+func groupSentences(spans []sentenceSpan, distances []float64, threshold float64, opts SemanticOptions) [][]sentenceSpan {
+	groups := make([][]sentenceSpan, 0)
+	current := make([]sentenceSpan, 0)
+	tokens := 0
+
+	for j, span := range spans {
+		if len(current) > 0 && tokens+span.tokens > opts.MaxTokens {
+			groups = append(groups, current)
+			current, tokens = make([]sentenceSpan, 0), 0
+		}
+		current = append(current, span)
+		tokens += span.tokens
+
+		if j < len(distances) && distances[j] >= threshold && tokens >= opts.MinTokens {
+			groups = append(groups, current)
+			current, tokens = make([]sentenceSpan, 0), 0
+		}
+	}
+	if len(current) > 0 {
+		if n := len(groups); n > 0 && tokens < opts.MinTokens && groupTokens(groups[n-1])+tokens <= opts.MaxTokens {
+			groups[n-1] = append(groups[n-1], current...)
+		} else {
+			groups = append(groups, current)
+		}
+	}
+
+	return groups
+}
+
+func groupTokens(group []sentenceSpan) int {
+	total := 0
+	for _, span := range group {
+		total += span.tokens
+	}
+	return total
+}
+
+// splitSentences returns byte spans of the sentences in body. Line breaks
+// always end a sentence, so list items and paragraphs stay separate.
+func splitSentences(body string) []sentenceSpan {
+	spans := make([]sentenceSpan, 0)
+	add := func(start, end int) {
+		for start < end && isSpaceByte(body[start]) {
+			start++
+		}
+		for end > start && isSpaceByte(body[end-1]) {
+			end--
+		}
+		if start < end {
+			spans = append(spans, sentenceSpan{start: start, end: end, tokens: EstimateTokens(body[start:end])})
+		}
+	}
+
+	start := 0
+	for i := 0; i < len(body); {
+		r, size := utf8.DecodeRuneInString(body[i:])
+		i += size
+
+		if r == '\n' {
+			add(start, i)
+			start = i
+			continue
+		}
+		if !isSentenceEnd(r) {
+			continue
+		}
+
+		end := i
+		for end < len(body) {
+			next, n := utf8.DecodeRuneInString(body[end:])
+			if !isSentenceEnd(next) && !strings.ContainsRune(`"'»”)]`, next) {
+				break
+			}
+			end += n
+		}
+		if end < len(body) && (body[end] == ' ' || body[end] == '\t') {
+			if next, _ := utf8.DecodeRuneInString(strings.TrimLeft(body[end:], " \t")); unicode.IsUpper(next) || unicode.IsDigit(next) || next == '-' || next == '«' {
+				add(start, end)
+				start = end
+			}
+		}
+		i = end
+	}
+	add(start, len(body))
+
+	return spans
+}
+
+func sentenceWindow(body string, spans []sentenceSpan, i, window int) string {
+	from := max(i-window, 0)
+	to := min(i+window, len(spans)-1)
+	return body[spans[from].start:spans[to].end]
+}
+
+func isSentenceEnd(r rune) bool {
+	return r == '.' || r == '!' || r == '?' || r == '…'
+}
+
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n'
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range min(len(a), len(b)) {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// percentile uses nearest-rank; with no values nothing counts as a breakpoint.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return math.Inf(1)
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
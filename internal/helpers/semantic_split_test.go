@@ -0,0 +1,111 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// topicEmbed puts sentences about chairs and about delivery on orthogonal
+// axes, so the only breakpoint is where the topic changes.
+func topicEmbed(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		switch {
+		case strings.Contains(text, "стул"):
+			vectors[i] = []float32{1, 0}
+		case strings.Contains(text, "доставк"):
+			vectors[i] = []float32{0, 1}
+		default:
+			vectors[i] = []float32{1, 1}
+		}
+	}
+	return vectors, nil
+}
+
+func TestSplitTextSemantically(t *testing.T) {
+	const prose = "Офисный стул стоит недорого. Каждый стул собран вручную. Стул выдерживает сто килограммов. " +
+		"Сроки доставки зависят от города. Курьер звонит перед доставкой. Оплата доставки при получении."
+	errEmbed := errors.New("embedding failed")
+
+	tests := []struct {
+		name       string
+		text       string
+		embed      EmbedFunc
+		opts       SemanticOptions
+		wantChunks []string
+		wantErr    error
+	}{
+		{
+			name:  "break at the topic change",
+			text:  "# Магазин\n\n" + prose,
+			embed: topicEmbed,
+			opts:  SemanticOptions{MinTokens: 1, MaxTokens: 1000, Window: -1},
+			wantChunks: []string{
+				"# Магазин\nОфисный стул стоит недорого. Каждый стул собран вручную. Стул выдерживает сто килограммов.",
+				"# Магазин\nСроки доставки зависят от города. Курьер звонит перед доставкой. Оплата доставки при получении.",
+			},
+		},
+		{
+			name:       "short tail joins the previous chunk",
+			text:       prose,
+			embed:      topicEmbed,
+			opts:       SemanticOptions{MinTokens: 1000, MaxTokens: 1000, Window: -1},
+			wantChunks: []string{prose},
+		},
+		{
+			name:  "max tokens forces a break",
+			text:  "Первое предложение без темы. Второе предложение без темы.",
+			embed: topicEmbed,
+			opts:  SemanticOptions{MinTokens: 1, MaxTokens: EstimateTokens("Первое предложение без темы."), Window: -1},
+			wantChunks: []string{
+				"Первое предложение без темы.",
+				"Второе предложение без темы.",
+			},
+		},
+		{
+			name:    "embedding error",
+			text:    prose,
+			embed:   func(context.Context, []string) ([][]float32, error) { return nil, errEmbed },
+			wantErr: errEmbed,
+		},
+		{
+			name:    "missing embeddings",
+			text:    prose,
+			embed:   func(context.Context, []string) ([][]float32, error) { return [][]float32{{1, 0}}, nil },
+			wantErr: errors.New("semantic split: got 1 embeddings for 6 sentences"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := SplitTextSemantically(context.Background(), tt.text, tt.embed, tt.opts)
+			if tt.wantErr != nil {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr.Error()) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SplitTextSemantically: %v", err)
+			}
+			if len(chunks) != len(tt.wantChunks) {
+				t.Fatalf("got %d chunks, want %d: %+v", len(chunks), len(tt.wantChunks), chunks)
+			}
+
+			source := []rune(tt.text)
+			for i, chunk := range chunks {
+				if chunk.Text != tt.wantChunks[i] {
+					t.Errorf("chunk %d = %q, want %q", i, chunk.Text, tt.wantChunks[i])
+				}
+				if chunk.Ordinal != i {
+					t.Errorf("chunk %d has ordinal %d", i, chunk.Ordinal)
+				}
+				if span := string(source[chunk.StartOffset:chunk.EndOffset]); !strings.HasSuffix(chunk.Text, span) {
+					t.Errorf("chunk %d offsets cover %q", i, span)
+				}
+			}
+		})
+	}
+}
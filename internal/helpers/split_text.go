@@ -2,34 +2,334 @@ package helpers
 
 import (
 	"log/slog"
+	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/tmc/langchaingo/textsplitter"
 )
 
-func SplitTextByChunks(text string) ([]string, error) {
-	split, err := textsplitter.NewMarkdownTextSplitter(
-		textsplitter.WithChunkSize(500),
-		textsplitter.WithChunkOverlap(80),
-		textsplitter.WithHeadingHierarchy(true),
-		textsplitter.WithModelName("gpt-5.1"),
-	).SplitText(text)
-	if err != nil {
-		slog.Error("failed to split text", slog.String("err", err.Error()))
-		return nil, err
+const (
+	chunkSize    = 500
+	chunkOverlap = 80
+
+	locateProbeBytes = 48
+
+	// maxRepeatedHeaderRunes caps the table header that splitTableRows
+	// repeats in every chunk; a longer header is only kept in the first one.
+	maxRepeatedHeaderRunes = chunkSize / 4
+)
+
+var (
+	headingPattern        = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+	tableSeparatorPattern = regexp.MustCompile(`^\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?$`)
+)
+
+// TextChunk is a piece of a Markdown document together with its position:
+// StartOffset and EndOffset are rune offsets into the source text.
+type TextChunk struct {
+	Text        string
+	HeadingPath []string
+	Ordinal     int
+	StartOffset int
+	EndOffset   int
+}
+
+type markdownSection struct {
+	headings []string
+	levels   []int
+	body     string
+	start    int
+	table    *markdownTable
+}
+
+type markdownTable struct {
+	header    string
+	separator string
+	rows      []tableRow
+}
+
+type tableRow struct {
+	text  string
+	start int
+	end   int
+}
+
+func SplitTextByChunks(text string) ([]TextChunk, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	split := make([]TextChunk, 0)
+	for _, section := range parseMarkdownSections(text) {
+		if section.table != nil {
+			split = append(split, splitTableRows(section)...)
+			continue
+		}
+
+		chunks, err := textsplitter.NewMarkdownTextSplitter(
+			textsplitter.WithChunkSize(chunkSize),
+			textsplitter.WithChunkOverlap(chunkOverlap),
+			textsplitter.WithModelName("gpt-5.1"),
+			// Without it the splitter drops fenced code altogether.
+			textsplitter.WithCodeBlocks(true),
+		).SplitText(section.body)
+		if err != nil {
+			slog.Error("failed to split text", slog.String("err", err.Error()))
+			return nil, err
+		}
+
+		prefix := headingLines(section.headings, section.levels)
+		cursor := 0
+		for _, chunk := range chunks {
+			if strings.TrimSpace(chunk) == "" {
+				continue
+			}
+
+			start, end := locateChunk(section.body, chunk, cursor)
+			cursor = start
+			split = append(split, TextChunk{
+				Text:        prefix + chunk,
+				HeadingPath: copyStrings(section.headings),
+				StartOffset: section.start + start,
+				EndOffset:   section.start + end,
+			})
+		}
 	}
 
+	return finalizeChunks(text, split), nil
+}
+
+// finalizeChunks numbers the chunks and turns their byte offsets into rune
+// offsets of text.
+func finalizeChunks(text string, split []TextChunk) []TextChunk {
 	split = removeUnusedChunks(split)
+	for i := range split {
+		split[i].Ordinal = i
+		split[i].StartOffset = utf8.RuneCountInString(text[:split[i].StartOffset])
+		split[i].EndOffset = utf8.RuneCountInString(text[:split[i].EndOffset])
+	}
+	return split
+}
+
+// parseMarkdownSections cuts the document into runs of prose and tables,
+// remembering the heading path each of them sits under. Offsets are in bytes.
+func parseMarkdownSections(text string) []markdownSection {
+	lines := strings.Split(text, "\n")
+	lineStarts := make([]int, len(lines))
+	offset := 0
+	for i, line := range lines {
+		lineStarts[i] = offset
+		offset += len(line) + 1
+	}
+	lineEnd := func(i int) int {
+		return lineStarts[i] + len(lines[i])
+	}
+
+	sections := make([]markdownSection, 0)
+	headings := make([]string, 0)
+	levels := make([]int, 0)
+	bodyFirst, bodyLast := -1, -1
+	inFence := false
+
+	addBodyLine := func(i int) {
+		if strings.TrimSpace(lines[i]) == "" {
+			return
+		}
+		if bodyFirst == -1 {
+			bodyFirst = i
+		}
+		bodyLast = i
+	}
+	flush := func() {
+		if bodyFirst == -1 {
+			return
+		}
+		raw := text[lineStarts[bodyFirst]:lineEnd(bodyLast)]
+		trimmed := strings.TrimLeft(raw, " \t")
+		sections = append(sections, markdownSection{
+			headings: copyStrings(headings),
+			levels:   copyInts(levels),
+			body:     strings.TrimRight(trimmed, " \t"),
+			start:    lineStarts[bodyFirst] + len(raw) - len(trimmed),
+		})
+		bodyFirst, bodyLast = -1, -1
+	}
+
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			addBodyLine(i)
+			continue
+		}
+		if inFence {
+			addBodyLine(i)
+			continue
+		}
+
+		if m := headingPattern.FindStringSubmatch(trimmed); m != nil {
+			flush()
+			level := len(m[1])
+			for len(levels) > 0 && levels[len(levels)-1] >= level {
+				levels = levels[:len(levels)-1]
+				headings = headings[:len(headings)-1]
+			}
+			levels = append(levels, level)
+			headings = append(headings, m[2])
+			continue
+		}
+
+		if isTableRow(trimmed) && i+1 < len(lines) && tableSeparatorPattern.MatchString(strings.TrimSpace(lines[i+1])) {
+			flush()
+			tableStart := lineStarts[i]
+			table := &markdownTable{
+				header:    trimmed,
+				separator: strings.TrimSpace(lines[i+1]),
+			}
+			i += 2
+			for ; i < len(lines) && isTableRow(strings.TrimSpace(lines[i])); i++ {
+				table.rows = append(table.rows, tableRow{
+					text:  strings.TrimSpace(lines[i]),
+					start: lineStarts[i],
+					end:   lineEnd(i),
+				})
+			}
+			i--
+			sections = append(sections, markdownSection{
+				headings: copyStrings(headings),
+				levels:   copyInts(levels),
+				start:    tableStart,
+				table:    table,
+			})
+			continue
+		}
+
+		addBodyLine(i)
+	}
+	flush()
+
+	return sections
+}
+
+// splitTableRows groups table rows into chunks that each repeat the heading
+// path and, while it is short, the table header, so a single row stays
+// self-describing without a wide header crowding out the rows.
+func splitTableRows(section markdownSection) []TextChunk {
+	table := section.table
+	headings := headingLines(section.headings, section.levels)
+	header := table.header + "\n" + table.separator
+	if len(table.rows) == 0 {
+		return []TextChunk{{
+			Text:        headings + header,
+			HeadingPath: copyStrings(section.headings),
+			StartOffset: section.start,
+			EndOffset:   section.start,
+		}}
+	}
+	repeatHeader := utf8.RuneCountInString(header) <= maxRepeatedHeaderRunes
+
+	chunks := make([]TextChunk, 0)
+	var current strings.Builder
+	var first, last tableRow
+	rowsInChunk := 0
+	emit := func() {
+		chunks = append(chunks, TextChunk{
+			Text:        current.String(),
+			HeadingPath: copyStrings(section.headings),
+			StartOffset: first.start,
+			EndOffset:   last.end,
+		})
+		current.Reset()
+		rowsInChunk = 0
+	}
+
+	for _, row := range table.rows {
+		if rowsInChunk > 0 && utf8.RuneCountInString(current.String())+1+utf8.RuneCountInString(row.text) > chunkSize {
+			emit()
+		}
+		if rowsInChunk == 0 {
+			current.WriteString(headings)
+			if len(chunks) == 0 || repeatHeader {
+				current.WriteString(header)
+			}
+			first = row
+		}
+		if current.Len() > 0 && !strings.HasSuffix(current.String(), "\n") {
+			current.WriteString("\n")
+		}
+		current.WriteString(row.text)
+		last = row
+		rowsInChunk++
+	}
+	if rowsInChunk > 0 {
+		emit()
+	}
+
+	return chunks
+}
+
+// locateChunk finds where a splitter output starts inside body, searching
+// from the previous chunk start because chunks overlap. The splitter may
+// reformat Markdown, so a miss falls back to the rest of the body.
+func locateChunk(body, chunk string, from int) (int, int) {
+	probe := strings.TrimSpace(chunk)
+	if i := strings.IndexByte(probe, '\n'); i != -1 {
+		probe = probe[:i]
+	}
+	if len(probe) > locateProbeBytes {
+		cut := locateProbeBytes
+		for cut > 0 && !utf8.RuneStart(probe[cut]) {
+			cut--
+		}
+		probe = probe[:cut]
+	}
+
+	if probe != "" {
+		if idx := strings.Index(body[from:], probe); idx != -1 {
+			start := from + idx
+			return start, min(start+len(strings.TrimSpace(chunk)), len(body))
+		}
+	}
+
+	return from, len(body)
+}
+
+func headingLines(headings []string, levels []int) string {
+	var b strings.Builder
+	for i, heading := range headings {
+		b.WriteString(strings.Repeat("#", levels[i]))
+		b.WriteString(" ")
+		b.WriteString(heading)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func copyStrings(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]string, len(values))
+	copy(out, values)
+	return out
+}
+
+func copyInts(values []int) []int {
+	out := make([]int, len(values))
+	copy(out, values)
+	return out
+}
 
-	return split, nil
+func isTableRow(line string) bool {
+	return strings.HasPrefix(line, "|") && strings.Count(line, "|") >= 2
 }
 
-func removeUnusedChunks(split []string) []string {
+func removeUnusedChunks(split []TextChunk) []TextChunk {
 	if len(split) == 0 || len(split) == 1 {
 		return split
 	}
 
-	if strings.Contains(split[len(split)-2], split[len(split)-1]) {
+	if strings.Contains(split[len(split)-2].Text, split[len(split)-1].Text) {
 		return removeUnusedChunks(split[:len(split)-1])
 	}
 
@@ -0,0 +1,227 @@
+package helpers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitTextByChunks(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		wantPaths [][]string
+	}{
+		{name: "empty", text: "  \n\n"},
+		{
+			name:      "prose without headings",
+			text:      "Первый абзац текста.\n\nВторой абзац.",
+			wantPaths: [][]string{{}},
+		},
+		{
+			name:      "nested headings",
+			text:      "# Каталог\n\nВступление.\n\n## Стулья\n\nСтул офисный.\n\n## Столы\n\nСтол письменный.\n",
+			wantPaths: [][]string{{"Каталог"}, {"Каталог", "Стулья"}, {"Каталог", "Столы"}},
+		},
+		{
+			name:      "sibling heading closes the section",
+			text:      "# A\n\n## B\n\nтекст b\n\n# C\n\nтекст c\n",
+			wantPaths: [][]string{{"A", "B"}, {"C"}},
+		},
+		{
+			name:      "heading inside a code fence",
+			text:      "# Код\n\n```sh\n# not a heading\necho ok\n```\n",
+			wantPaths: [][]string{{"Код"}},
+		},
+		{
+			name:      "windows line endings",
+			text:      "# Заголовок\r\n\r\nТекст.\r\n",
+			wantPaths: [][]string{{"Заголовок"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := SplitTextByChunks(tt.text)
+			if err != nil {
+				t.Fatalf("SplitTextByChunks: %v", err)
+			}
+			if len(chunks) != len(tt.wantPaths) {
+				t.Fatalf("got %d chunks, want %d: %+v", len(chunks), len(tt.wantPaths), chunks)
+			}
+
+			source := []rune(strings.ReplaceAll(tt.text, "\r\n", "\n"))
+			for i, chunk := range chunks {
+				if chunk.Ordinal != i {
+					t.Errorf("chunk %d has ordinal %d", i, chunk.Ordinal)
+				}
+				if !slices.Equal(chunk.HeadingPath, tt.wantPaths[i]) {
+					t.Errorf("chunk %d heading path = %q, want %q", i, chunk.HeadingPath, tt.wantPaths[i])
+				}
+				if chunk.StartOffset < 0 || chunk.StartOffset >= chunk.EndOffset || chunk.EndOffset > len(source) {
+					t.Fatalf("chunk %d has offsets [%d, %d) in %d runes", i, chunk.StartOffset, chunk.EndOffset, len(source))
+				}
+				if !startsAtOffset(chunk, source) {
+					t.Errorf("chunk %d text %q does not start at offset %d", i, chunk.Text, chunk.StartOffset)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitLongProse(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("# Доставка\n\n")
+	for i := range 60 {
+		fmt.Fprintf(&b, "Предложение номер %d о сроках и условиях доставки.\n\n", i)
+	}
+	text := b.String()
+
+	chunks, err := SplitTextByChunks(text)
+	if err != nil {
+		t.Fatalf("SplitTextByChunks: %v", err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want the section split", len(chunks))
+	}
+	source := []rune(text)
+	for i, chunk := range chunks {
+		if !strings.HasPrefix(chunk.Text, "# Доставка\n") {
+			t.Errorf("chunk %d does not start with the heading path: %q", i, chunk.Text)
+		}
+		if i > 0 && chunk.StartOffset < chunks[i-1].StartOffset {
+			t.Errorf("chunk %d starts at %d, before chunk %d at %d", i, chunk.StartOffset, i-1, chunks[i-1].StartOffset)
+		}
+		if !startsAtOffset(chunk, source) {
+			t.Errorf("chunk %d text %q does not start at offset %d", i, chunk.Text, chunk.StartOffset)
+		}
+	}
+	if last := chunks[len(chunks)-1]; !strings.Contains(last.Text, "номер 59") {
+		t.Errorf("last chunk misses the end of the section: %q", last.Text)
+	}
+}
+
+func TestSplitCodeFences(t *testing.T) {
+	shell := "```sh\n# not a heading\necho ok\n```"
+	program := "```go\nfunc main() {\n\n\tfmt.Println(\"| a | b |\")\n}\n```"
+	tilde := "~~~\n## inside\n\n| --- | --- |\n~~~"
+
+	tests := []struct {
+		name string
+		text string
+		// fence is the block as the chunk renders it.
+		fence string
+	}{
+		{name: "only a code block", text: "# Код\n\n" + shell + "\n", fence: shell},
+		{name: "blank line inside the block", text: "# Код\n\nТекст до.\n\n" + program + "\n\nТекст после.\n", fence: program},
+		{
+			name:  "tilde fence with heading and table lines",
+			text:  "# Код\n\n" + tilde + "\n",
+			fence: "```\n## inside\n\n| --- | --- |\n```",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := SplitTextByChunks(tt.text)
+			if err != nil {
+				t.Fatalf("SplitTextByChunks: %v", err)
+			}
+			whole := 0
+			for _, chunk := range chunks {
+				if !slices.Equal(chunk.HeadingPath, []string{"Код"}) {
+					t.Errorf("chunk %q has heading path %q", chunk.Text, chunk.HeadingPath)
+				}
+				if strings.Contains(chunk.Text, tt.fence) {
+					whole++
+				}
+			}
+			if whole != 1 {
+				t.Errorf("code block found whole in %d chunks, want 1: %+v", whole, chunks)
+			}
+		})
+	}
+}
+
+// startsAtOffset reports whether the first line of the chunk body, after
+// the repeated heading path, is found in source at the chunk start. The
+// splitter may reflow blank lines, so only the start is exact.
+func startsAtOffset(chunk TextChunk, source []rune) bool {
+	body := chunk.Text
+	for range chunk.HeadingPath {
+		_, body, _ = strings.Cut(body, "\n")
+	}
+	first, _, _ := strings.Cut(strings.TrimSpace(body), "\n")
+	return strings.HasPrefix(string(source[chunk.StartOffset:]), first)
+}
+
+func TestSplitTableRows(t *testing.T) {
+	table := func(header string, rows int, cell string) string {
+		var b strings.Builder
+		b.WriteString("# Prices\n\n" + header + "\n| --- | --- |\n")
+		for i := range rows {
+			fmt.Fprintf(&b, "| item %d | %s |\n", i, cell)
+		}
+		return b.String()
+	}
+	shortHeader := "| Name | Price |"
+	wideHeader := "| " + strings.Repeat("name ", 30) + "| " + strings.Repeat("price ", 30) + "|"
+
+	tests := []struct {
+		name        string
+		text        string
+		wantChunks  int
+		wantHeaders int
+	}{
+		{
+			name:        "short header in every chunk",
+			text:        table(shortHeader, 40, strings.Repeat("x", 40)),
+			wantChunks:  5,
+			wantHeaders: 5,
+		},
+		{
+			name:        "wide header only in the first chunk",
+			text:        table(wideHeader, 40, strings.Repeat("x", 40)),
+			wantChunks:  6,
+			wantHeaders: 1,
+		},
+		{
+			name:        "table in one chunk",
+			text:        table(wideHeader, 2, "x"),
+			wantChunks:  1,
+			wantHeaders: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := SplitTextByChunks(tt.text)
+			if err != nil {
+				t.Fatalf("SplitTextByChunks: %v", err)
+			}
+			if len(chunks) != tt.wantChunks {
+				t.Fatalf("got %d chunks, want %d", len(chunks), tt.wantChunks)
+			}
+
+			header := strings.SplitN(strings.TrimPrefix(tt.text, "# Prices\n\n"), "\n", 2)[0]
+			headers := 0
+			rows := 0
+			for i, chunk := range chunks {
+				if !strings.HasPrefix(chunk.Text, "# Prices\n|") {
+					t.Errorf("chunk %d does not start with the heading path: %q", i, chunk.Text)
+				}
+				if strings.Contains(chunk.Text, header) {
+					headers++
+				}
+				rows += strings.Count(chunk.Text, "| item ")
+			}
+			if headers != tt.wantHeaders {
+				t.Errorf("header repeated in %d chunks, want %d", headers, tt.wantHeaders)
+			}
+			if want := strings.Count(tt.text, "| item "); rows != want {
+				t.Errorf("chunks hold %d rows, want %d", rows, want)
+			}
+		})
+	}
+}
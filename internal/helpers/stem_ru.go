@@ -0,0 +1,184 @@
+package helpers
+
+import (
+	"sort"
+	"strings"
+)
+
+// Suffix groups of the Snowball Russian stemmer. The first list of a group
+// only matches when the ending follows "а" or "я", which is kept.
+var (
+	ruPerfectiveGerund = newSuffixSet(
+		[]string{"в", "вши", "вшись"},
+		[]string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"},
+	)
+	ruAdjective = newSuffixSet(nil, []string{
+		"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+		"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+	})
+	ruParticiple = newSuffixSet(
+		[]string{"ем", "нн", "вш", "ющ", "щ"},
+		[]string{"ивш", "ывш", "ующ"},
+	)
+	ruReflexive = newSuffixSet(nil, []string{"ся", "сь"})
+	ruVerb      = newSuffixSet(
+		[]string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно"},
+		[]string{
+			"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен",
+			"ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю",
+		},
+	)
+	ruNoun = newSuffixSet(nil, []string{
+		"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й",
+		"иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я",
+	})
+	ruSuperlative  = newSuffixSet(nil, []string{"ейш", "ейше"})
+	ruDerivational = newSuffixSet(nil, []string{"ост", "ость"})
+)
+
+const ruVowels = "аеиоуыэюя"
+
+type suffix struct {
+	runes    []rune
+	afterAYa bool
+}
+
+// suffixSet is sorted longest first: the longest ending wins, as in
+// Snowball's "among".
+type suffixSet []suffix
+
+func newSuffixSet(afterAYa, plain []string) suffixSet {
+	set := make(suffixSet, 0, len(afterAYa)+len(plain))
+	for _, s := range afterAYa {
+		set = append(set, suffix{runes: []rune(s), afterAYa: true})
+	}
+	for _, s := range plain {
+		set = append(set, suffix{runes: []rune(s)})
+	}
+	sort.SliceStable(set, func(i, j int) bool { return len(set[i].runes) > len(set[j].runes) })
+	return set
+}
+
+// StemRussian applies the Snowball Russian stemmer to a lower-case word;
+// "ё" must already be folded to "е".
+func StemRussian(word string) string {
+	w := []rune(word)
+	rv, r2 := ruRegions(w)
+	if rv >= len(w) {
+		return word
+	}
+
+	// Step 1.
+	if n, ok := ruPerfectiveGerund.match(w, rv); ok {
+		w = w[:len(w)-n]
+	} else {
+		if n, ok := ruReflexive.match(w, rv); ok {
+			w = w[:len(w)-n]
+		}
+		if n, ok := ruAdjective.match(w, rv); ok {
+			w = w[:len(w)-n]
+			if n, ok := ruParticiple.match(w, rv); ok {
+				w = w[:len(w)-n]
+			}
+		} else if n, ok := ruVerb.match(w, rv); ok {
+			w = w[:len(w)-n]
+		} else if n, ok := ruNoun.match(w, rv); ok {
+			w = w[:len(w)-n]
+		}
+	}
+
+	// Step 2.
+	if len(w) > rv && w[len(w)-1] == 'и' {
+		w = w[:len(w)-1]
+	}
+
+	// Step 3.
+	if n, ok := ruDerivational.match(w, r2); ok {
+		w = w[:len(w)-n]
+	}
+
+	// Step 4.
+	switch {
+	case hasRuneSuffix(w, rv, "нн"):
+		w = w[:len(w)-1]
+	default:
+		if n, ok := ruSuperlative.match(w, rv); ok {
+			w = w[:len(w)-n]
+			if hasRuneSuffix(w, rv, "нн") {
+				w = w[:len(w)-1]
+			}
+		} else if len(w) > rv && w[len(w)-1] == 'ь' {
+			w = w[:len(w)-1]
+		}
+	}
+
+	return string(w)
+}
+
+// match returns the length of the longest ending of w inside the region
+// starting at from; an "after а/я" ending fails when that letter is missing.
+func (s suffixSet) match(w []rune, from int) (int, bool) {
+	for _, suf := range s {
+		n := len(suf.runes)
+		if len(w)-n < from || !hasRunes(w, suf.runes) {
+			continue
+		}
+		if suf.afterAYa {
+			i := len(w) - n - 1
+			if i < from || (w[i] != 'а' && w[i] != 'я') {
+				return 0, false
+			}
+		}
+		return n, true
+	}
+	return 0, false
+}
+
+func hasRunes(w, suffix []rune) bool {
+	if len(suffix) > len(w) {
+		return false
+	}
+	offset := len(w) - len(suffix)
+	for i, r := range suffix {
+		if w[offset+i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+func hasRuneSuffix(w []rune, from int, suffix string) bool {
+	s := []rune(suffix)
+	return len(w)-len(s) >= from && hasRunes(w, s)
+}
+
+// ruRegions returns the start of RV, the part after the first vowel, and
+// of R2, the region R1 of R1.
+func ruRegions(w []rune) (int, int) {
+	isVowel := func(r rune) bool { return strings.ContainsRune(ruVowels, r) }
+
+	rv := len(w)
+	for i, r := range w {
+		if isVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+
+	r1 := len(w)
+	for i := 1; i < len(w); i++ {
+		if !isVowel(w[i]) && isVowel(w[i-1]) {
+			r1 = i + 1
+			break
+		}
+	}
+	r2 := len(w)
+	for i := r1 + 1; i < len(w); i++ {
+		if !isVowel(w[i]) && isVowel(w[i-1]) {
+			r2 = i + 1
+			break
+		}
+	}
+
+	return rv, r2
+}
@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"strings"
+	"unicode"
+)
+
+// russianStopWords are function words that carry no meaning for lexical
+// search; the list follows the Snowball one.
+var russianStopWords = toSet(strings.Fields(`
+	и в во не что он на я с со как а то все она так его но да ты к у же вы за бы по
+	только ее мне было вот от меня еще нет о из ему теперь когда даже ну ли если уже или
+	ни быть был него до вас уж вам ведь там потом себя ей может они тут где есть надо ней
+	для мы тебя их чем была сам чтоб без чего раз тоже себе под будет ж тогда кто этот того
+	потому этого какой ним здесь этом почти мой тем чтобы нее были куда зачем всех при об
+	хоть после над тот через эти нас про всего них какая разве эту моя свою этой перед
+	чуть том такой им всю между
+`))
+
+// Tokenize splits text into lexical search terms: lower-case words with
+// "ё" folded to "е", Cyrillic words stemmed and stop words dropped. Words
+// joined by "-", ".", "/" or "_", like the SKU "AB-1234" or the price
+// "1.299", are kept whole and also split into their parts.
+func Tokenize(text string) []string {
+	runes := []rune(strings.ReplaceAll(strings.ToLower(text), "ё", "е"))
+
+	terms := make([]string, 0)
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := string(runes[start:end])
+		start = -1
+
+		parts := strings.FieldsFunc(word, isTokenJoiner)
+		if len(parts) > 1 {
+			terms = append(terms, word)
+		}
+		for _, part := range parts {
+			if term := normalizeTerm(part); term != "" {
+				terms = append(terms, term)
+			}
+		}
+	}
+
+	for i, r := range runes {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start < 0 {
+				start = i
+			}
+		case isTokenJoiner(r) && start >= 0 && i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1])):
+			// Keeps the token going.
+		default:
+			flush(i)
+		}
+	}
+	flush(len(runes))
+
+	return terms
+}
+
+func normalizeTerm(word string) string {
+	if _, ok := russianStopWords[word]; ok {
+		return ""
+	}
+	for _, r := range word {
+		if !unicode.Is(unicode.Cyrillic, r) {
+			return word
+		}
+	}
+	return StemRussian(word)
+}
+
+func isTokenJoiner(r rune) bool {
+	return r == '-' || r == '.' || r == '/' || r == '_'
+}
+
+func toSet(words []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		set[word] = struct{}{}
+	}
+	return set
+}
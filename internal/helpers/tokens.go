@@ -0,0 +1,9 @@
+package helpers
+
+import "unicode/utf8"
+
+// EstimateTokens gives a conservative token count for batching requests
+// without loading a BPE vocabulary; Cyrillic text averages about two runes per token.
+func EstimateTokens(text string) int {
+	return utf8.RuneCountInString(text)/2 + 1
+}
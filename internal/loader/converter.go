@@ -0,0 +1,29 @@
+package loader
+
+import "context"
+
+type Converter interface {
+	ConvertOneFileToMarkdown(filePath string) (string, error)
+}
+
+type ConverterLoader struct {
+	name      string
+	converter Converter
+}
+
+func NewConverterLoader(name string, converter Converter) *ConverterLoader {
+	return &ConverterLoader{name: name, converter: converter}
+}
+
+func (l *ConverterLoader) Name() string {
+	return l.name
+}
+
+func (l *ConverterLoader) Load(_ context.Context, path string) (Document, error) {
+	markdown, err := l.converter.ConvertOneFileToMarkdown(path)
+	if err != nil {
+		return Document{}, err
+	}
+
+	return Document{Markdown: markdown}, nil
+}
@@ -0,0 +1,50 @@
+package loader
+
+import (
+	"context"
+	"encoding/csv"
+	"strings"
+)
+
+type CSVLoader struct{}
+
+func NewCSVLoader() *CSVLoader {
+	return &CSVLoader{}
+}
+
+func (l *CSVLoader) Name() string {
+	return "csv"
+}
+
+func (l *CSVLoader) Load(_ context.Context, path string) (Document, error) {
+	text, err := readUTF8(path)
+	if err != nil {
+		return Document{}, err
+	}
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = detectDelimiter(text)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return Document{}, err
+	}
+
+	return Document{Markdown: markdownTable(trimEmptyRows(rows))}, nil
+}
+
+// detectDelimiter guesses the separator from the header line; spreadsheet
+// exports with a Russian locale use semicolons instead of commas.
+func detectDelimiter(text string) rune {
+	header, _, _ := strings.Cut(text, "\n")
+
+	best, bestCount := ',', 0
+	for _, candidate := range []rune{',', ';', '\t'} {
+		if count := strings.Count(header, string(candidate)); count > bestCount {
+			best, bestCount = candidate, count
+		}
+	}
+	return best
+}
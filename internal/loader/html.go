@@ -0,0 +1,347 @@
+package loader
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+type HTMLLoader struct{}
+
+func NewHTMLLoader() *HTMLLoader {
+	return &HTMLLoader{}
+}
+
+func (l *HTMLLoader) Name() string {
+	return "html"
+}
+
+func (l *HTMLLoader) Load(_ context.Context, path string) (Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Document{}, err
+	}
+	defer f.Close()
+
+	page, err := ParseHTML(f)
+	if err != nil {
+		return Document{}, err
+	}
+
+	return Document{Title: page.Title, Markdown: page.Markdown}, nil
+}
+
+type HTMLPage struct {
+	Title    string
+	Markdown string
+	Links    []string
+}
+
+var skippedHTMLElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+	"svg":      true,
+	"head":     true,
+	"nav":      true,
+	"footer":   true,
+	"iframe":   true,
+	"form":     true,
+}
+
+var blockHTMLElements = map[string]bool{
+	"p":          true,
+	"div":        true,
+	"section":    true,
+	"article":    true,
+	"main":       true,
+	"header":     true,
+	"aside":      true,
+	"blockquote": true,
+	"pre":        true,
+	"dl":         true,
+	"dt":         true,
+	"dd":         true,
+	"figure":     true,
+	"figcaption": true,
+	"hr":         true,
+}
+
+// ParseHTML converts an HTML page to Markdown; it also returns the raw href
+// of every link so crawlers can follow them. The page is parsed the way a
+// browser does, so unclosed and void elements and script bodies are fine.
+func ParseHTML(r io.Reader) (HTMLPage, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return HTMLPage{}, err
+	}
+
+	c := &htmlConverter{}
+	c.sink = &c.inline
+	c.walk(root)
+	c.flush()
+
+	var b strings.Builder
+	for i, blk := range c.blocks {
+		if i > 0 {
+			if blk.listItem && c.blocks[i-1].listItem {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(blk.text)
+	}
+
+	return HTMLPage{
+		Title:    strings.Join(strings.Fields(c.title.String()), " "),
+		Markdown: b.String(),
+		Links:    c.links,
+	}, nil
+}
+
+type htmlBlock struct {
+	text     string
+	listItem bool
+}
+
+type htmlConverter struct {
+	blocks []htmlBlock
+	links  []string
+
+	inline   strings.Builder
+	cell     strings.Builder
+	sink     *strings.Builder
+	prefix   string
+	listItem bool
+
+	title   strings.Builder
+	inTitle bool
+
+	skipDepth  int
+	preDepth   int
+	lists      []bool
+	hrefs      []string
+	linkStarts []int
+
+	tableDepth int
+	rows       [][]string
+	row        []string
+	inCell     bool
+}
+
+func (c *htmlConverter) walk(n *html.Node) {
+	switch n.Type {
+	case html.ElementNode:
+		c.start(n.Data, n.Attr)
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			c.walk(child)
+		}
+		c.end(n.Data)
+	case html.TextNode:
+		c.text(n.Data)
+	case html.DocumentNode:
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			c.walk(child)
+		}
+	}
+}
+
+func (c *htmlConverter) start(name string, attrs []html.Attribute) {
+	if name == "title" {
+		c.inTitle = true
+		return
+	}
+	if c.skipDepth > 0 || skippedHTMLElements[name] {
+		c.skipDepth++
+		return
+	}
+
+	switch {
+	case len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6':
+		c.flush()
+		c.prefix = strings.Repeat("#", int(name[1]-'0')) + " "
+	case name == "ul" || name == "ol":
+		c.flush()
+		c.lists = append(c.lists, name == "ol")
+	case name == "li":
+		c.flush()
+		depth := max(len(c.lists)-1, 0)
+		marker := "- "
+		if len(c.lists) > 0 && c.lists[len(c.lists)-1] {
+			marker = "1. "
+		}
+		c.prefix = strings.Repeat("  ", depth) + marker
+		c.listItem = true
+	case name == "table":
+		c.tableDepth++
+		if c.tableDepth == 1 {
+			c.flush()
+			c.rows = nil
+		}
+	case name == "tr" && c.tableDepth == 1:
+		c.row = nil
+	case (name == "td" || name == "th") && c.tableDepth == 1:
+		c.inCell = true
+		c.cell.Reset()
+		c.sink = &c.cell
+	case name == "br":
+		c.sink.WriteString("\n")
+	case name == "strong" || name == "b":
+		c.sink.WriteString("**")
+	case name == "a":
+		href := ""
+		for _, attr := range attrs {
+			if attr.Namespace == "" && attr.Key == "href" {
+				href = strings.TrimSpace(attr.Val)
+			}
+		}
+		if href != "" {
+			c.links = append(c.links, href)
+		}
+		c.hrefs = append(c.hrefs, href)
+		c.linkStarts = append(c.linkStarts, c.sink.Len())
+	case name == "pre":
+		c.flush()
+		c.preDepth++
+	case blockHTMLElements[name]:
+		if !c.inCell {
+			c.flush()
+		}
+	}
+}
+
+func (c *htmlConverter) end(name string) {
+	if name == "title" {
+		c.inTitle = false
+		return
+	}
+	if c.skipDepth > 0 {
+		c.skipDepth--
+		return
+	}
+
+	switch {
+	case len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6':
+		c.flush()
+	case name == "ul" || name == "ol":
+		c.flush()
+		if len(c.lists) > 0 {
+			c.lists = c.lists[:len(c.lists)-1]
+		}
+	case name == "li":
+		c.flush()
+	case name == "table":
+		if c.tableDepth == 1 {
+			if table := markdownTable(trimEmptyRows(c.rows)); table != "" {
+				c.blocks = append(c.blocks, htmlBlock{text: table})
+			}
+			c.rows = nil
+		}
+		c.tableDepth = max(c.tableDepth-1, 0)
+	case name == "tr" && c.tableDepth == 1:
+		if len(c.row) > 0 {
+			c.rows = append(c.rows, c.row)
+		}
+		c.row = nil
+	case (name == "td" || name == "th") && c.tableDepth == 1:
+		c.row = append(c.row, strings.TrimSpace(c.cell.String()))
+		c.inCell = false
+		c.sink = &c.inline
+	case name == "strong" || name == "b":
+		c.sink.WriteString("**")
+	case name == "a":
+		if len(c.hrefs) == 0 {
+			return
+		}
+		href := c.hrefs[len(c.hrefs)-1]
+		start := c.linkStarts[len(c.linkStarts)-1]
+		c.hrefs = c.hrefs[:len(c.hrefs)-1]
+		c.linkStarts = c.linkStarts[:len(c.linkStarts)-1]
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+			return
+		}
+
+		current := c.sink.String()
+		if start > len(current) {
+			return
+		}
+		label := strings.TrimSpace(current[start:])
+		if label == "" {
+			return
+		}
+		c.sink.Reset()
+		c.sink.WriteString(current[:start])
+		c.sink.WriteString("[" + label + "](" + href + ")")
+	case name == "pre":
+		c.flush()
+		c.preDepth = max(c.preDepth-1, 0)
+	case blockHTMLElements[name]:
+		if !c.inCell {
+			c.flush()
+		}
+	}
+}
+
+func (c *htmlConverter) text(value string) {
+	if c.inTitle {
+		c.title.WriteString(value)
+		return
+	}
+	if c.skipDepth > 0 {
+		return
+	}
+	if c.preDepth > 0 {
+		c.sink.WriteString(value)
+		return
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		if value != "" {
+			c.sink.WriteString(" ")
+		}
+		return
+	}
+
+	if startsWithSpace(value) {
+		c.sink.WriteString(" ")
+	}
+	c.sink.WriteString(strings.Join(fields, " "))
+	if endsWithSpace(value) {
+		c.sink.WriteString(" ")
+	}
+}
+
+func (c *htmlConverter) flush() {
+	text := c.inline.String()
+	c.inline.Reset()
+
+	if c.preDepth == 0 {
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			lines[i] = strings.Join(strings.Fields(line), " ")
+		}
+		text = strings.Join(lines, "\n")
+	}
+	text = strings.Trim(text, " \n")
+	text = strings.ReplaceAll(text, "****", "")
+
+	if text != "" && text != "**" {
+		c.blocks = append(c.blocks, htmlBlock{text: c.prefix + text, listItem: c.listItem})
+	}
+	c.prefix = ""
+	c.listItem = false
+}
+
+func startsWithSpace(value string) bool {
+	return value != "" && strings.TrimLeft(value, " \t\r\n") != value
+}
+
+func endsWithSpace(value string) bool {
+	return value != "" && strings.TrimRight(value, " \t\r\n") != value
+}
@@ -0,0 +1,73 @@
+package loader
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		title    string
+		markdown string
+		links    []string
+	}{
+		{
+			name:     "script and style bodies",
+			input:    `<html><head><title> My  Page </title><script>if (a < b && c) {}</script><style>p > a { color: red }</style></head><body><h1>Intro</h1><p>text</p></body></html>`,
+			title:    "My Page",
+			markdown: "# Intro\n\ntext",
+		},
+		{
+			name:     "void elements",
+			input:    `<p>a<br>b</p><img src=x><hr><p>tail`,
+			markdown: "a\nb\n\ntail",
+		},
+		{
+			name:     "unquoted attributes",
+			input:    `<p>see <a href=/docs/x.html>the docs</a> and <a href="#top">top</a></p>`,
+			markdown: "see [the docs](/docs/x.html) and top",
+			links:    []string{"/docs/x.html", "#top"},
+		},
+		{
+			name:     "unclosed list items",
+			input:    `<ul><li>one<li>two<ol><li>inner</ol></ul>`,
+			markdown: "- one\n- two\n  1. inner",
+		},
+		{
+			name:     "table with implied end tags",
+			input:    `<table><tr><th>Name<th>Age<tr><td>Ann<td>30</table><p>A &amp; B &nbsp;C</p>`,
+			markdown: "| Name | Age |\n| --- | --- |\n| Ann | 30 |\n\nA & B C",
+		},
+		{
+			name:     "preformatted text and emphasis",
+			input:    "<pre>line 1\n  line 2</pre><p><b>bold</b> text</p>",
+			markdown: "line 1\n  line 2\n\n**bold** text",
+		},
+		{
+			name:     "navigation is skipped",
+			input:    `<nav><a href="/nav">Nav</a></nav><main><p>body</p></main><footer>footer</footer>`,
+			markdown: "body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := ParseHTML(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ParseHTML: %v", err)
+			}
+			if page.Title != tt.title {
+				t.Errorf("title = %q, want %q", page.Title, tt.title)
+			}
+			if page.Markdown != tt.markdown {
+				t.Errorf("markdown = %q, want %q", page.Markdown, tt.markdown)
+			}
+			if !slices.Equal(page.Links, tt.links) {
+				t.Errorf("links = %q, want %q", page.Links, tt.links)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrUnsupported = errors.New("unsupported document type")
+
+type Document struct {
+	Title    string
+	Markdown string
+	Metadata map[string]string
+}
+
+type Loader interface {
+	Name() string
+	Load(ctx context.Context, path string) (Document, error)
+}
+
+type Registry struct {
+	byExtension map[string]Loader
+	byMIME      map[string]Loader
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		byExtension: make(map[string]Loader),
+		byMIME:      make(map[string]Loader),
+	}
+}
+
+// NewDefaultRegistry registers the built-in loaders; docx and pdf files are
+// handed to the given converters.
+func NewDefaultRegistry(docxConverter, pdfConverter Converter) *Registry {
+	r := NewRegistry()
+	r.Register(NewTextLoader(), []string{".txt", ".text"}, []string{"text/plain"})
+	r.Register(NewMarkdownLoader(), []string{".md", ".markdown"}, []string{"text/markdown"})
+	r.Register(NewHTMLLoader(), []string{".html", ".htm"}, []string{"text/html"})
+	r.Register(NewCSVLoader(), []string{".csv"}, []string{"text/csv"})
+	r.Register(NewXLSXLoader(), []string{".xlsx"}, []string{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"})
+	if docxConverter != nil {
+		r.Register(NewConverterLoader("docx", docxConverter), []string{".docx"}, []string{"application/vnd.openxmlformats-officedocument.wordprocessingml.document"})
+	}
+	if pdfConverter != nil {
+		r.Register(NewConverterLoader("pdf", pdfConverter), []string{".pdf"}, []string{"application/pdf"})
+	}
+	return r
+}
+
+func (r *Registry) Register(loader Loader, extensions []string, mimeTypes []string) {
+	for _, ext := range extensions {
+		r.byExtension[strings.ToLower(ext)] = loader
+	}
+	for _, mimeType := range mimeTypes {
+		r.byMIME[mimeType] = loader
+	}
+}
+
+// Resolve picks a loader by file extension and falls back to sniffing the
+// MIME type of the file contents.
+func (r *Registry) Resolve(path string) (Loader, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if loader, ok := r.byExtension[ext]; ok {
+		return loader, nil
+	}
+
+	if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+		if loader, ok := r.byMIME[baseMIME(mimeType)]; ok {
+			return loader, nil
+		}
+	}
+
+	mimeType, err := sniffMIME(path)
+	if err != nil {
+		return nil, err
+	}
+	if loader, ok := r.byMIME[mimeType]; ok {
+		return loader, nil
+	}
+
+	return nil, ErrUnsupported
+}
+
+func (r *Registry) Load(ctx context.Context, path string) (Document, error) {
+	loader, err := r.Resolve(path)
+	if err != nil {
+		return Document{}, err
+	}
+
+	doc, err := loader.Load(ctx, path)
+	if err != nil {
+		return Document{}, err
+	}
+	if doc.Metadata == nil {
+		doc.Metadata = make(map[string]string)
+	}
+	if doc.Metadata["loader"] == "" {
+		doc.Metadata["loader"] = loader.Name()
+	}
+	if strings.TrimSpace(doc.Title) == "" {
+		doc.Title = titleFromPath(path)
+	}
+	return doc, nil
+}
+
+func sniffMIME(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return baseMIME(http.DetectContentType(head[:n])), nil
+}
+
+func baseMIME(mimeType string) string {
+	base, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return mimeType
+	}
+	return base
+}
+
+func titleFromPath(path string) string {
+	name := filepath.Base(path)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
@@ -0,0 +1,126 @@
+package loader
+
+import (
+	"context"
+	"strings"
+)
+
+type MarkdownLoader struct{}
+
+func NewMarkdownLoader() *MarkdownLoader {
+	return &MarkdownLoader{}
+}
+
+func (l *MarkdownLoader) Name() string {
+	return "markdown"
+}
+
+func (l *MarkdownLoader) Load(_ context.Context, path string) (Document, error) {
+	text, err := readUTF8(path)
+	if err != nil {
+		return Document{}, err
+	}
+
+	metadata, body := parseFrontMatter(text)
+
+	title := metadata["title"]
+	if title == "" {
+		title = firstHeading(body)
+	}
+
+	return Document{
+		Title:    title,
+		Markdown: body,
+		Metadata: metadata,
+	}, nil
+}
+
+// parseFrontMatter understands the flat "key: value" subset of YAML front
+// matter, including inline ([a, b]) and dashed lists, which are joined with commas.
+func parseFrontMatter(text string) (map[string]string, string) {
+	metadata := make(map[string]string)
+
+	normalized := strings.ReplaceAll(text, "\r\n", "\n")
+	if !strings.HasPrefix(normalized, "---\n") {
+		return metadata, text
+	}
+
+	rest := normalized[len("---\n"):]
+	end := strings.Index(rest, "\n---")
+	if end == -1 {
+		return metadata, text
+	}
+
+	header := rest[:end]
+	body := rest[end+len("\n---"):]
+	if i := strings.IndexByte(body, '\n'); i != -1 {
+		body = body[i+1:]
+	} else {
+		body = ""
+	}
+
+	var listKey string
+	for _, line := range strings.Split(header, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok && listKey != "" {
+			metadata[listKey] = joinValues(metadata[listKey], unquote(item))
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		listKey = ""
+
+		switch {
+		case value == "":
+			listKey = key
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			items := strings.Split(strings.Trim(value, "[]"), ",")
+			for _, item := range items {
+				metadata[key] = joinValues(metadata[key], unquote(item))
+			}
+		default:
+			metadata[key] = unquote(value)
+		}
+	}
+
+	return metadata, body
+}
+
+func joinValues(current, value string) string {
+	if value == "" {
+		return current
+	}
+	if current == "" {
+		return value
+	}
+	return current + "," + value
+}
+
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 {
+		if (value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
+
+func firstHeading(markdown string) string {
+	for _, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			return strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package loader
+
+import "strings"
+
+func markdownTable(rows [][]string) string {
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	if len(rows) == 0 || cols == 0 {
+		return ""
+	}
+
+	separator := make([]string, cols)
+	for i := range separator {
+		separator[i] = "---"
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	for i, row := range rows {
+		cells := make([]string, cols)
+		for j := range cells {
+			if j < len(row) {
+				cells[j] = tableCell(row[j])
+			}
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "| "+strings.Join(separator, " | ")+" |")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func tableCell(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	return strings.ReplaceAll(value, "|", "\\|")
+}
+
+func trimEmptyRows(rows [][]string) [][]string {
+	out := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		for _, cell := range row {
+			if strings.TrimSpace(cell) != "" {
+				out = append(out, row)
+				break
+			}
+		}
+	}
+	return out
+}
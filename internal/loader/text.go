@@ -0,0 +1,40 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+type TextLoader struct{}
+
+func NewTextLoader() *TextLoader {
+	return &TextLoader{}
+}
+
+func (l *TextLoader) Name() string {
+	return "text"
+}
+
+func (l *TextLoader) Load(_ context.Context, path string) (Document, error) {
+	text, err := readUTF8(path)
+	if err != nil {
+		return Document{}, err
+	}
+
+	return Document{Markdown: text}, nil
+}
+
+func readUTF8(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("%s is not valid UTF-8", path)
+	}
+
+	return strings.TrimPrefix(string(data), "\ufeff"), nil
+}
@@ -0,0 +1,192 @@
+package loader
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type XLSXLoader struct{}
+
+func NewXLSXLoader() *XLSXLoader {
+	return &XLSXLoader{}
+}
+
+func (l *XLSXLoader) Name() string {
+	return "xlsx"
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxRichText `xml:"si"`
+}
+
+type xlsxRichText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxRichText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string       `xml:"r,attr"`
+			Type   string       `xml:"t,attr"`
+			Value  string       `xml:"v"`
+			Inline xlsxRichText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func (l *XLSXLoader) Load(_ context.Context, filePath string) (Document, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return Document{}, fmt.Errorf("xlsx: open %s: %w", filePath, err)
+	}
+	defer zr.Close()
+
+	var workbook xlsxWorkbook
+	if err := decodeZipXML(&zr.Reader, "xl/workbook.xml", &workbook); err != nil {
+		return Document{}, err
+	}
+
+	var rels xlsxRelationships
+	if err := decodeZipXML(&zr.Reader, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return Document{}, err
+	}
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		target := strings.TrimPrefix(rel.Target, "/")
+		if !strings.HasPrefix(target, "xl/") {
+			target = path.Join("xl", target)
+		}
+		targets[rel.ID] = target
+	}
+
+	var shared xlsxSharedStrings
+	if err := decodeZipXML(&zr.Reader, "xl/sharedStrings.xml", &shared); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Document{}, err
+	}
+	strs := make([]string, len(shared.Items))
+	for i, item := range shared.Items {
+		strs[i] = item.String()
+	}
+
+	sections := make([]string, 0, len(workbook.Sheets))
+	for _, sheet := range workbook.Sheets {
+		target, ok := targets[sheet.RID]
+		if !ok {
+			continue
+		}
+
+		var data xlsxSheet
+		if err := decodeZipXML(&zr.Reader, target, &data); err != nil {
+			return Document{}, err
+		}
+
+		rows := make([][]string, 0, len(data.Rows))
+		for _, row := range data.Rows {
+			cells := make([]string, 0, len(row.Cells))
+			for i, cell := range row.Cells {
+				col := columnIndex(cell.Ref)
+				if col < 0 {
+					col = i
+				}
+				for len(cells) <= col {
+					cells = append(cells, "")
+				}
+				cells[col] = cellValue(cell.Type, cell.Value, cell.Inline, strs)
+			}
+			rows = append(rows, cells)
+		}
+
+		table := markdownTable(trimEmptyRows(rows))
+		if table == "" {
+			continue
+		}
+		sections = append(sections, "## "+sheet.Name+"\n\n"+table)
+	}
+
+	return Document{Markdown: strings.Join(sections, "\n\n")}, nil
+}
+
+func cellValue(cellType, value string, inline xlsxRichText, shared []string) string {
+	switch cellType {
+	case "s":
+		idx, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || idx < 0 || idx >= len(shared) {
+			return ""
+		}
+		return shared[idx]
+	case "inlineStr":
+		return inline.String()
+	case "b":
+		if value == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	default:
+		return value
+	}
+}
+
+// columnIndex converts the letters of a cell reference such as "AB12" to a zero-based column.
+func columnIndex(ref string) int {
+	col := 0
+	letters := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A'+1)
+		letters++
+	}
+	if letters == 0 {
+		return -1
+	}
+	return col - 1
+}
+
+func decodeZipXML(zr *zip.Reader, name string, target any) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := xml.NewDecoder(f).Decode(target); err != nil {
+		return fmt.Errorf("xlsx: parse %s: %w", name, err)
+	}
+	return nil
+}
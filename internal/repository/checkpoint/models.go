@@ -0,0 +1,23 @@
+package checkpoint
+
+import "time"
+
+// Checkpoint is the ingestion progress of one file: the embeddings of the
+// batches finished so far and the chunk IDs about to be written, so a crash
+// between the vector store write and the manifest update can be cleaned up.
+// Questions and QuestionEmbeddings hold the generated questions per chunk.
+// Pipeline is the ingest pipeline version the checkpoint was made by.
+type Checkpoint struct {
+	Path               string      `json:"path"`
+	Hash               string      `json:"hash"`
+	Inputs             string      `json:"inputs"`
+	Pipeline           int         `json:"pipeline"`
+	EmbeddingModel     string      `json:"embedding_model"`
+	Dim                int         `json:"dim"`
+	Embeddings         [][]float32 `json:"embeddings"`
+	Questions          [][]string  `json:"questions,omitempty"`
+	QuestionEmbeddings [][]float32 `json:"question_embeddings,omitempty"`
+	PendingIDs         []int64     `json:"pending_ids,omitempty"`
+	PendingQuestionIDs []int64     `json:"pending_question_ids,omitempty"`
+	UpdatedAt          time.Time   `json:"updated_at"`
+}
@@ -0,0 +1,104 @@
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rag-test/internal/storage/atomicfile"
+)
+
+// Repository keeps one checkpoint file per document in a directory.
+type Repository struct {
+	dir string
+}
+
+func NewRepository(dir string) (*Repository, error) {
+	if dir == "" {
+		return nil, errors.New("checkpoint dir is empty")
+	}
+
+	return &Repository{dir: dir}, nil
+}
+
+// Load returns nil when the file has no checkpoint.
+func (r *Repository) Load(path string) (*Checkpoint, error) {
+	return r.read(r.file(path))
+}
+
+// List returns every stored checkpoint.
+func (r *Repository) List() ([]*Checkpoint, error) {
+	entries, err := os.ReadDir(r.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*Checkpoint, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		cp, err := r.read(filepath.Join(r.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if cp != nil {
+			out = append(out, cp)
+		}
+	}
+	return out, nil
+}
+
+func (r *Repository) Save(cp *Checkpoint) error {
+	if cp == nil {
+		return errors.New("checkpoint is nil")
+	}
+
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.Write(r.file(cp.Path), data)
+}
+
+func (r *Repository) Delete(path string) error {
+	if err := os.Remove(r.file(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Reset drops all checkpoints.
+func (r *Repository) Reset() error {
+	return os.RemoveAll(r.dir)
+}
+
+func (r *Repository) read(file string) (*Checkpoint, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("decode checkpoint %s: %w", file, err)
+	}
+	return &cp, nil
+}
+
+func (r *Repository) file(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(r.dir, hex.EncodeToString(sum[:8])+".json")
+}
@@ -0,0 +1,107 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	repo, err := NewRepository(filepath.Join(t.TempDir(), "checkpoints"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err := repo.Load("a.md")
+	if err != nil || cp != nil {
+		t.Fatalf("Load before Save = %+v, %v; want nil, nil", cp, err)
+	}
+	if list, err := repo.List(); err != nil || len(list) != 0 {
+		t.Fatalf("List of a missing dir = %v, %v; want nothing", list, err)
+	}
+
+	saved := &Checkpoint{
+		Path:       "a.md",
+		Hash:       "h1",
+		Inputs:     "i1",
+		Pipeline:   3,
+		Dim:        2,
+		Embeddings: [][]float32{{1, 0}, {0, 1}},
+		PendingIDs: []int64{7, 8},
+	}
+	if err := repo.Save(saved); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := repo.Save(&Checkpoint{Path: "b.md", Hash: "h2"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	cp, err = repo.Load("a.md")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cp.Hash != "h1" || cp.Pipeline != 3 || len(cp.Embeddings) != 2 || !slices.Equal(cp.PendingIDs, []int64{7, 8}) {
+		t.Errorf("Load = %+v, want the saved checkpoint", cp)
+	}
+
+	// Saving again replaces the checkpoint, as a run does after every batch.
+	saved.Embeddings = append(saved.Embeddings, []float32{1, 1})
+	if err := repo.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	if cp, err := repo.Load("a.md"); err != nil || len(cp.Embeddings) != 3 {
+		t.Errorf("Load after the second Save = %+v, %v; want 3 embeddings", cp, err)
+	}
+
+	list, err := repo.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make([]string, 0, len(list))
+	for _, cp := range list {
+		paths = append(paths, cp.Path)
+	}
+	slices.Sort(paths)
+	if !slices.Equal(paths, []string{"a.md", "b.md"}) {
+		t.Errorf("List paths = %v, want [a.md b.md]", paths)
+	}
+
+	if err := repo.Delete("a.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete("a.md"); err != nil {
+		t.Errorf("Delete of a missing checkpoint: %v", err)
+	}
+	if cp, err := repo.Load("a.md"); err != nil || cp != nil {
+		t.Errorf("Load after Delete = %+v, %v; want nil, nil", cp, err)
+	}
+
+	if err := repo.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := repo.List(); err != nil || len(list) != 0 {
+		t.Errorf("List after Reset = %v, %v; want nothing", list, err)
+	}
+}
+
+func TestLoadCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := NewRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(repo.file("a.md"), []byte(`{"path":"a.md","embe`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Load("a.md"); err == nil {
+		t.Error("Load of a torn checkpoint succeeded")
+	}
+	if _, err := repo.List(); err == nil {
+		t.Error("List with a torn checkpoint succeeded")
+	}
+	if _, err := NewRepository(""); err == nil {
+		t.Error("NewRepository with an empty dir succeeded")
+	}
+}
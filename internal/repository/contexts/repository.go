@@ -0,0 +1,97 @@
+package contexts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"rag-test/internal/storage/atomicfile"
+)
+
+// Cache holds the situating contexts generated for the chunks of one
+// document version, keyed by chunk text hash.
+type Cache struct {
+	DocumentHash string            `json:"document_hash"`
+	Contexts     map[string]string `json:"contexts"`
+}
+
+func (c *Cache) Get(chunk string) (string, bool) {
+	context, ok := c.Contexts[ChunkKey(chunk)]
+	return context, ok
+}
+
+func (c *Cache) Put(chunk, context string) {
+	c.Contexts[ChunkKey(chunk)] = context
+}
+
+func ChunkKey(chunk string) string {
+	sum := sha256.Sum256([]byte(chunk))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Repository stores one cache file per document hash, so an unchanged
+// document never pays for its contexts twice.
+type Repository struct {
+	dir string
+}
+
+func NewRepository(dir string) (*Repository, error) {
+	if dir == "" {
+		return nil, errors.New("context cache dir is empty")
+	}
+
+	return &Repository{dir: dir}, nil
+}
+
+// Load returns an empty cache when the document hash has none yet.
+func (r *Repository) Load(documentHash string) (*Cache, error) {
+	empty := &Cache{DocumentHash: documentHash, Contexts: make(map[string]string)}
+
+	data, err := os.ReadFile(r.file(documentHash))
+	if errors.Is(err, os.ErrNotExist) {
+		return empty, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cache
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("decode context cache %s: %w", documentHash, err)
+	}
+	if c.DocumentHash != documentHash {
+		return empty, nil
+	}
+	if c.Contexts == nil {
+		c.Contexts = make(map[string]string)
+	}
+	return &c, nil
+}
+
+func (r *Repository) Save(c *Cache) error {
+	if c == nil {
+		return errors.New("context cache is nil")
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.Write(r.file(c.DocumentHash), data)
+}
+
+func (r *Repository) Delete(documentHash string) error {
+	if err := os.Remove(r.file(documentHash)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) file(documentHash string) string {
+	return filepath.Join(r.dir, filepath.Base(documentHash)+".json")
+}
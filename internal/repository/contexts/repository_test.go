@@ -0,0 +1,97 @@
+package contexts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	repo, err := NewRepository(filepath.Join(t.TempDir(), "contexts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := repo.Load("doc1")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cache.DocumentHash != "doc1" || len(cache.Contexts) != 0 {
+		t.Fatalf("Load of a new document = %+v, want an empty cache", cache)
+	}
+	cache.Put("Стул офисный.", "Раздел каталога о стульях.")
+	cache.Put("Стол письменный.", "Раздел каталога о столах.")
+	if err := repo.Save(cache); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	cache, err = repo.Load("doc1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := cache.Get("Стул офисный."); !ok || got != "Раздел каталога о стульях." {
+		t.Errorf("Get = %q, %v; want the stored context", got, ok)
+	}
+	// A chunk whose text changed is a new key, so its context is generated
+	// again while the unchanged chunks keep theirs.
+	if got, ok := cache.Get("Стул офисный, чёрный."); ok {
+		t.Errorf("Get of a changed chunk = %q, want a miss", got)
+	}
+
+	// Another version of the document starts with an empty cache.
+	other, err := repo.Load("doc2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other.Contexts) != 0 {
+		t.Errorf("Load of another document version = %+v, want an empty cache", other)
+	}
+
+	if err := repo.Delete("doc1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete("doc1"); err != nil {
+		t.Errorf("Delete of a missing cache: %v", err)
+	}
+	if cache, err := repo.Load("doc1"); err != nil || len(cache.Contexts) != 0 {
+		t.Errorf("Load after Delete = %+v, %v; want an empty cache", cache, err)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{name: "cache of another document", file: `{"document_hash":"other","contexts":{"k":"v"}}`},
+		{name: "no contexts", file: `{"document_hash":"doc1"}`},
+		{name: "torn file", file: `{"document_hash":"doc1","cont`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := NewRepository(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(repo.file("doc1"), []byte(tt.file), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cache, err := repo.Load("doc1")
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Load succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cache.DocumentHash != "doc1" || cache.Contexts == nil || len(cache.Contexts) != 0 {
+				t.Errorf("Load = %+v, want an empty cache of doc1", cache)
+			}
+		})
+	}
+}
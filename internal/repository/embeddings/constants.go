@@ -1,7 +1,8 @@
 package embeddings
 
 const (
-	modelName          = "gpt-5.2"
-	embeddingModelName = "text-embedding-3-large"
-	defaultDim         = 384
+	modelName = "gpt-5.2"
+
+	DefaultModel = "text-embedding-3-large"
+	DefaultDim   = 384
 )
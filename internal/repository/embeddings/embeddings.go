@@ -7,16 +7,31 @@ import (
 	"github.com/tmc/langchaingo/llms/openai"
 )
 
+// Config selects the embedding model and the vector size it is asked for;
+// zero values fall back to DefaultModel and DefaultDim.
+type Config struct {
+	Model string
+	Dim   int
+}
+
 type Repository struct {
 	cli *openai.LLM
+	cfg Config
 }
 
-func NewRepository(token string) (*Repository, error) {
+func NewRepository(token string, cfg Config) (*Repository, error) {
+	if cfg.Model == "" {
+		cfg.Model = DefaultModel
+	}
+	if cfg.Dim <= 0 {
+		cfg.Dim = DefaultDim
+	}
+
 	opts := []openai.Option{
 		openai.WithToken(token),
 		openai.WithModel(modelName),
-		openai.WithEmbeddingModel(embeddingModelName),
-		openai.WithEmbeddingDimensions(defaultDim),
+		openai.WithEmbeddingModel(cfg.Model),
+		openai.WithEmbeddingDimensions(cfg.Dim),
 	}
 
 	llm, err := openai.New(opts...)
@@ -24,7 +39,7 @@ func NewRepository(token string) (*Repository, error) {
 		return nil, err
 	}
 
-	return &Repository{cli: llm}, nil
+	return &Repository{cli: llm, cfg: cfg}, nil
 }
 
 func (r *Repository) CreateEmbeddings(ctx context.Context, data ...string) ([][]float32, error) {
@@ -36,3 +51,11 @@ func (r *Repository) CreateEmbeddings(ctx context.Context, data ...string) ([][]
 
 	return embeddings, nil
 }
+
+func (r *Repository) Model() string {
+	return r.cfg.Model
+}
+
+func (r *Repository) Dim() int {
+	return r.cfg.Dim
+}
@@ -0,0 +1,277 @@
+package hnsw
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+
+	milvusrepo "rag-test/internal/repository/milvus"
+)
+
+// graphSeed makes the levels drawn for a given insertion order repeatable,
+// so rebuilding an index from its log yields the same graph.
+const graphSeed = 1
+
+type node struct {
+	id        int64
+	vector    []float32
+	neighbors [][]int32
+	deleted   bool
+}
+
+type candidate struct {
+	node     int32
+	distance float32
+}
+
+// graph is a hierarchical navigable small world. Its distance is squared L2
+// for the L2 metric and the negated similarity otherwise, so lower is always
+// closer. Deleted nodes stay in the graph for navigation and are skipped in results
+// until the next rebuild.
+type graph struct {
+	m              int
+	efConstruction int
+	levelMult      float64
+	rng            *rand.Rand
+	metric         milvusrepo.Metric
+	distance       func(a, b []float32) float32
+
+	nodes    []node
+	entry    int32
+	maxLevel int
+	deleted  int
+}
+
+func newGraph(m, efConstruction int, metric milvusrepo.Metric) *graph {
+	distance := l2
+	if metric != milvusrepo.MetricL2 {
+		distance = func(a, b []float32) float32 { return -milvusrepo.Similarity(metric, a, b) }
+	}
+	return &graph{
+		m:              m,
+		efConstruction: efConstruction,
+		levelMult:      1 / math.Log(float64(m)),
+		rng:            rand.New(rand.NewSource(graphSeed)),
+		metric:         metric,
+		distance:       distance,
+		entry:          -1,
+	}
+}
+
+// score turns a graph distance into a normalized search score.
+func (g *graph) score(distance float32) float32 {
+	if g.metric == milvusrepo.MetricL2 {
+		return milvusrepo.NormalizeScore(milvusrepo.MetricL2, distance)
+	}
+	return -distance
+}
+
+func (g *graph) live() int {
+	return len(g.nodes) - g.deleted
+}
+
+func (g *graph) remove(idx int32) {
+	if !g.nodes[idx].deleted {
+		g.nodes[idx].deleted = true
+		g.deleted++
+	}
+}
+
+func (g *graph) insert(id int64, vector []float32) int32 {
+	level := int(math.Floor(-math.Log(1-g.rng.Float64()) * g.levelMult))
+	idx := int32(len(g.nodes))
+	g.nodes = append(g.nodes, node{id: id, vector: vector, neighbors: make([][]int32, level+1)})
+
+	if g.entry < 0 {
+		g.entry, g.maxLevel = idx, level
+		return idx
+	}
+
+	ep := g.entry
+	for l := g.maxLevel; l > level; l-- {
+		ep = g.greedy(vector, ep, l)
+	}
+	for l := min(level, g.maxLevel); l >= 0; l-- {
+		found := g.searchLayer(vector, ep, g.efConstruction, l)
+		neighbors := g.selectNeighbors(found, g.m)
+		g.nodes[idx].neighbors[l] = neighbors
+		for _, n := range neighbors {
+			g.link(n, idx, l)
+		}
+		ep = found[0].node
+	}
+
+	if level > g.maxLevel {
+		g.entry, g.maxLevel = idx, level
+	}
+	return idx
+}
+
+// link adds to into the neighbour list of from and prunes the list back to
+// its limit; layer 0 keeps twice as many links as the upper layers.
+func (g *graph) link(from, to int32, level int) {
+	limit := g.m
+	if level == 0 {
+		limit = 2 * g.m
+	}
+
+	neighbors := append(g.nodes[from].neighbors[level], to)
+	if len(neighbors) > limit {
+		found := make([]candidate, 0, len(neighbors))
+		for _, n := range neighbors {
+			found = append(found, candidate{node: n, distance: g.distance(g.nodes[from].vector, g.nodes[n].vector)})
+		}
+		sort.Slice(found, func(i, j int) bool { return found[i].distance < found[j].distance })
+		neighbors = g.selectNeighbors(found, limit)
+	}
+	g.nodes[from].neighbors[level] = neighbors
+}
+
+// selectNeighbors keeps a candidate only if it is closer to the query than
+// to every neighbour kept so far, which spreads links across clusters; the
+// remaining slots are filled with the closest of the skipped candidates.
+// found must be sorted by distance.
+func (g *graph) selectNeighbors(found []candidate, limit int) []int32 {
+	selected := make([]int32, 0, limit)
+	skipped := make([]int32, 0)
+	for _, c := range found {
+		if len(selected) == limit {
+			break
+		}
+		keep := true
+		for _, s := range selected {
+			if g.distance(g.nodes[c.node].vector, g.nodes[s].vector) < c.distance {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			selected = append(selected, c.node)
+		} else {
+			skipped = append(skipped, c.node)
+		}
+	}
+	for _, n := range skipped {
+		if len(selected) == limit {
+			break
+		}
+		selected = append(selected, n)
+	}
+	return selected
+}
+
+func (g *graph) greedy(query []float32, ep int32, level int) int32 {
+	best := g.distance(query, g.nodes[ep].vector)
+	for changed := true; changed; {
+		changed = false
+		for _, n := range g.nodes[ep].neighbors[level] {
+			if d := g.distance(query, g.nodes[n].vector); d < best {
+				ep, best, changed = n, d, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer returns up to ef nodes closest to query on one layer, sorted
+// by distance; deleted nodes are included.
+func (g *graph) searchLayer(query []float32, ep int32, ef int, level int) []candidate {
+	visited := make([]bool, len(g.nodes))
+	visited[ep] = true
+	start := candidate{node: ep, distance: g.distance(query, g.nodes[ep].vector)}
+
+	frontier := &minHeap{start}
+	results := &maxHeap{start}
+	for frontier.Len() > 0 {
+		current := heap.Pop(frontier).(candidate)
+		if results.Len() >= ef && current.distance > (*results)[0].distance {
+			break
+		}
+		for _, n := range g.nodes[current.node].neighbors[level] {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+
+			d := g.distance(query, g.nodes[n].vector)
+			if results.Len() < ef || d < (*results)[0].distance {
+				heap.Push(frontier, candidate{node: n, distance: d})
+				heap.Push(results, candidate{node: n, distance: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	found := []candidate(*results)
+	sort.Slice(found, func(i, j int) bool { return found[i].distance < found[j].distance })
+	return found
+}
+
+// search returns up to k live nodes closest to query. The beam widens while
+// deleted nodes crowd out live ones.
+func (g *graph) search(query []float32, k, ef int) []candidate {
+	if g.entry < 0 || k <= 0 {
+		return nil
+	}
+
+	ep := g.entry
+	for l := g.maxLevel; l > 0; l-- {
+		ep = g.greedy(query, ep, l)
+	}
+
+	ef = max(ef, k)
+	for {
+		found := g.searchLayer(query, ep, ef, 0)
+		hits := make([]candidate, 0, k)
+		for _, c := range found {
+			if !g.nodes[c.node].deleted {
+				hits = append(hits, c)
+				if len(hits) == k {
+					break
+				}
+			}
+		}
+		if len(hits) == k || len(hits) == g.live() || ef >= len(g.nodes) {
+			return hits
+		}
+		ef *= 2
+	}
+}
+
+func l2(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
+
+type minHeap []candidate
+
+func (h minHeap) Len() int           { return len(h) }
+func (h minHeap) Less(i, j int) bool { return h[i].distance < h[j].distance }
+func (h minHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x any)        { *h = append(*h, x.(candidate)) }
+func (h *minHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type maxHeap []candidate
+
+func (h maxHeap) Len() int           { return len(h) }
+func (h maxHeap) Less(i, j int) bool { return h[i].distance > h[j].distance }
+func (h maxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x any)        { *h = append(*h, x.(candidate)) }
+func (h *maxHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
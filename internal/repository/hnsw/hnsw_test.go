@@ -0,0 +1,384 @@
+package hnsw
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"rag-test/internal/repository/memory"
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/storage/wal"
+)
+
+const testCollection = "kb"
+
+func randomItems(rng *rand.Rand, n, dim int, firstID int64) []milvusrepo.VectorItem {
+	items := make([]milvusrepo.VectorItem, n)
+	for i := range items {
+		items[i] = milvusrepo.VectorItem{
+			ID:         firstID + int64(i),
+			Embedding:  randomVector(rng, dim),
+			Payload:    fmt.Sprintf("chunk %d", firstID+int64(i)),
+			DataSource: "doc.md",
+		}
+	}
+	return items
+}
+
+func randomVector(rng *rand.Rand, dim int) []float32 {
+	v := make([]float32, dim)
+	for i := range v {
+		v[i] = rng.Float32()*2 - 1
+	}
+	return v
+}
+
+// fill creates the collection in both stores and upserts the same items.
+func fill(tb testing.TB, items []milvusrepo.VectorItem, cfg milvusrepo.CollectionConfig, repos ...milvusrepo.VectorRepository) {
+	tb.Helper()
+
+	ctx := context.Background()
+	for _, repo := range repos {
+		if err := repo.EnsureCollection(ctx, testCollection, cfg); err != nil {
+			tb.Fatalf("EnsureCollection: %v", err)
+		}
+		for start := 0; start < len(items); start += 500 {
+			if err := repo.Upsert(ctx, testCollection, items[start:min(start+500, len(items))]); err != nil {
+				tb.Fatalf("Upsert: %v", err)
+			}
+		}
+	}
+}
+
+func hitIDs(hits []milvusrepo.SearchHit) []int64 {
+	ids := make([]int64, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+// TestRecall compares the graph search with the exact search of the memory
+// store on the same random vectors.
+func TestRecall(t *testing.T) {
+	const (
+		items   = 3000
+		dim     = 32
+		queries = 100
+		topK    = 10
+	)
+
+	tests := []struct {
+		metric    milvusrepo.Metric
+		minRecall float64
+	}{
+		{metric: milvusrepo.MetricL2, minRecall: 0.95},
+		{metric: milvusrepo.MetricCosine, minRecall: 0.95},
+		{metric: milvusrepo.MetricIP, minRecall: 0.9},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.metric), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			repo, err := NewRepository(t.TempDir(), Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer repo.Close()
+			exact, err := memory.NewRepository(memory.Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			fill(t, randomItems(rng, items, dim, 1), milvusrepo.CollectionConfig{Dim: dim, Metric: tt.metric}, repo, exact)
+
+			ctx := context.Background()
+			found := 0
+			for range queries {
+				req := milvusrepo.SearchRequest{Vector: randomVector(rng, dim), TopK: topK}
+				want, err := exact.Search(ctx, testCollection, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := repo.Search(ctx, testCollection, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				gotIDs := hitIDs(got)
+				for _, id := range hitIDs(want) {
+					if slices.Contains(gotIDs, id) {
+						found++
+					}
+				}
+			}
+
+			recall := float64(found) / float64(queries*topK)
+			t.Logf("recall@%d = %.3f", topK, recall)
+			if recall < tt.minRecall {
+				t.Errorf("recall@%d = %.3f, want at least %.2f", topK, recall, tt.minRecall)
+			}
+		})
+	}
+}
+
+// TestSearchBatch checks that every query gets its own ranking, cut to the
+// request, through the graph and through the filtered scan.
+func TestSearchBatch(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewRepository(t.TempDir(), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	items := []milvusrepo.VectorItem{
+		{ID: 1, Embedding: []float32{1, 0}, DataSource: "a.md"},
+		{ID: 2, Embedding: []float32{0.9, 0.1}, DataSource: "b.md"},
+		{ID: 3, Embedding: []float32{0, 1}, DataSource: "a.md"},
+		{ID: 4, Embedding: []float32{0.1, 0.9}, DataSource: "b.md"},
+	}
+	fill(t, items, milvusrepo.CollectionConfig{Dim: 2, Metric: milvusrepo.MetricCosine}, repo)
+	queries := [][]float32{{0, 1}, {1, 0}}
+
+	tests := []struct {
+		name string
+		req  milvusrepo.SearchRequest
+		want [][]int64
+	}{
+		{
+			name: "one hit per query in query order",
+			req:  milvusrepo.SearchRequest{TopK: 1},
+			want: [][]int64{{3}, {1}},
+		},
+		{
+			name: "topK and offset per query",
+			req:  milvusrepo.SearchRequest{TopK: 2, Offset: 1},
+			want: [][]int64{{4, 2}, {2, 4}},
+		},
+		{
+			name: "filter per query",
+			req:  milvusrepo.SearchRequest{TopK: 10, Filter: milvusrepo.Eq(milvusrepo.FieldDataSource, "b.md")},
+			want: [][]int64{{4, 2}, {2, 4}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch, err := repo.SearchBatch(ctx, testCollection, queries, tt.req)
+			if err != nil {
+				t.Fatalf("SearchBatch: %v", err)
+			}
+			if len(batch) != len(tt.want) {
+				t.Fatalf("got %d result lists, want %d", len(batch), len(tt.want))
+			}
+			for i, hits := range batch {
+				if got := hitIDs(hits); !slices.Equal(got, tt.want[i]) {
+					t.Errorf("query %d: hits = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := repo.SearchBatch(ctx, testCollection, [][]float32{{1, 0}, {1}}, milvusrepo.SearchRequest{TopK: 1}); err == nil {
+		t.Error("SearchBatch with a vector of the wrong dimension succeeded")
+	}
+}
+
+// TestReplayTornLog cuts or corrupts the last record of vectors.log, as a
+// crash in the middle of a write would, and checks that reopening keeps the
+// earlier records, drops the torn one and truncates the file after the last
+// intact record.
+func TestReplayTornLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(t *testing.T, path string, intact, full int64)
+	}{
+		{
+			name: "cut inside the header",
+			damage: func(t *testing.T, path string, intact, _ int64) {
+				// The frame header of a wal record is 8 bytes.
+				truncate(t, path, intact+4)
+			},
+		},
+		{
+			name: "cut inside the record",
+			damage: func(t *testing.T, path string, intact, full int64) {
+				truncate(t, path, intact+(full-intact)/2)
+			},
+		},
+		{
+			name: "checksum mismatch",
+			damage: func(t *testing.T, path string, _, full int64) {
+				f, err := os.OpenFile(path, os.O_RDWR, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				if _, err := f.WriteAt([]byte{'#'}, full-2); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			path := filepath.Join(dir, testCollection, logFileName)
+			rng := rand.New(rand.NewSource(1))
+
+			repo, err := NewRepository(dir, Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			fill(t, randomItems(rng, 10, 8, 1), milvusrepo.CollectionConfig{Dim: 8}, repo)
+			intact := fileSize(t, path)
+			if err := repo.Upsert(ctx, testCollection, randomItems(rng, 10, 8, 11)); err != nil {
+				t.Fatal(err)
+			}
+			full := fileSize(t, path)
+			if err := repo.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.damage(t, path, intact, full)
+
+			records, err := wal.Replay(wal.New(path), func(logRecord) {})
+			if err != nil {
+				t.Fatalf("Replay: %v", err)
+			}
+			if records != 1 {
+				t.Errorf("Replay kept %d records, want 1", records)
+			}
+			if size := fileSize(t, path); size != intact {
+				t.Errorf("log is %d bytes after replay, want %d", size, intact)
+			}
+
+			repo, err = NewRepository(dir, Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer repo.Close()
+			if got := getIDs(t, repo, 1, 20); !slices.Equal(got, ids(1, 10)) {
+				t.Errorf("after reopening got ids %v, want 1..10", got)
+			}
+
+			// The log accepts new records after the truncated tail.
+			if err := repo.Upsert(ctx, testCollection, randomItems(rng, 5, 8, 21)); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Close(); err != nil {
+				t.Fatal(err)
+			}
+			repo, err = NewRepository(dir, Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := getIDs(t, repo, 1, 25); !slices.Equal(got, append(ids(1, 10), ids(21, 25)...)) {
+				t.Errorf("after appending got ids %v, want 1..10 and 21..25", got)
+			}
+		})
+	}
+}
+
+func truncate(t *testing.T, path string, size int64) {
+	t.Helper()
+	if err := os.Truncate(path, size); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func ids(from, to int64) []int64 {
+	out := make([]int64, 0, to-from+1)
+	for id := from; id <= to; id++ {
+		out = append(out, id)
+	}
+	return out
+}
+
+func getIDs(t *testing.T, repo *Repository, from, to int64) []int64 {
+	t.Helper()
+	items, err := repo.Get(context.Background(), testCollection, ids(from, to))
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got := make([]int64, 0, len(items))
+	for _, item := range items {
+		got = append(got, item.ID)
+	}
+	slices.Sort(got)
+	return got
+}
+
+func BenchmarkUpsert(b *testing.B) {
+	const dim = 384
+	rng := rand.New(rand.NewSource(1))
+	items := randomItems(rng, 100, dim, 1)
+
+	repo, err := NewRepository(b.TempDir(), Config{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer repo.Close()
+	fill(b, nil, milvusrepo.CollectionConfig{Dim: dim}, repo)
+
+	b.ResetTimer()
+	for i := range b.N {
+		for j := range items {
+			items[j].ID = int64(i*len(items) + j)
+		}
+		if err := repo.Upsert(context.Background(), testCollection, items); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	const dim = 384
+	for _, size := range []int{1000, 10000} {
+		rng := rand.New(rand.NewSource(1))
+		items := randomItems(rng, size, dim, 1)
+		queries := make([][]float32, 64)
+		for i := range queries {
+			queries[i] = randomVector(rng, dim)
+		}
+
+		repo, err := NewRepository(b.TempDir(), Config{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		exact, err := memory.NewRepository(memory.Config{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		fill(b, items, milvusrepo.CollectionConfig{Dim: dim}, repo, exact)
+
+		for _, store := range []struct {
+			name string
+			repo milvusrepo.VectorRepository
+		}{{"hnsw", repo}, {"exact", exact}} {
+			b.Run(fmt.Sprintf("%s/%d", store.name, size), func(b *testing.B) {
+				for i := range b.N {
+					req := milvusrepo.SearchRequest{Vector: queries[i%len(queries)], TopK: 10}
+					if _, err := store.repo.Search(context.Background(), testCollection, req); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+		repo.Close()
+	}
+}
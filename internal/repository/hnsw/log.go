@@ -0,0 +1,45 @@
+package hnsw
+
+import (
+	milvusrepo "rag-test/internal/repository/milvus"
+)
+
+const (
+	opUpsert = "upsert"
+	opDelete = "delete"
+)
+
+// logRecord is one change in the append-only collection log.
+type logRecord struct {
+	Op    string    `json:"op"`
+	Items []logItem `json:"items,omitempty"`
+	IDs   []int64   `json:"ids,omitempty"`
+}
+
+type logItem struct {
+	ID         int64                    `json:"id"`
+	Embedding  []float32                `json:"embedding"`
+	Payload    string                   `json:"payload"`
+	DataSource string                   `json:"data_source"`
+	Metadata   milvusrepo.ChunkMetadata `json:"metadata"`
+}
+
+func toLogItem(item milvusrepo.VectorItem) logItem {
+	return logItem{
+		ID:         item.ID,
+		Embedding:  item.Embedding,
+		Payload:    item.Payload,
+		DataSource: item.DataSource,
+		Metadata:   item.Metadata,
+	}
+}
+
+func (i logItem) vectorItem() milvusrepo.VectorItem {
+	return milvusrepo.VectorItem{
+		ID:         i.ID,
+		Embedding:  i.Embedding,
+		Payload:    i.Payload,
+		DataSource: i.DataSource,
+		Metadata:   i.Metadata,
+	}
+}
@@ -0,0 +1,551 @@
+package hnsw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/storage/atomicfile"
+	"rag-test/internal/storage/wal"
+)
+
+const (
+	defaultM              = 16
+	defaultEfConstruction = 200
+	defaultEfSearch       = 64
+
+	metaFileName = "meta.json"
+	logFileName  = "vectors.log"
+
+	// compactMinEntries keeps small logs from being rewritten on every change.
+	compactMinEntries = 1024
+	compactBatchSize  = 256
+)
+
+// Config tunes the index. M is the number of links per node, EfConstruction
+// and EfSearch the beam widths while inserting and searching; larger values
+// raise recall at the cost of memory, build time and latency.
+type Config struct {
+	M              int
+	EfConstruction int
+	EfSearch       int
+}
+
+func (c Config) withDefaults() Config {
+	if c.M < 2 {
+		c.M = defaultM
+	}
+	if c.EfConstruction <= 0 {
+		c.EfConstruction = defaultEfConstruction
+	}
+	if c.EfSearch <= 0 {
+		c.EfSearch = defaultEfSearch
+	}
+	return c
+}
+
+type collectionMeta struct {
+	Dim             int    `json:"dim"`
+	MaxPayloadBytes int    `json:"max_payload_bytes"`
+	Metric          string `json:"metric,omitempty"`
+}
+
+// collection keeps its items and graph in memory. Only the log in its
+// directory is persisted: the graph is rebuilt from it when the collection
+// is opened and after every compaction, which takes a while for large
+// collections.
+type collection struct {
+	mu      sync.RWMutex
+	dir     string
+	cfg     milvusrepo.CollectionConfig
+	items   map[int64]milvusrepo.VectorItem
+	nodes   map[int64]int32
+	graph   *graph
+	log     *wal.Log
+	entries int
+}
+
+// Repository is a file-backed milvus.VectorRepository with an HNSW index
+// for deployments too small to run Milvus. Every collection lives in its own
+// subdirectory of dir; searches run concurrently, writes are appended and
+// synced to the collection log before they become visible.
+type Repository struct {
+	dir string
+	cfg Config
+
+	mu          sync.Mutex
+	collections map[string]*collection
+}
+
+var _ milvusrepo.VectorRepository = (*Repository)(nil)
+
+func NewRepository(dir string, cfg Config) (*Repository, error) {
+	if dir == "" {
+		return nil, errors.New("hnsw directory is empty")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Repository{dir: dir, cfg: cfg.withDefaults(), collections: make(map[string]*collection)}, nil
+}
+
+func (r *Repository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for name, coll := range r.collections {
+		coll.mu.Lock()
+		if err := coll.log.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close collection %s: %w", name, err))
+		}
+		coll.mu.Unlock()
+	}
+	r.collections = make(map[string]*collection)
+	return errors.Join(errs...)
+}
+
+func (r *Repository) EnsureCollection(_ context.Context, name string, want milvusrepo.CollectionConfig) error {
+	cfg := want.WithDefaults()
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	coll, err := r.open(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if coll != nil {
+		_, err := want.Adopt(name, milvusrepo.CollectionConfig{Dim: coll.cfg.Dim, MaxPayloadBytes: coll.cfg.MaxPayloadBytes, Metric: coll.cfg.Metric})
+		return err
+	}
+
+	dir := filepath.Join(r.dir, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	meta, err := json.Marshal(collectionMeta{Dim: cfg.Dim, MaxPayloadBytes: cfg.MaxPayloadBytes, Metric: string(cfg.Metric)})
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.Write(filepath.Join(dir, metaFileName), meta); err != nil {
+		return err
+	}
+
+	_, err = r.open(name)
+	return err
+}
+
+// open returns a cached collection or loads it from disk; the caller holds
+// r.mu. A missing collection yields an error wrapping os.ErrNotExist.
+func (r *Repository) open(name string) (*collection, error) {
+	if coll, ok := r.collections[name]; ok {
+		return coll, nil
+	}
+
+	dir := filepath.Join(r.dir, name)
+	data, err := os.ReadFile(filepath.Join(dir, metaFileName))
+	if err != nil {
+		return nil, err
+	}
+	var meta collectionMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("decode %s meta: %w", name, err)
+	}
+
+	coll := &collection{
+		dir: dir,
+		cfg: milvusrepo.CollectionConfig{
+			Dim:             meta.Dim,
+			MaxPayloadBytes: meta.MaxPayloadBytes,
+			Metric:          milvusrepo.Metric(meta.Metric),
+		}.WithDefaults(),
+		items: make(map[int64]milvusrepo.VectorItem),
+		log:   wal.New(filepath.Join(dir, logFileName)),
+	}
+	_, err = wal.Replay(coll.log, func(rec logRecord) {
+		switch rec.Op {
+		case opUpsert:
+			for _, item := range rec.Items {
+				coll.items[item.ID] = item.vectorItem()
+			}
+			coll.entries += len(rec.Items)
+		case opDelete:
+			for _, id := range rec.IDs {
+				delete(coll.items, id)
+			}
+			coll.entries += len(rec.IDs)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	coll.rebuild(r.cfg)
+	r.collections[name] = coll
+	return coll, nil
+}
+
+func (r *Repository) collection(name string) (*collection, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	coll, err := r.open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("collection %s does not exist", name)
+	}
+	return coll, err
+}
+
+func (r *Repository) Upsert(_ context.Context, name string, items []milvusrepo.VectorItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	coll, err := r.collection(name)
+	if err != nil {
+		return err
+	}
+
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+
+	rec := logRecord{Op: opUpsert, Items: make([]logItem, 0, len(items))}
+	for _, item := range items {
+		if len(item.Embedding) == 0 {
+			return fmt.Errorf("empty embedding for item id %d", item.ID)
+		}
+		if len(item.Embedding) != coll.cfg.Dim {
+			return fmt.Errorf("embedding dimension mismatch for item id %d", item.ID)
+		}
+		if strings.TrimSpace(item.DataSource) == "" {
+			return fmt.Errorf("data_source is required for item id %d", item.ID)
+		}
+		if limit := coll.cfg.MaxPayloadBytes; len(item.Payload) > limit {
+			return fmt.Errorf("%w: item id %d has %d bytes, limit %d", milvusrepo.ErrPayloadTooLarge, item.ID, len(item.Payload), limit)
+		}
+		rec.Items = append(rec.Items, toLogItem(item.Clone()))
+	}
+
+	if err := coll.log.Append(rec); err != nil {
+		return err
+	}
+	for _, item := range rec.Items {
+		coll.put(item.vectorItem())
+	}
+	coll.entries += len(rec.Items)
+
+	return coll.maybeCompact(r.cfg)
+}
+
+func (r *Repository) Delete(_ context.Context, name string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	coll, err := r.collection(name)
+	if err != nil {
+		return err
+	}
+
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+
+	if err := coll.log.Append(logRecord{Op: opDelete, IDs: ids}); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		coll.remove(id)
+	}
+	coll.entries += len(ids)
+
+	return coll.maybeCompact(r.cfg)
+}
+
+func (r *Repository) Get(_ context.Context, name string, ids []int64) ([]milvusrepo.VectorItem, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	coll, err := r.collection(name)
+	if err != nil {
+		return nil, err
+	}
+
+	coll.mu.RLock()
+	defer coll.mu.RUnlock()
+
+	items := make([]milvusrepo.VectorItem, 0, len(ids))
+	for _, id := range ids {
+		if item, ok := coll.items[id]; ok {
+			items = append(items, item.Clone())
+		}
+	}
+	return items, nil
+}
+
+func (r *Repository) Search(ctx context.Context, name string, req milvusrepo.SearchRequest) ([]milvusrepo.SearchHit, error) {
+	hits, err := r.SearchBatch(ctx, name, [][]float32{req.Vector}, req)
+	if err != nil {
+		return nil, err
+	}
+	return hits[0], nil
+}
+
+// SearchBatch walks the graph for unfiltered requests only. A request with
+// a filter does not use the index at all: it scans every item of the
+// collection and scores the matches exactly, since a filtered graph walk
+// would miss most of them. Scores are normalized like the Milvus ones.
+func (r *Repository) SearchBatch(_ context.Context, name string, vectors [][]float32, req milvusrepo.SearchRequest) ([][]milvusrepo.SearchHit, error) {
+	for _, vector := range vectors {
+		req.Vector = vector
+		if err := req.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if len(vectors) == 0 {
+		return nil, nil
+	}
+
+	coll, err := r.searchable(name, vectors)
+	if err != nil {
+		return nil, err
+	}
+
+	coll.mu.RLock()
+	defer coll.mu.RUnlock()
+
+	batch := make([][]milvusrepo.SearchHit, len(vectors))
+	for i, vector := range vectors {
+		req.Vector = vector
+		batch[i] = req.Window(coll.search(req, r.cfg.EfSearch))
+	}
+	return batch, nil
+}
+
+func (c *collection) search(req milvusrepo.SearchRequest, ef int) []milvusrepo.SearchHit {
+	if req.Filter == nil {
+		found := c.graph.search(req.Vector, req.Offset+req.TopK, ef)
+		hits := make([]milvusrepo.SearchHit, 0, len(found))
+		for _, f := range found {
+			hits = append(hits, c.items[c.graph.nodes[f.node].id].Hit(c.graph.score(f.distance)))
+		}
+		return hits
+	}
+
+	hits := make([]milvusrepo.SearchHit, 0)
+	for _, item := range c.items {
+		if req.Filter.Match(item) {
+			hits = append(hits, item.Hit(milvusrepo.Similarity(c.cfg.Metric, req.Vector, item.Embedding)))
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	return hits
+}
+
+func (r *Repository) searchable(name string, vectors [][]float32) (*collection, error) {
+	coll, err := r.collection(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, vector := range vectors {
+		if len(vector) != coll.cfg.Dim {
+			return nil, fmt.Errorf("query vector has dim %d, collection %s has %d", len(vector), name, coll.cfg.Dim)
+		}
+	}
+	return coll, nil
+}
+
+func (r *Repository) ListCollections(_ context.Context) ([]string, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(r.dir, entry.Name(), metaFileName)); err == nil {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// DescribeCollection reports the index parameters of this repository; the
+// graph is rebuilt with them whenever a collection is opened.
+func (r *Repository) DescribeCollection(_ context.Context, name string) (milvusrepo.CollectionInfo, error) {
+	coll, err := r.collection(name)
+	if err != nil {
+		return milvusrepo.CollectionInfo{}, err
+	}
+
+	coll.mu.RLock()
+	defer coll.mu.RUnlock()
+
+	cfg := coll.cfg
+	cfg.Index = milvusrepo.IndexHNSW
+	cfg.M = r.cfg.M
+	cfg.EfConstruction = r.cfg.EfConstruction
+	cfg.Ef = r.cfg.EfSearch
+	return milvusrepo.CollectionInfo{Name: name, Config: cfg, Count: int64(len(coll.items))}, nil
+}
+
+// Scan copies the collection before calling fn, so fn may change it.
+func (r *Repository) Scan(_ context.Context, name string, req milvusrepo.ScanRequest, fn func([]milvusrepo.VectorItem) error) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	coll, err := r.collection(name)
+	if err != nil {
+		return err
+	}
+
+	coll.mu.RLock()
+	items := make([]milvusrepo.VectorItem, 0, len(coll.items))
+	for _, item := range coll.items {
+		items = append(items, item.Clone())
+	}
+	coll.mu.RUnlock()
+
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return req.Walk(items, fn)
+}
+
+func (r *Repository) DropCollection(_ context.Context, name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	coll, err := r.open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("collection %s does not exist", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+
+	if err := coll.log.Close(); err != nil {
+		return err
+	}
+	delete(r.collections, name)
+	return os.RemoveAll(coll.dir)
+}
+
+func (r *Repository) Compact(_ context.Context, name string) error {
+	coll, err := r.collection(name)
+	if err != nil {
+		return err
+	}
+
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+
+	return coll.compact(r.cfg)
+}
+
+func (c *collection) put(item milvusrepo.VectorItem) {
+	if idx, ok := c.nodes[item.ID]; ok {
+		c.graph.remove(idx)
+	}
+	c.items[item.ID] = item
+	c.nodes[item.ID] = c.graph.insert(item.ID, item.Embedding)
+}
+
+func (c *collection) remove(id int64) {
+	if idx, ok := c.nodes[id]; ok {
+		c.graph.remove(idx)
+		delete(c.nodes, id)
+	}
+	delete(c.items, id)
+}
+
+// rebuild inserts the items in ID order, so an index reopened from the same
+// log always has the same graph.
+func (c *collection) rebuild(cfg Config) {
+	ids := make([]int64, 0, len(c.items))
+	for id := range c.items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	c.graph = newGraph(cfg.M, cfg.EfConstruction, c.cfg.Metric)
+	c.nodes = make(map[int64]int32, len(ids))
+	for _, id := range ids {
+		c.nodes[id] = c.graph.insert(id, c.items[id].Embedding)
+	}
+}
+
+// maybeCompact compacts once overwritten and deleted entries dominate the
+// log.
+func (c *collection) maybeCompact(cfg Config) error {
+	if c.entries < compactMinEntries || c.entries < 2*len(c.items) {
+		return nil
+	}
+	return c.compact(cfg)
+}
+
+// compact rewrites the log with the live items and drops the deleted nodes
+// from the graph.
+func (c *collection) compact(cfg Config) error {
+	ids := make([]int64, 0, len(c.items))
+	for id := range c.items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	recs := make([]any, 0, len(ids)/compactBatchSize+1)
+	for start := 0; start < len(ids); start += compactBatchSize {
+		rec := logRecord{Op: opUpsert}
+		for _, id := range ids[start:min(start+compactBatchSize, len(ids))] {
+			rec.Items = append(rec.Items, toLogItem(c.items[id]))
+		}
+		recs = append(recs, rec)
+	}
+	if err := c.log.Rewrite(recs...); err != nil {
+		return err
+	}
+
+	c.entries = len(ids)
+	c.rebuild(cfg)
+	return nil
+}
+
+func validateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid collection name %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package lexical
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"rag-test/internal/helpers"
+)
+
+// BM25 parameters with the usual defaults.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+type Hit struct {
+	ID    int64
+	Score float64
+}
+
+type document struct {
+	Terms  map[string]int `json:"terms"`
+	Length int            `json:"length"`
+}
+
+// Index is an in-memory BM25 index over helpers.Tokenize terms. It is safe
+// for concurrent use.
+type Index struct {
+	mu          sync.RWMutex
+	docs        map[int64]document
+	postings    map[string]map[int64]int
+	totalLength int
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[int64]document),
+		postings: make(map[string]map[int64]int),
+	}
+}
+
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.docs)
+}
+
+// Put indexes text under id, replacing what was indexed before.
+func (x *Index) Put(id int64, text string) {
+	x.put(id, newDocument(text))
+}
+
+func (x *Index) put(id int64, doc document) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.remove(id)
+	x.add(id, doc)
+}
+
+func (x *Index) Delete(ids []int64) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for _, id := range ids {
+		x.remove(id)
+	}
+}
+
+// Search ranks the documents containing any query term by BM25 and returns
+// the topK best, highest score first.
+func (x *Index) Search(query string, topK int) []Hit {
+	if topK <= 0 {
+		return nil
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	if len(x.docs) == 0 {
+		return nil
+	}
+
+	n := float64(len(x.docs))
+	avgLength := float64(x.totalLength) / n
+	scores := make(map[int64]float64)
+
+	seen := make(map[string]struct{})
+	for _, term := range helpers.Tokenize(query) {
+		if _, ok := seen[term]; ok {
+			continue
+		}
+		seen[term] = struct{}{}
+
+		posting := x.postings[term]
+		if len(posting) == 0 {
+			continue
+		}
+		df := float64(len(posting))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range posting {
+			length := float64(x.docs[id].Length)
+			freq := float64(tf)
+			scores[id] += idf * freq * (bm25K1 + 1) / (freq + bm25K1*(1-bm25B+bm25B*length/avgLength))
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if len(hits) > topK {
+		hits = hits[:topK]
+	}
+	return hits
+}
+
+func newDocument(text string) document {
+	terms := make(map[string]int)
+	length := 0
+	for _, term := range helpers.Tokenize(text) {
+		terms[term]++
+		length++
+	}
+	return document{Terms: terms, Length: length}
+}
+
+func (x *Index) add(id int64, doc document) {
+	x.docs[id] = doc
+	x.totalLength += doc.Length
+	for term, tf := range doc.Terms {
+		posting, ok := x.postings[term]
+		if !ok {
+			posting = make(map[int64]int)
+			x.postings[term] = posting
+		}
+		posting[id] = tf
+	}
+}
+
+func (x *Index) remove(id int64) {
+	doc, ok := x.docs[id]
+	if !ok {
+		return
+	}
+
+	delete(x.docs, id)
+	x.totalLength -= doc.Length
+	for term := range doc.Terms {
+		delete(x.postings[term], id)
+		if len(x.postings[term]) == 0 {
+			delete(x.postings, term)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package lexical
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/storage/atomicfile"
+	"rag-test/internal/storage/wal"
+)
+
+const (
+	indexVersion = 1
+
+	// compactMinEntries keeps small logs from being folded into the index
+	// file on every change.
+	compactMinEntries = 1024
+)
+
+type indexFile struct {
+	Version int                `json:"version"`
+	Docs    map[int64]document `json:"docs"`
+}
+
+// logRecord is one change appended to the log of a collection: the
+// documents put and the ids deleted.
+type logRecord struct {
+	Docs map[int64]document `json:"docs,omitempty"`
+	IDs  []int64            `json:"ids,omitempty"`
+}
+
+// Repository wraps a VectorRepository and keeps a BM25 index of the chunks
+// written to the given collections. Every collection has an index file
+// under dir and a log of the changes since it was written; the log is
+// folded into the file once it outgrows the index and on Close. It sees the
+// full payloads, so it belongs outside the textstore wrapper.
+type Repository struct {
+	milvusrepo.VectorRepository
+
+	dir string
+
+	mu          sync.Mutex
+	collections map[string]struct{}
+	indexes     map[string]*Index
+	logs        map[string]*wal.Log
+	entries     map[string]int
+}
+
+func NewRepository(inner milvusrepo.VectorRepository, dir string, collections ...string) (*Repository, error) {
+	if dir == "" {
+		return nil, errors.New("lexical index dir is empty")
+	}
+
+	indexed := make(map[string]struct{}, len(collections))
+	for _, collection := range collections {
+		indexed[collection] = struct{}{}
+	}
+
+	return &Repository{
+		VectorRepository: inner,
+		dir:              dir,
+		collections:      indexed,
+		indexes:          make(map[string]*Index),
+		logs:             make(map[string]*wal.Log),
+		entries:          make(map[string]int),
+	}, nil
+}
+
+// Close folds the logs into the index files and closes the wrapped
+// repository.
+func (r *Repository) Close() error {
+	r.mu.Lock()
+	var errs []error
+	for collection, index := range r.indexes {
+		if r.entries[collection] > 0 {
+			errs = append(errs, r.compact(collection, index))
+		}
+		errs = append(errs, r.logs[collection].Close())
+	}
+	r.mu.Unlock()
+
+	errs = append(errs, r.VectorRepository.Close())
+	return errors.Join(errs...)
+}
+
+func (r *Repository) Upsert(ctx context.Context, collection string, items []milvusrepo.VectorItem) error {
+	if err := r.VectorRepository.Upsert(ctx, collection, items); err != nil {
+		return err
+	}
+	if !r.tracked(collection) || len(items) == 0 {
+		return nil
+	}
+
+	index, err := r.index(collection)
+	if err != nil {
+		return err
+	}
+	docs := make(map[int64]document, len(items))
+	for _, item := range items {
+		docs[item.ID] = newDocument(indexedText(item))
+	}
+	return r.append(collection, index, logRecord{Docs: docs})
+}
+
+func (r *Repository) Delete(ctx context.Context, collection string, ids []int64) error {
+	if err := r.VectorRepository.Delete(ctx, collection, ids); err != nil {
+		return err
+	}
+	if !r.tracked(collection) || len(ids) == 0 {
+		return nil
+	}
+
+	index, err := r.index(collection)
+	if err != nil {
+		return err
+	}
+	return r.append(collection, index, logRecord{IDs: ids})
+}
+
+// DropCollection also removes the index of the collection.
+func (r *Repository) DropCollection(ctx context.Context, name string) error {
+	if err := r.VectorRepository.DropCollection(ctx, name); err != nil {
+		return err
+	}
+	if !r.tracked(name) {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if log, ok := r.logs[name]; ok {
+		if err := log.Close(); err != nil {
+			return err
+		}
+	}
+	delete(r.indexes, name)
+	delete(r.logs, name)
+	delete(r.entries, name)
+	return DropIndex(r.dir, name)
+}
+
+// Track indexes the chunks written to collection from now on, next to the
+// collections the repository was made with.
+func (r *Repository) Track(collection string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collections[collection] = struct{}{}
+}
+
+func (r *Repository) tracked(collection string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.collections[collection]
+	return ok
+}
+
+// SearchText ranks the chunks of collection by BM25 against query.
+func (r *Repository) SearchText(collection, query string, topK int) ([]Hit, error) {
+	index, err := r.index(collection)
+	if err != nil {
+		return nil, err
+	}
+	return index.Search(query, topK), nil
+}
+
+// indexedText adds the title and headings, which often hold the product
+// names a question mentions, to the chunk text.
+func indexedText(item milvusrepo.VectorItem) string {
+	parts := make([]string, 0, len(item.Metadata.HeadingPath)+2)
+	if item.Metadata.Title != "" {
+		parts = append(parts, item.Metadata.Title)
+	}
+	parts = append(parts, item.Metadata.HeadingPath...)
+	parts = append(parts, item.Payload)
+	return strings.Join(parts, "\n")
+}
+
+func (r *Repository) index(collection string) (*Index, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if index, ok := r.indexes[collection]; ok {
+		return index, nil
+	}
+
+	index := NewIndex()
+	data, err := os.ReadFile(r.path(collection))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		var file indexFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("decode lexical index %s: %w", r.path(collection), err)
+		}
+		if file.Version == indexVersion {
+			for id, doc := range file.Docs {
+				index.add(id, doc)
+			}
+		}
+	}
+
+	log := wal.New(logPath(r.dir, collection))
+	entries := 0
+	_, err = wal.Replay(log, func(rec logRecord) {
+		rec.apply(index)
+		entries += len(rec.Docs) + len(rec.IDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.indexes[collection] = index
+	r.logs[collection] = log
+	r.entries[collection] = entries
+	return index, nil
+}
+
+func (rec logRecord) apply(index *Index) {
+	for id, doc := range rec.Docs {
+		index.put(id, doc)
+	}
+	index.Delete(rec.IDs)
+}
+
+// append writes rec to the log of collection, applies it to index and
+// folds the log into the index file once it holds more changes than the
+// index has documents. It is serialized so concurrent writers cannot
+// interleave.
+func (r *Repository) append(collection string, index *Index, rec logRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log, ok := r.logs[collection]
+	if !ok {
+		return fmt.Errorf("lexical index of %s was dropped", collection)
+	}
+	if err := log.Append(rec); err != nil {
+		return err
+	}
+
+	rec.apply(index)
+	r.entries[collection] += len(rec.Docs) + len(rec.IDs)
+	if r.entries[collection] < max(compactMinEntries, index.Len()) {
+		return nil
+	}
+	return r.compact(collection, index)
+}
+
+// compact rewrites the index file through a temporary file and empties the
+// log; the caller holds r.mu. A crash between the two replays the log over
+// the new file, which changes nothing.
+func (r *Repository) compact(collection string, index *Index) error {
+	index.mu.RLock()
+	data, err := json.Marshal(indexFile{Version: indexVersion, Docs: index.docs})
+	index.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if err := atomicfile.Write(r.path(collection), data); err != nil {
+		return err
+	}
+	if err := r.logs[collection].Remove(); err != nil {
+		return err
+	}
+	r.entries[collection] = 0
+	return nil
+}
+
+func (r *Repository) path(collection string) string {
+	return indexPath(r.dir, collection)
+}
+
+// CopyIndex duplicates the index file and log of a collection under dir
+// for another collection name. A collection without an index leaves the
+// other without one too.
+func CopyIndex(dir, from, to string) error {
+	if err := copyFile(indexPath(dir, from), indexPath(dir, to)); err != nil {
+		return err
+	}
+	return copyFile(logPath(dir, from), logPath(dir, to))
+}
+
+// copyFile replaces to with a copy of from, or removes it when from does
+// not exist.
+func copyFile(from, to string) error {
+	data, err := os.ReadFile(from)
+	if errors.Is(err, os.ErrNotExist) {
+		return removeFile(to)
+	}
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.Write(to, data)
+}
+
+func DropIndex(dir, collection string) error {
+	if err := removeFile(indexPath(dir, collection)); err != nil {
+		return err
+	}
+	return removeFile(logPath(dir, collection))
+}
+
+func removeFile(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func indexPath(dir, collection string) string {
+	return filepath.Join(dir, collection+".json")
+}
+
+func logPath(dir, collection string) string {
+	return filepath.Join(dir, collection+".log")
+}
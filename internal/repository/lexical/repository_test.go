@@ -0,0 +1,188 @@
+package lexical
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"rag-test/internal/repository/memory"
+	milvusrepo "rag-test/internal/repository/milvus"
+)
+
+const testCollection = "kb"
+
+func newTestRepository(t *testing.T, dir string) *Repository {
+	t.Helper()
+
+	inner, err := memory.NewRepository(memory.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inner.EnsureCollection(context.Background(), testCollection, milvusrepo.CollectionConfig{Dim: 2}); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(inner, dir, testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func item(id int64, text string) milvusrepo.VectorItem {
+	return milvusrepo.VectorItem{ID: id, Embedding: []float32{1, 0}, Payload: text, DataSource: "doc.md"}
+}
+
+func searchIDs(t *testing.T, repo *Repository, query string) []int64 {
+	t.Helper()
+	hits, err := repo.SearchText(testCollection, query, 10)
+	if err != nil {
+		t.Fatalf("SearchText: %v", err)
+	}
+	ids := make([]int64, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// TestPersistence writes through one repository and reads the index back
+// through a fresh one, as the next run would.
+func TestPersistence(t *testing.T) {
+	tests := []struct {
+		name    string
+		close   bool
+		damage  func(t *testing.T, log string)
+		wantLog bool
+		want    map[string][]int64
+	}{
+		{
+			name:    "log replayed after a crash",
+			wantLog: true,
+			want:    map[string][]int64{"chair": {1}, "lamp": {3}, "table": nil},
+		},
+		{
+			name:  "log folded into the index on close",
+			close: true,
+			want:  map[string][]int64{"chair": {1}, "lamp": {3}, "table": nil},
+		},
+		{
+			name: "torn last record dropped",
+			damage: func(t *testing.T, log string) {
+				f, err := os.OpenFile(log, os.O_WRONLY|os.O_APPEND, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				if _, err := f.WriteString(`{"docs":{"4":{"terms":{"so`); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantLog: true,
+			want:    map[string][]int64{"chair": {1}, "lamp": {3}, "sofa": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			log := logPath(dir, testCollection)
+
+			repo := newTestRepository(t, dir)
+			if err := repo.Upsert(ctx, testCollection, []milvusrepo.VectorItem{item(1, "office chair"), item(2, "oak table")}); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Upsert(ctx, testCollection, []milvusrepo.VectorItem{item(3, "desk lamp")}); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Delete(ctx, testCollection, []int64{2}); err != nil {
+				t.Fatal(err)
+			}
+			if tt.close {
+				if err := repo.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.damage != nil {
+				tt.damage(t, log)
+			}
+
+			if _, err := os.Stat(log); (err == nil) != tt.wantLog {
+				t.Errorf("log exists = %v, want %v", err == nil, tt.wantLog)
+			}
+			if _, err := os.Stat(indexPath(dir, testCollection)); (err == nil) != tt.close {
+				t.Errorf("index file exists = %v, want %v", err == nil, tt.close)
+			}
+
+			reopened := newTestRepository(t, dir)
+			for query, want := range tt.want {
+				if got := searchIDs(t, reopened, query); !slices.Equal(got, want) {
+					t.Errorf("search %q = %v, want %v", query, got, want)
+				}
+			}
+
+			// A reopened log takes new records after a truncated tail.
+			if err := reopened.Upsert(ctx, testCollection, []milvusrepo.VectorItem{item(5, "sofa")}); err != nil {
+				t.Fatal(err)
+			}
+			if got := searchIDs(t, newTestRepository(t, dir), "sofa"); !slices.Equal(got, []int64{5}) {
+				t.Errorf("search sofa after reopening = %v, want [5]", got)
+			}
+		})
+	}
+}
+
+func TestCompactLargeLog(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo := newTestRepository(t, dir)
+
+	items := make([]milvusrepo.VectorItem, 0, compactMinEntries)
+	for id := range int64(compactMinEntries) {
+		items = append(items, item(id+1, "chair"))
+	}
+	if err := repo.Upsert(ctx, testCollection, items); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(logPath(dir, testCollection)); !os.IsNotExist(err) {
+		t.Errorf("log kept after %d changes: %v", compactMinEntries, err)
+	}
+	if got := len(searchIDs(t, newTestRepository(t, dir), "chair")); got != 10 {
+		t.Errorf("reopened index returned %d hits, want 10", got)
+	}
+}
+
+func TestCopyAndDropIndex(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo := newTestRepository(t, dir)
+	if err := repo.Upsert(ctx, testCollection, []milvusrepo.VectorItem{item(1, "chair")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CopyIndex(dir, testCollection, "copy"); err != nil {
+		t.Fatalf("CopyIndex: %v", err)
+	}
+	copied, err := NewRepository(repo.VectorRepository, dir, "copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits, err := copied.SearchText("copy", "chair", 10); err != nil || len(hits) != 1 {
+		t.Errorf("copied index search = %v, %v, want one hit", hits, err)
+	}
+
+	if err := DropIndex(dir, "copy"); err != nil {
+		t.Fatalf("DropIndex: %v", err)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "copy.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) > 0 {
+		t.Errorf("files left after DropIndex: %v", matches)
+	}
+}
@@ -0,0 +1,37 @@
+package manifest
+
+import "time"
+
+const currentVersion = 1
+
+type Manifest struct {
+	Version    int                  `json:"version"`
+	Collection string               `json:"collection"`
+	Files      map[string]FileEntry `json:"files"`
+}
+
+type FileEntry struct {
+	Path           string    `json:"path"`
+	Hash           string    `json:"hash"`
+	ModTime        time.Time `json:"mod_time"`
+	ChunkIDs       []int64   `json:"chunk_ids"`
+	Fingerprints   []uint64  `json:"fingerprints,omitempty"`
+	QuestionIDs    []int64   `json:"question_ids,omitempty"`
+	Questions      int       `json:"questions,omitempty"`
+	Context        string    `json:"context,omitempty"`
+	SectionIDs     []int64   `json:"section_ids,omitempty"`
+	SectionTokens  int       `json:"section_tokens,omitempty"`
+	EmbeddingModel string    `json:"embedding_model"`
+	Dim            int       `json:"dim"`
+	Pipeline       int       `json:"pipeline"`
+	Splitter       string    `json:"splitter,omitempty"`
+	IngestedAt     time.Time `json:"ingested_at"`
+}
+
+func New(collection string) *Manifest {
+	return &Manifest{
+		Version:    currentVersion,
+		Collection: collection,
+		Files:      make(map[string]FileEntry),
+	}
+}
@@ -0,0 +1,92 @@
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"rag-test/internal/storage/atomicfile"
+)
+
+// ErrMismatch is returned by Load when the manifest file was written for
+// another collection or in an unknown format. Its file IDs point into that
+// collection, so starting over with an empty manifest would orphan them.
+var ErrMismatch = errors.New("manifest does not match")
+
+type Repository struct {
+	path string
+}
+
+func NewRepository(path string) (*Repository, error) {
+	if path == "" {
+		return nil, errors.New("manifest path is empty")
+	}
+
+	return &Repository{path: path}, nil
+}
+
+func (r *Repository) Path() string {
+	return r.path
+}
+
+// Load reads the manifest of collection; a missing file yields an empty one.
+func (r *Repository) Load(collection string) (*Manifest, error) {
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return New(collection), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("decode manifest %s: %w", r.path, err)
+	}
+	if m.Version != currentVersion {
+		return nil, fmt.Errorf("%w: %s has version %d, want %d", ErrMismatch, r.path, m.Version, currentVersion)
+	}
+	if m.Collection != collection {
+		return nil, fmt.Errorf("%w: %s belongs to collection %s, not %s; use another manifest path or remove the file", ErrMismatch, r.path, m.Collection, collection)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]FileEntry)
+	}
+
+	return &m, nil
+}
+
+func (r *Repository) Save(m *Manifest) error {
+	if m == nil {
+		return errors.New("manifest is nil")
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.Write(r.path, data)
+}
+
+// Remove deletes the manifest when it belongs to collection, so that the
+// next run ingests every file into it again.
+func (r *Repository) Remove(collection string) error {
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("decode manifest %s: %w", r.path, err)
+	}
+	if m.Collection != collection {
+		return nil
+	}
+	return os.Remove(r.path)
+}
@@ -0,0 +1,103 @@
+package manifest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		wantFiles int
+		wantErr   error
+	}{
+		{
+			name: "missing file",
+		},
+		{
+			name:      "same collection",
+			file:      `{"version":1,"collection":"kb","files":{"a.md":{"path":"a.md","chunk_ids":[1,2]}}}`,
+			wantFiles: 1,
+		},
+		{
+			name: "no files",
+			file: `{"version":1,"collection":"kb"}`,
+		},
+		{
+			name:    "other collection",
+			file:    `{"version":1,"collection":"other","files":{"a.md":{"path":"a.md","chunk_ids":[1,2]}}}`,
+			wantErr: ErrMismatch,
+		},
+		{
+			name:    "unknown version",
+			file:    `{"version":2,"collection":"kb","files":{}}`,
+			wantErr: ErrMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "manifest.json")
+			if tt.file != "" {
+				if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			repo, err := NewRepository(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m, err := repo.Load("kb")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Load error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if m.Collection != "kb" || m.Files == nil {
+				t.Fatalf("Load = %+v, want a manifest of kb with a files map", m)
+			}
+			if len(m.Files) != tt.wantFiles {
+				t.Errorf("got %d files, want %d", len(m.Files), tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestSaveAndRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "manifest.json")
+	repo, err := NewRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := New("kb")
+	m.Files["a.md"] = FileEntry{Path: "a.md", Hash: "h", ChunkIDs: []int64{1, 2}}
+	if err := repo.Save(m); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := repo.Load("kb")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := loaded.Files["a.md"]; got.Hash != "h" || len(got.ChunkIDs) != 2 {
+		t.Errorf("loaded entry = %+v", got)
+	}
+
+	if err := repo.Remove("other"); err != nil {
+		t.Fatalf("Remove of another collection: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("manifest of kb removed for another collection: %v", err)
+	}
+	if err := repo.Remove("kb"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("manifest still exists after Remove: %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/storage/wal"
+)
+
+// Config selects an optional snapshot file. The metric comes from the
+// collection config and scores are normalized like the Milvus ones.
+type Config struct {
+	SnapshotPath string
+}
+
+type collection struct {
+	cfg   milvusrepo.CollectionConfig
+	items map[int64]milvusrepo.VectorItem
+}
+
+// Repository is a pure-Go milvus.VectorRepository with exact search for
+// tests and offline runs. With a snapshot path every change is appended to
+// a log next to the file, so the index stays in step with the ingestion
+// manifest; the log is folded into the snapshot once it outgrows the store,
+// on Compact and on Close.
+type Repository struct {
+	cfg Config
+
+	mu          sync.RWMutex
+	collections map[string]*collection
+	log         *wal.Log
+	entries     int
+}
+
+var _ milvusrepo.VectorRepository = (*Repository)(nil)
+
+// NewRepository loads the snapshot and its log when they exist.
+func NewRepository(cfg Config) (*Repository, error) {
+	r := &Repository{cfg: cfg, collections: make(map[string]*collection)}
+	if cfg.SnapshotPath != "" {
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Close folds the log into the snapshot.
+func (r *Repository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.log == nil {
+		return nil
+	}
+	if r.entries == 0 {
+		return r.log.Close()
+	}
+	return r.persist()
+}
+
+func (r *Repository) EnsureCollection(_ context.Context, name string, want milvusrepo.CollectionConfig) error {
+	cfg := want.WithDefaults()
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.collections[name]; ok {
+		_, err := want.Adopt(name, milvusrepo.CollectionConfig{Dim: existing.cfg.Dim, MaxPayloadBytes: existing.cfg.MaxPayloadBytes, Metric: existing.cfg.Metric})
+		return err
+	}
+
+	return r.record(logRecord{
+		Op:         opEnsure,
+		Collection: name,
+		Config:     &snapshotCollection{Dim: cfg.Dim, MaxPayloadBytes: cfg.MaxPayloadBytes, Metric: string(cfg.Metric)},
+	})
+}
+
+func (r *Repository) Upsert(_ context.Context, name string, items []milvusrepo.VectorItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	coll, err := r.collection(name)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if len(item.Embedding) == 0 {
+			return fmt.Errorf("empty embedding for item id %d", item.ID)
+		}
+		if len(item.Embedding) != coll.cfg.Dim {
+			return fmt.Errorf("embedding dimension mismatch for item id %d", item.ID)
+		}
+		if strings.TrimSpace(item.DataSource) == "" {
+			return fmt.Errorf("data_source is required for item id %d", item.ID)
+		}
+		if limit := coll.cfg.MaxPayloadBytes; len(item.Payload) > limit {
+			return fmt.Errorf("%w: item id %d has %d bytes, limit %d", milvusrepo.ErrPayloadTooLarge, item.ID, len(item.Payload), limit)
+		}
+	}
+
+	rec := logRecord{Op: opUpsert, Collection: name, Items: make([]snapshotItem, 0, len(items))}
+	for _, item := range items {
+		rec.Items = append(rec.Items, toSnapshotItem(item.Clone()))
+	}
+	return r.record(rec)
+}
+
+func (r *Repository) Delete(_ context.Context, name string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.collection(name); err != nil {
+		return err
+	}
+	return r.record(logRecord{Op: opDelete, Collection: name, IDs: ids})
+}
+
+func (r *Repository) Get(_ context.Context, name string, ids []int64) ([]milvusrepo.VectorItem, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coll, err := r.collection(name)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]milvusrepo.VectorItem, 0, len(ids))
+	for _, id := range ids {
+		if item, ok := coll.items[id]; ok {
+			items = append(items, item.Clone())
+		}
+	}
+	return items, nil
+}
+
+func (r *Repository) Search(ctx context.Context, name string, req milvusrepo.SearchRequest) ([]milvusrepo.SearchHit, error) {
+	hits, err := r.SearchBatch(ctx, name, [][]float32{req.Vector}, req)
+	if err != nil {
+		return nil, err
+	}
+	return hits[0], nil
+}
+
+// SearchBatch scans the whole collection per vector, evaluating the filter
+// on every item; ties are broken by ID so results are stable between runs.
+func (r *Repository) SearchBatch(_ context.Context, name string, vectors [][]float32, req milvusrepo.SearchRequest) ([][]milvusrepo.SearchHit, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coll, err := r.collection(name)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := make([][]milvusrepo.SearchHit, len(vectors))
+	for i, vector := range vectors {
+		req.Vector = vector
+		if err := req.Validate(); err != nil {
+			return nil, err
+		}
+		if len(vector) != coll.cfg.Dim {
+			return nil, fmt.Errorf("query vector has dim %d, collection %s has %d", len(vector), name, coll.cfg.Dim)
+		}
+		batch[i] = coll.search(req)
+	}
+	return batch, nil
+}
+
+func (c *collection) search(req milvusrepo.SearchRequest) []milvusrepo.SearchHit {
+	hits := make([]milvusrepo.SearchHit, 0)
+	for _, item := range c.items {
+		if req.Filter != nil && !req.Filter.Match(item) {
+			continue
+		}
+		hits = append(hits, item.Hit(milvusrepo.Similarity(c.cfg.Metric, req.Vector, item.Embedding)))
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	return req.Window(hits)
+}
+
+func (r *Repository) ListCollections(_ context.Context) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.collections))
+	for name := range r.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DescribeCollection reports a flat index, which is what the exact search
+// amounts to.
+func (r *Repository) DescribeCollection(_ context.Context, name string) (milvusrepo.CollectionInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coll, err := r.collection(name)
+	if err != nil {
+		return milvusrepo.CollectionInfo{}, err
+	}
+	cfg := coll.cfg
+	cfg.Index = milvusrepo.IndexFlat
+	return milvusrepo.CollectionInfo{Name: name, Config: cfg, Count: int64(len(coll.items))}, nil
+}
+
+// Scan copies the collection before calling fn, so fn may change it.
+func (r *Repository) Scan(_ context.Context, name string, req milvusrepo.ScanRequest, fn func([]milvusrepo.VectorItem) error) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	coll, err := r.collection(name)
+	if err != nil {
+		r.mu.RUnlock()
+		return err
+	}
+	items := make([]milvusrepo.VectorItem, 0, len(coll.items))
+	for _, item := range coll.items {
+		if req.Embeddings {
+			item = item.Clone()
+		} else {
+			item.Embedding = nil
+			item.Metadata = item.Metadata.Clone()
+		}
+		items = append(items, item)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return req.Walk(items, fn)
+}
+
+func (r *Repository) DropCollection(_ context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.collection(name); err != nil {
+		return err
+	}
+	return r.record(logRecord{Op: opDrop, Collection: name})
+}
+
+// Compact folds the log into the snapshot; deleted items are already gone
+// from memory.
+func (r *Repository) Compact(_ context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.collection(name); err != nil {
+		return err
+	}
+	if r.entries == 0 {
+		return nil
+	}
+	return r.persist()
+}
+
+func (r *Repository) collection(name string) (*collection, error) {
+	coll, ok := r.collections[name]
+	if !ok {
+		return nil, fmt.Errorf("collection %s does not exist", name)
+	}
+	return coll, nil
+}
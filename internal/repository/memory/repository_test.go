@@ -0,0 +1,355 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	milvusrepo "rag-test/internal/repository/milvus"
+)
+
+const testCollection = "kb"
+
+func item(id int64, source string, embedding ...float32) milvusrepo.VectorItem {
+	return milvusrepo.VectorItem{ID: id, Embedding: embedding, Payload: "chunk", DataSource: source}
+}
+
+func openRepository(t *testing.T, path string) *Repository {
+	t.Helper()
+	repo, err := NewRepository(Config{SnapshotPath: path})
+	if err != nil {
+		t.Fatalf("NewRepository: %v", err)
+	}
+	return repo
+}
+
+func hitIDs(hits []milvusrepo.SearchHit) []int64 {
+	ids := make([]int64, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func storedIDs(t *testing.T, repo *Repository, collection string) []int64 {
+	t.Helper()
+	var ids []int64
+	err := repo.Scan(context.Background(), collection, milvusrepo.ScanRequest{}, func(items []milvusrepo.VectorItem) error {
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	repo := openRepository(t, "")
+	if err := repo.EnsureCollection(ctx, testCollection, milvusrepo.CollectionConfig{Dim: 2, Metric: milvusrepo.MetricCosine}); err != nil {
+		t.Fatal(err)
+	}
+	err := repo.Upsert(ctx, testCollection, []milvusrepo.VectorItem{
+		item(1, "a.md", 1, 0),
+		item(2, "b.md", 0.9, 0.1),
+		item(3, "a.md", 0, 1),
+		item(4, "b.md", 1, 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  milvusrepo.SearchRequest
+		want []int64
+	}{
+		{
+			name: "ties broken by id",
+			req:  milvusrepo.SearchRequest{Vector: []float32{1, 0}, TopK: 3},
+			want: []int64{1, 4, 2},
+		},
+		{
+			name: "offset",
+			req:  milvusrepo.SearchRequest{Vector: []float32{1, 0}, TopK: 2, Offset: 2},
+			want: []int64{2, 3},
+		},
+		{
+			name: "filter",
+			req:  milvusrepo.SearchRequest{Vector: []float32{1, 0}, TopK: 10, Filter: milvusrepo.Eq(milvusrepo.FieldDataSource, "a.md")},
+			want: []int64{1, 3},
+		},
+		{
+			name: "min score",
+			req:  milvusrepo.SearchRequest{Vector: []float32{1, 0}, TopK: 10, MinScore: 0.5},
+			want: []int64{1, 4, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := repo.Search(ctx, testCollection, tt.req)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if got := hitIDs(hits); !slices.Equal(got, tt.want) {
+				t.Errorf("hits = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := repo.Search(ctx, testCollection, milvusrepo.SearchRequest{Vector: []float32{1}, TopK: 1}); err == nil {
+		t.Error("Search with a vector of the wrong dimension succeeded")
+	}
+	if err := repo.Upsert(ctx, testCollection, []milvusrepo.VectorItem{item(5, "", 1, 0)}); err == nil {
+		t.Error("Upsert without data_source succeeded")
+	}
+}
+
+func TestSearchBatch(t *testing.T) {
+	ctx := context.Background()
+	repo := openRepository(t, "")
+	if err := repo.EnsureCollection(ctx, testCollection, milvusrepo.CollectionConfig{Dim: 2, Metric: milvusrepo.MetricCosine}); err != nil {
+		t.Fatal(err)
+	}
+	err := repo.Upsert(ctx, testCollection, []milvusrepo.VectorItem{
+		item(1, "a.md", 1, 0),
+		item(2, "b.md", 0.9, 0.1),
+		item(3, "a.md", 0, 1),
+		item(4, "b.md", 0.1, 0.9),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := [][]float32{{0, 1}, {1, 0}}
+
+	tests := []struct {
+		name string
+		req  milvusrepo.SearchRequest
+		want [][]int64
+	}{
+		{
+			name: "one hit per query in query order",
+			req:  milvusrepo.SearchRequest{TopK: 1},
+			want: [][]int64{{3}, {1}},
+		},
+		{
+			name: "topK and offset per query",
+			req:  milvusrepo.SearchRequest{TopK: 2, Offset: 1},
+			want: [][]int64{{4, 2}, {2, 4}},
+		},
+		{
+			name: "filter per query",
+			req:  milvusrepo.SearchRequest{TopK: 10, Filter: milvusrepo.Eq(milvusrepo.FieldDataSource, "b.md")},
+			want: [][]int64{{4, 2}, {2, 4}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch, err := repo.SearchBatch(ctx, testCollection, queries, tt.req)
+			if err != nil {
+				t.Fatalf("SearchBatch: %v", err)
+			}
+			if len(batch) != len(tt.want) {
+				t.Fatalf("got %d result lists, want %d", len(batch), len(tt.want))
+			}
+			for i, hits := range batch {
+				if got := hitIDs(hits); !slices.Equal(got, tt.want[i]) {
+					t.Errorf("query %d: hits = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+
+	if batch, err := repo.SearchBatch(ctx, testCollection, nil, milvusrepo.SearchRequest{TopK: 1}); err != nil || len(batch) != 0 {
+		t.Errorf("SearchBatch without vectors = %v, %v; want no results", batch, err)
+	}
+	if _, err := repo.SearchBatch(ctx, testCollection, [][]float32{{1, 0}, {1}}, milvusrepo.SearchRequest{TopK: 1}); err == nil {
+		t.Error("SearchBatch with a vector of the wrong dimension succeeded")
+	}
+}
+
+func TestValidation(t *testing.T) {
+	ctx := context.Background()
+	repo := openRepository(t, "")
+	if err := repo.EnsureCollection(ctx, testCollection, milvusrepo.CollectionConfig{Dim: 2, MaxPayloadBytes: 8}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{name: "unknown collection", run: func() error { return repo.Upsert(ctx, "other", []milvusrepo.VectorItem{item(1, "a.md", 1, 0)}) }},
+		{name: "wrong dimension", run: func() error { return repo.Upsert(ctx, testCollection, []milvusrepo.VectorItem{item(1, "a.md", 1)}) }},
+		{name: "missing data source", run: func() error { return repo.Upsert(ctx, testCollection, []milvusrepo.VectorItem{item(1, " ", 1, 0)}) }},
+		{
+			name: "payload too large",
+			run: func() error {
+				big := item(1, "a.md", 1, 0)
+				big.Payload = "123456789"
+				return repo.Upsert(ctx, testCollection, []milvusrepo.VectorItem{big})
+			},
+		},
+		{name: "query of the wrong dimension", run: func() error {
+			_, err := repo.Search(ctx, testCollection, milvusrepo.SearchRequest{Vector: []float32{1}, TopK: 1})
+			return err
+		}},
+		{name: "other dimension", run: func() error { return repo.EnsureCollection(ctx, testCollection, milvusrepo.CollectionConfig{Dim: 3}) }},
+		{name: "unknown metric", run: func() error {
+			return repo.EnsureCollection(ctx, "other", milvusrepo.CollectionConfig{Dim: 2, Metric: "HAMMING"})
+		}},
+		{name: "other metric", run: func() error {
+			return repo.EnsureCollection(ctx, testCollection, milvusrepo.CollectionConfig{Dim: 2, Metric: milvusrepo.MetricIP})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); err == nil {
+				t.Error("succeeded, want an error")
+			}
+		})
+	}
+}
+
+// TestPersistence writes through one repository and reads the store back
+// through a fresh one, as the next run would.
+func TestPersistence(t *testing.T) {
+	tests := []struct {
+		name string
+		// finish runs after the writes instead of a clean shutdown.
+		finish       func(t *testing.T, repo *Repository, path string)
+		wantLog      bool
+		wantSnapshot bool
+	}{
+		{
+			name:    "log replayed after a crash",
+			finish:  func(*testing.T, *Repository, string) {},
+			wantLog: true,
+		},
+		{
+			name: "log folded into the snapshot on close",
+			finish: func(t *testing.T, repo *Repository, _ string) {
+				if err := repo.Close(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantSnapshot: true,
+		},
+		{
+			name: "log folded into the snapshot on compact",
+			finish: func(t *testing.T, repo *Repository, _ string) {
+				if err := repo.Compact(context.Background(), testCollection); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantSnapshot: true,
+		},
+		{
+			name: "torn last record dropped",
+			finish: func(t *testing.T, _ *Repository, path string) {
+				f, err := os.OpenFile(path+".log", os.O_WRONLY|os.O_APPEND, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				if _, err := f.WriteString(`{"op":"upsert","collection":"kb","items":[{"id":9,"embe`); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantLog: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "state", "vectors.json")
+
+			repo := openRepository(t, path)
+			for _, name := range []string{testCollection, "dropped"} {
+				if err := repo.EnsureCollection(ctx, name, milvusrepo.CollectionConfig{Dim: 2, Metric: milvusrepo.MetricIP}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := repo.Upsert(ctx, testCollection, []milvusrepo.VectorItem{item(1, "a.md", 1, 0), item(2, "a.md", 0, 1)}); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Upsert(ctx, testCollection, []milvusrepo.VectorItem{item(3, "b.md", 1, 1)}); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Delete(ctx, testCollection, []int64{2}); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.DropCollection(ctx, "dropped"); err != nil {
+				t.Fatal(err)
+			}
+			tt.finish(t, repo, path)
+
+			if _, err := os.Stat(path + ".log"); (err == nil) != tt.wantLog {
+				t.Errorf("log exists = %v, want %v", err == nil, tt.wantLog)
+			}
+			if _, err := os.Stat(path); (err == nil) != tt.wantSnapshot {
+				t.Errorf("snapshot exists = %v, want %v", err == nil, tt.wantSnapshot)
+			}
+
+			reopened := openRepository(t, path)
+			names, err := reopened.ListCollections(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(names, []string{testCollection}) {
+				t.Errorf("collections = %v, want [%s]", names, testCollection)
+			}
+			info, err := reopened.DescribeCollection(ctx, testCollection)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Config.Dim != 2 || info.Config.Metric != milvusrepo.MetricIP {
+				t.Errorf("config = %+v, want dim 2 and metric IP", info.Config)
+			}
+			if got := storedIDs(t, reopened, testCollection); !slices.Equal(got, []int64{1, 3}) {
+				t.Errorf("ids = %v, want [1 3]", got)
+			}
+
+			// The log takes new records after a truncated tail.
+			if err := reopened.Upsert(ctx, testCollection, []milvusrepo.VectorItem{item(4, "c.md", 1, 0)}); err != nil {
+				t.Fatal(err)
+			}
+			if got := storedIDs(t, openRepository(t, path), testCollection); !slices.Equal(got, []int64{1, 3, 4}) {
+				t.Errorf("ids after reopening = %v, want [1 3 4]", got)
+			}
+		})
+	}
+}
+
+func TestCompactLargeLog(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors.json")
+	repo := openRepository(t, path)
+	if err := repo.EnsureCollection(ctx, testCollection, milvusrepo.CollectionConfig{Dim: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	items := make([]milvusrepo.VectorItem, 0, compactMinEntries)
+	for id := range int64(compactMinEntries) {
+		items = append(items, item(id+1, "a.md", 1, 0))
+	}
+	if err := repo.Upsert(ctx, testCollection, items); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path + ".log"); !os.IsNotExist(err) {
+		t.Errorf("log kept after %d changes: %v", compactMinEntries, err)
+	}
+	if got := len(storedIDs(t, openRepository(t, path), testCollection)); got != compactMinEntries {
+		t.Errorf("reopened store has %d items, want %d", got, compactMinEntries)
+	}
+}
@@ -0,0 +1,208 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/storage/atomicfile"
+	"rag-test/internal/storage/wal"
+)
+
+const (
+	snapshotVersion = 1
+
+	// compactMinEntries keeps small logs from being folded into the
+	// snapshot on every change.
+	compactMinEntries = 1024
+
+	opEnsure = "ensure"
+	opUpsert = "upsert"
+	opDelete = "delete"
+	opDrop   = "drop"
+)
+
+type snapshot struct {
+	Version     int                           `json:"version"`
+	Collections map[string]snapshotCollection `json:"collections"`
+}
+
+type snapshotCollection struct {
+	Dim             int            `json:"dim"`
+	MaxPayloadBytes int            `json:"max_payload_bytes"`
+	Metric          string         `json:"metric,omitempty"`
+	Items           []snapshotItem `json:"items"`
+}
+
+type snapshotItem struct {
+	ID         int64                    `json:"id"`
+	Embedding  []float32                `json:"embedding"`
+	Payload    string                   `json:"payload"`
+	DataSource string                   `json:"data_source"`
+	Metadata   milvusrepo.ChunkMetadata `json:"metadata"`
+}
+
+// logRecord is one change appended to the log next to the snapshot. Config is set for ensure, Items for upsert and
+// IDs for delete.
+type logRecord struct {
+	Op         string              `json:"op"`
+	Collection string              `json:"collection"`
+	Config     *snapshotCollection `json:"config,omitempty"`
+	Items      []snapshotItem      `json:"items,omitempty"`
+	IDs        []int64             `json:"ids,omitempty"`
+}
+
+func toSnapshotItem(item milvusrepo.VectorItem) snapshotItem {
+	return snapshotItem{
+		ID:         item.ID,
+		Embedding:  item.Embedding,
+		Payload:    item.Payload,
+		DataSource: item.DataSource,
+		Metadata:   item.Metadata,
+	}
+}
+
+func (i snapshotItem) vectorItem() milvusrepo.VectorItem {
+	return milvusrepo.VectorItem{
+		ID:         i.ID,
+		Embedding:  i.Embedding,
+		Payload:    i.Payload,
+		DataSource: i.DataSource,
+		Metadata:   i.Metadata,
+	}
+}
+
+func (c snapshotCollection) config() milvusrepo.CollectionConfig {
+	return milvusrepo.CollectionConfig{
+		Dim:             c.Dim,
+		MaxPayloadBytes: c.MaxPayloadBytes,
+		Metric:          milvusrepo.Metric(c.Metric),
+	}.WithDefaults()
+}
+
+// load reads the snapshot and replays the log written after it.
+func (r *Repository) load() error {
+	data, err := os.ReadFile(r.cfg.SnapshotPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		var snap snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return fmt.Errorf("decode snapshot %s: %w", r.cfg.SnapshotPath, err)
+		}
+		if snap.Version != snapshotVersion {
+			return fmt.Errorf("snapshot %s has version %d, want %d", r.cfg.SnapshotPath, snap.Version, snapshotVersion)
+		}
+
+		for name, stored := range snap.Collections {
+			coll := &collection{cfg: stored.config(), items: make(map[int64]milvusrepo.VectorItem, len(stored.Items))}
+			for _, item := range stored.Items {
+				coll.items[item.ID] = item.vectorItem()
+			}
+			r.collections[name] = coll
+		}
+	}
+
+	r.log = wal.New(r.cfg.SnapshotPath + ".log")
+	_, err = wal.Replay(r.log, r.apply)
+	return err
+}
+
+// apply makes the change of rec in memory; the caller holds the write lock
+// or has the repository to itself.
+func (r *Repository) apply(rec logRecord) {
+	switch rec.Op {
+	case opEnsure:
+		if _, ok := r.collections[rec.Collection]; !ok && rec.Config != nil {
+			r.collections[rec.Collection] = &collection{cfg: rec.Config.config(), items: make(map[int64]milvusrepo.VectorItem)}
+		}
+	case opUpsert:
+		if coll, ok := r.collections[rec.Collection]; ok {
+			for _, item := range rec.Items {
+				coll.items[item.ID] = item.vectorItem()
+			}
+		}
+	case opDelete:
+		if coll, ok := r.collections[rec.Collection]; ok {
+			for _, id := range rec.IDs {
+				delete(coll.items, id)
+			}
+		}
+	case opDrop:
+		delete(r.collections, rec.Collection)
+	}
+	r.entries += max(len(rec.Items)+len(rec.IDs), 1)
+}
+
+// record logs rec and applies it. Without a snapshot path it only applies
+// it. The log is folded into the snapshot once it holds more changes than
+// the store has items. The caller holds the write lock.
+func (r *Repository) record(rec logRecord) error {
+	if r.cfg.SnapshotPath == "" {
+		r.apply(rec)
+		return nil
+	}
+
+	if err := r.log.Append(rec); err != nil {
+		return err
+	}
+
+	r.apply(rec)
+	if r.entries < max(compactMinEntries, r.items()) {
+		return nil
+	}
+	return r.persist()
+}
+
+func (r *Repository) items() int {
+	n := 0
+	for _, coll := range r.collections {
+		n += len(coll.items)
+	}
+	return n
+}
+
+// persist writes the snapshot through a temporary file, so an interrupted
+// run leaves the previous one intact, and then empties the log. A crash
+// between the two replays the log over the new snapshot, which changes
+// nothing. The caller holds the write lock.
+func (r *Repository) persist() error {
+	if r.cfg.SnapshotPath == "" {
+		return nil
+	}
+
+	snap := snapshot{Version: snapshotVersion, Collections: make(map[string]snapshotCollection, len(r.collections))}
+	for name, coll := range r.collections {
+		items := make([]snapshotItem, 0, len(coll.items))
+		for _, item := range coll.items {
+			items = append(items, toSnapshotItem(item))
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+
+		snap.Collections[name] = snapshotCollection{
+			Dim:             coll.cfg.Dim,
+			MaxPayloadBytes: coll.cfg.MaxPayloadBytes,
+			Metric:          string(coll.cfg.Metric),
+			Items:           items,
+		}
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	if err := atomicfile.Write(r.cfg.SnapshotPath, data); err != nil {
+		return err
+	}
+	if err := r.log.Remove(); err != nil {
+		return err
+	}
+	r.entries = 0
+	return nil
+}
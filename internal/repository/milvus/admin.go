@@ -0,0 +1,331 @@
+package milvus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+const (
+	defaultScanBatchSize = 1000
+
+	compactionPollInterval = 500 * time.Millisecond
+)
+
+// CollectionInfo describes a stored collection: the configuration the
+// store knows of and the number of live items.
+type CollectionInfo struct {
+	Name   string
+	Config CollectionConfig
+	Count  int64
+}
+
+// ScanRequest pages through the items of a collection in ascending ID
+// order, BatchSize per page. Filter and OutputFields work as in
+// SearchRequest; the embeddings are only returned with Embeddings, which
+// exports set.
+type ScanRequest struct {
+	Filter       Filter
+	OutputFields []Field
+	BatchSize    int
+	Embeddings   bool
+}
+
+func (r ScanRequest) Validate() error {
+	if r.BatchSize < 0 {
+		return fmt.Errorf("batch size must not be negative, got %d", r.BatchSize)
+	}
+	if err := validateOutputFields(r.OutputFields); err != nil {
+		return err
+	}
+	if r.Filter != nil {
+		if _, err := r.Filter.Expr(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r ScanRequest) Wants(field Field) bool {
+	return wants(r.OutputFields, field)
+}
+
+func (r ScanRequest) batchSize() int {
+	if r.BatchSize <= 0 {
+		return defaultScanBatchSize
+	}
+	return r.BatchSize
+}
+
+// Walk calls fn with the matching items in pages, clearing the columns
+// that were not asked for. The stores that scan without Milvus pass all
+// items of a collection in ascending ID order.
+func (r ScanRequest) Walk(items []VectorItem, fn func([]VectorItem) error) error {
+	matched := make([]VectorItem, 0, len(items))
+	for _, item := range items {
+		if r.Filter != nil && !r.Filter.Match(item) {
+			continue
+		}
+		if !r.Embeddings {
+			item.Embedding = nil
+		}
+		if !r.Wants(FieldPayload) {
+			item.Payload = ""
+		}
+		if !r.Wants(FieldDataSource) {
+			item.DataSource = ""
+		}
+		if !r.Wants(FieldMetadata) {
+			item.Metadata = ChunkMetadata{}
+		}
+		matched = append(matched, item)
+	}
+
+	for page := range slices.Chunk(matched, r.batchSize()) {
+		if err := fn(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MilvusRepository) ListCollections(ctx context.Context) ([]string, error) {
+	collections, err := r.client.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(collections))
+	for _, coll := range collections {
+		names = append(names, coll.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DescribeCollection reads the schema and index from Milvus rather than the
+// cached configuration. The search parameters are not stored there and
+// show their defaults.
+func (r *MilvusRepository) DescribeCollection(ctx context.Context, name string) (CollectionInfo, error) {
+	cfg, err := r.describeConfig(ctx, name)
+	if err != nil {
+		return CollectionInfo{}, err
+	}
+	count, err := r.Count(ctx, name)
+	if err != nil {
+		return CollectionInfo{}, err
+	}
+	return CollectionInfo{Name: name, Config: cfg, Count: count}, nil
+}
+
+// Scan pages by primary key: Milvus returns query results in primary key
+// order, so every page continues after the largest ID of the one before.
+func (r *MilvusRepository) Scan(ctx context.Context, collection string, req ScanRequest, fn func([]VectorItem) error) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	filter := ""
+	if req.Filter != nil {
+		filter, _ = req.Filter.Expr()
+	}
+	fields := outputFields(req.OutputFields)
+	if req.Embeddings {
+		fields = append(fields, "embedding")
+	}
+	limit := req.batchSize()
+
+	var (
+		last  int64
+		first = true
+	)
+	for {
+		expr := filter
+		if !first {
+			bound := fmt.Sprintf("id > %d", last)
+			if expr == "" {
+				expr = bound
+			} else {
+				expr = "(" + expr + ") and " + bound
+			}
+		}
+
+		rs, err := r.client.Query(
+			ctx,
+			collection,
+			[]string{},
+			expr,
+			fields,
+			client.WithLimit(int64(limit)),
+			client.WithSearchQueryConsistencyLevel(entity.ClStrong),
+		)
+		if err != nil {
+			return err
+		}
+		items, err := parseQueryResult(rs, req)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+		last, first = items[len(items)-1].ID, false
+		if err := fn(items); err != nil {
+			return err
+		}
+		if len(items) < limit {
+			return nil
+		}
+	}
+}
+
+func parseQueryResult(rs client.ResultSet, req ScanRequest) ([]VectorItem, error) {
+	idColumn := rs.GetColumn("id")
+	payloadColumn := rs.GetColumn("payload")
+	sourceColumn := rs.GetColumn("data_source")
+	metadataColumn := rs.GetColumn("metadata")
+	if idColumn == nil {
+		return nil, fmt.Errorf("missing id column in query result")
+	}
+	if payloadColumn == nil && req.Wants(FieldPayload) {
+		return nil, fmt.Errorf("missing payload column in query result")
+	}
+	if sourceColumn == nil && req.Wants(FieldDataSource) {
+		return nil, fmt.Errorf("missing data_source column in query result")
+	}
+	var vectors [][]float32
+	if req.Embeddings {
+		vectorColumn, ok := rs.GetColumn("embedding").(*entity.ColumnFloatVector)
+		if !ok {
+			return nil, fmt.Errorf("missing embedding column in query result")
+		}
+		vectors = vectorColumn.Data()
+	}
+
+	items := make([]VectorItem, 0, idColumn.Len())
+	for i := 0; i < idColumn.Len(); i++ {
+		id, err := idColumn.GetAsInt64(i)
+		if err != nil {
+			return nil, err
+		}
+		item := VectorItem{ID: id}
+		if vectors != nil {
+			item.Embedding = vectors[i]
+		}
+		if payloadColumn != nil {
+			if item.Payload, err = payloadColumn.GetAsString(i); err != nil {
+				return nil, err
+			}
+		}
+		if sourceColumn != nil {
+			if item.DataSource, err = sourceColumn.GetAsString(i); err != nil {
+				return nil, err
+			}
+		}
+		if metadataColumn != nil {
+			raw, err := metadataColumn.GetAsString(i)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal([]byte(raw), &item.Metadata); err != nil {
+				return nil, fmt.Errorf("decode metadata of id %d: %w", id, err)
+			}
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// CopyCollection copies the items of from with their vectors into to, which
+// is created with the configuration of from. Items to already holds are
+// overwritten, others are kept.
+func CopyCollection(ctx context.Context, repo VectorRepository, from, to string) error {
+	info, err := repo.DescribeCollection(ctx, from)
+	if err != nil {
+		return err
+	}
+	if err := repo.EnsureCollection(ctx, to, info.Config); err != nil {
+		return err
+	}
+
+	req := ScanRequest{
+		OutputFields: []Field{FieldPayload, FieldDataSource, FieldMetadata},
+		Embeddings:   true,
+	}
+	return repo.Scan(ctx, from, req, func(items []VectorItem) error {
+		return repo.Upsert(ctx, to, items)
+	})
+}
+
+func (r *MilvusRepository) DropCollection(ctx context.Context, name string) error {
+	if err := r.client.DropCollection(ctx, name); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	delete(r.configs, name)
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Compact flushes the collection, merges its segments so deleted entities
+// stop taking space and waits until Milvus reports the compaction done.
+func (r *MilvusRepository) Compact(ctx context.Context, name string) error {
+	if err := r.client.Flush(ctx, name, false); err != nil {
+		return err
+	}
+	id, err := r.client.ManualCompaction(ctx, name, 0)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(compactionPollInterval)
+	defer ticker.Stop()
+	for {
+		state, err := r.client.GetCompactionState(ctx, id)
+		if err != nil {
+			return err
+		}
+		if state == entity.CompactionStateCompleted {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Count returns the number of live entities; a strong read sees every
+// write acknowledged before it.
+func (r *MilvusRepository) Count(ctx context.Context, collection string) (int64, error) {
+	result, err := r.client.Query(
+		ctx,
+		collection,
+		[]string{},
+		"",
+		[]string{"count(*)"},
+		client.WithSearchQueryConsistencyLevel(entity.ClStrong),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	column := result.GetColumn("count(*)")
+	if column == nil || column.Len() == 0 {
+		return 0, fmt.Errorf("missing count in query result of %s", collection)
+	}
+	return column.GetAsInt64(0)
+}
@@ -0,0 +1,69 @@
+package milvus
+
+import (
+	"context"
+	"fmt"
+)
+
+// ResolveAlias returns the collection behind name: the target of an alias,
+// name itself for a plain collection, or "" when neither exists.
+func (r *MilvusRepository) ResolveAlias(ctx context.Context, name string) (string, error) {
+	exists, err := r.client.HasCollection(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", nil
+	}
+
+	coll, err := r.client.DescribeCollection(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return coll.Name, nil
+}
+
+// SwapAlias points alias at collection, creating the alias if needed. Milvus
+// switches it atomically, so readers see either the old or the new target.
+func (r *MilvusRepository) SwapAlias(ctx context.Context, alias, collection string) error {
+	current, err := r.ResolveAlias(ctx, alias)
+	if err != nil {
+		return err
+	}
+
+	switch current {
+	case "":
+		err = r.client.CreateAlias(ctx, collection, alias)
+	case alias:
+		return fmt.Errorf("%s is a collection, not an alias", alias)
+	case collection:
+		return nil
+	default:
+		err = r.client.AlterAlias(ctx, collection, alias)
+	}
+	if err != nil {
+		return err
+	}
+
+	// A configuration cached under the alias belongs to the old target.
+	r.mu.Lock()
+	delete(r.configs, alias)
+	r.mu.Unlock()
+
+	return nil
+}
+
+// RenameCollection gives the collection from the name to, which must be
+// free; an alias pointing at it follows.
+func (r *MilvusRepository) RenameCollection(ctx context.Context, from, to string) error {
+	if err := r.client.RenameCollection(ctx, from, to); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	delete(r.configs, from)
+	delete(r.configs, to)
+	r.mu.Unlock()
+
+	return nil
+}
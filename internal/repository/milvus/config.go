@@ -0,0 +1,169 @@
+package milvus
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// DefaultMaxPayloadBytes is the max_length of the payload VARCHAR field.
+	// Milvus measures it in UTF-8 bytes, so Cyrillic text fits about half as
+	// many characters.
+	DefaultMaxPayloadBytes = 4096
+
+	maxVarCharLength = 65535
+
+	defaultNList          = 128
+	defaultNProbe         = 64
+	defaultM              = 16
+	defaultEfConstruction = 200
+	defaultEf             = 64
+)
+
+type IndexType string
+
+const (
+	IndexFlat    IndexType = "FLAT"
+	IndexIVFFlat IndexType = "IVF_FLAT"
+	IndexIVFSQ8  IndexType = "IVF_SQ8"
+	IndexHNSW    IndexType = "HNSW"
+)
+
+var ErrPayloadTooLarge = errors.New("payload exceeds the collection max_length")
+
+// CollectionConfig describes a collection. MaxPayloadBytes, Metric, Index
+// and the build parameters (NList for the IVF indexes, M and EfConstruction
+// for HNSW) are fixed when the collection is created; a zero value takes
+// the default for a new collection and the existing value otherwise, see
+// Adopt. The search parameters NProbe and Ef may change between runs.
+type CollectionConfig struct {
+	Dim             int
+	MaxPayloadBytes int
+
+	Metric         Metric
+	Index          IndexType
+	NList          int
+	NProbe         int
+	M              int
+	EfConstruction int
+	Ef             int
+}
+
+// WithDefaults fills the payload limit and the index settings, which default
+// to the L2 IVF_FLAT index collections were always built with; every
+// VectorRepository applies it before Validate.
+func (c CollectionConfig) WithDefaults() CollectionConfig {
+	if c.MaxPayloadBytes <= 0 {
+		c.MaxPayloadBytes = DefaultMaxPayloadBytes
+	}
+	if c.Metric == "" {
+		c.Metric = MetricL2
+	}
+	if c.Index == "" {
+		c.Index = IndexIVFFlat
+	}
+	if c.NList <= 0 {
+		c.NList = defaultNList
+	}
+	if c.NProbe <= 0 {
+		c.NProbe = defaultNProbe
+	}
+	if c.M <= 0 {
+		c.M = defaultM
+	}
+	if c.EfConstruction <= 0 {
+		c.EfConstruction = defaultEfConstruction
+	}
+	if c.Ef <= 0 {
+		c.Ef = defaultEf
+	}
+	return c
+}
+
+func (c CollectionConfig) Validate() error {
+	if c.Dim <= 0 {
+		return fmt.Errorf("collection dim must be positive, got %d", c.Dim)
+	}
+	if c.MaxPayloadBytes > maxVarCharLength {
+		return fmt.Errorf("payload max_length %d exceeds the Milvus limit %d", c.MaxPayloadBytes, maxVarCharLength)
+	}
+
+	switch c.Metric {
+	case MetricL2, MetricIP, MetricCosine:
+	default:
+		return fmt.Errorf("unknown metric %q", c.Metric)
+	}
+
+	switch c.Index {
+	case IndexFlat:
+	case IndexIVFFlat, IndexIVFSQ8:
+		if c.NList < 1 || c.NList > 65536 {
+			return fmt.Errorf("nlist must be in [1, 65536], got %d", c.NList)
+		}
+		if c.NProbe > c.NList {
+			return fmt.Errorf("nprobe %d exceeds nlist %d", c.NProbe, c.NList)
+		}
+	case IndexHNSW:
+		if c.M < 4 || c.M > 64 {
+			return fmt.Errorf("HNSW M must be in [4, 64], got %d", c.M)
+		}
+		if c.EfConstruction < 8 || c.EfConstruction > 512 {
+			return fmt.Errorf("HNSW efConstruction must be in [8, 512], got %d", c.EfConstruction)
+		}
+	default:
+		return fmt.Errorf("unknown index type %q", c.Index)
+	}
+	return nil
+}
+
+// Adopt returns the config of the existing collection name, described by
+// existing, for a request of c: the settings fixed at creation come from
+// existing and the search parameters from c. A fixed setting c gives that
+// differs from existing is an error, since changing it means dropping and
+// re-ingesting the collection. An empty existing.Index skips the index
+// checks, for stores without a Milvus index.
+func (c CollectionConfig) Adopt(name string, existing CollectionConfig) (CollectionConfig, error) {
+	if c.Dim != existing.Dim {
+		return CollectionConfig{}, fmt.Errorf("collection %s has dim %d, want %d", name, existing.Dim, c.Dim)
+	}
+	if c.MaxPayloadBytes > 0 && c.MaxPayloadBytes != existing.MaxPayloadBytes {
+		return CollectionConfig{}, fmt.Errorf("collection %s has payload max_length %d, want %d", name, existing.MaxPayloadBytes, c.MaxPayloadBytes)
+	}
+	if c.Metric != "" && c.Metric != existing.Metric {
+		return CollectionConfig{}, fmt.Errorf("collection %s uses metric %s, want %s", name, existing.Metric, c.Metric)
+	}
+	if existing.Index != "" {
+		if c.Index != "" && c.Index != existing.Index {
+			return CollectionConfig{}, fmt.Errorf("collection %s has a %s index, want %s", name, existing.Index, c.Index)
+		}
+		requested := CollectionConfig{Index: existing.Index, NList: c.NList, M: c.M, EfConstruction: c.EfConstruction}
+		have := existing.buildParams()
+		for key, want := range requested.buildParams() {
+			if want != "0" && want != have[key] {
+				return CollectionConfig{}, fmt.Errorf("collection %s index has %s %s, want %s", name, key, have[key], want)
+			}
+		}
+	}
+
+	existing.NProbe, existing.Ef = c.NProbe, c.Ef
+	return existing.WithDefaults(), nil
+}
+
+// buildParams are the index parameters Milvus keeps with the collection.
+func (c CollectionConfig) buildParams() map[string]string {
+	switch c.Index {
+	case IndexIVFFlat, IndexIVFSQ8:
+		return map[string]string{"nlist": strconv.Itoa(c.NList)}
+	case IndexHNSW:
+		return map[string]string{"M": strconv.Itoa(c.M), "efConstruction": strconv.Itoa(c.EfConstruction)}
+	default:
+		return nil
+	}
+}
+
+// QuestionsCollection names the collection that holds the generated
+// questions of the chunks in collection.
+func QuestionsCollection(collection string) string {
+	return collection + "_questions"
+}
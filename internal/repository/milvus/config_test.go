@@ -0,0 +1,44 @@
+package milvus
+
+import "testing"
+
+func TestAdopt(t *testing.T) {
+	existing := CollectionConfig{Dim: 8, MaxPayloadBytes: 8192, Metric: MetricCosine, Index: IndexHNSW, M: 32, EfConstruction: 100}.WithDefaults()
+
+	tests := []struct {
+		name    string
+		want    CollectionConfig
+		wantErr bool
+	}{
+		{name: "settings left unset", want: CollectionConfig{Dim: 8}},
+		{name: "matching explicit settings", want: CollectionConfig{Dim: 8, MaxPayloadBytes: 8192, Metric: MetricCosine, Index: IndexHNSW, M: 32}},
+		{name: "search parameters", want: CollectionConfig{Dim: 8, Ef: 200}},
+		{name: "build parameter of another index", want: CollectionConfig{Dim: 8, NList: 1024}},
+		{name: "other dim", want: CollectionConfig{Dim: 16}, wantErr: true},
+		{name: "other payload size", want: CollectionConfig{Dim: 8, MaxPayloadBytes: 4096}, wantErr: true},
+		{name: "other metric", want: CollectionConfig{Dim: 8, Metric: MetricL2}, wantErr: true},
+		{name: "other index", want: CollectionConfig{Dim: 8, Index: IndexIVFFlat}, wantErr: true},
+		{name: "other build parameter", want: CollectionConfig{Dim: 8, EfConstruction: 200}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.want.Adopt("kb", existing)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Adopt = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Adopt: %v", err)
+			}
+
+			want := existing
+			want.NProbe, want.Ef = tt.want.NProbe, tt.want.Ef
+			if want = want.WithDefaults(); got != want {
+				t.Errorf("Adopt = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
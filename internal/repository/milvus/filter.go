@@ -0,0 +1,361 @@
+package milvus
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field names a stored chunk attribute. Payload and metadata can only be
+// selected as search output; the others can also be filtered on.
+type Field string
+
+const (
+	FieldID         Field = "id"
+	FieldPayload    Field = "payload"
+	FieldDataSource Field = "data_source"
+	FieldMetadata   Field = "metadata"
+	FieldDocType    Field = "doc_type"
+	FieldTags       Field = "tags"
+	FieldUpdatedAt  Field = "updated_at"
+
+	// fieldSources is the metadata list data_source filters also match.
+	fieldSources Field = "sources"
+)
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota + 1
+	kindInt
+	kindTags
+)
+
+var filterFields = map[Field]fieldKind{
+	FieldID:         kindInt,
+	FieldDataSource: kindString,
+	FieldDocType:    kindString,
+	FieldTags:       kindTags,
+	FieldUpdatedAt:  kindInt,
+}
+
+// Filter is a boolean expression over chunk fields, built with Eq, In,
+// Range, And, Or and Not. Values are checked against the field type when
+// the filter is built and reported by Expr, so a filter never compiles to
+// an expression that could be read another way.
+type Filter interface {
+	// Expr compiles the filter to a Milvus boolean expression.
+	Expr() (string, error)
+	// Match evaluates the filter against an item, for the stores that
+	// search without Milvus; an invalid filter matches nothing.
+	Match(item VectorItem) bool
+}
+
+// Eq matches chunks whose field equals value; for tags, chunks carrying the
+// tag, and for data_source also the chunks merged from several documents
+// that list value in their sources. Integer fields take int, int64 or, for
+// updated_at, time.Time values. A metadata key the chunk does not have
+// matches no value, as in Milvus.
+func Eq(field Field, value any) Filter {
+	f := &inFilter{field: field}
+	f.values, f.err = filterValues(field, []any{value})
+	return f
+}
+
+// In matches chunks whose field equals one of values; for tags, chunks
+// carrying any of them.
+func In(field Field, values ...any) Filter {
+	f := &inFilter{field: field, list: true}
+	if len(values) == 0 {
+		f.err = fmt.Errorf("filter on %s has no values", field)
+		return f
+	}
+	f.values, f.err = filterValues(field, values)
+	return f
+}
+
+// Range matches chunks whose integer field is at least from and below to;
+// a nil bound leaves that side open.
+func Range(field Field, from, to any) Filter {
+	f := &rangeFilter{field: field}
+	if filterFields[field] != kindInt {
+		f.err = fmt.Errorf("field %s does not support ranges", field)
+		return f
+	}
+	if from == nil && to == nil {
+		f.err = fmt.Errorf("range on %s has no bounds", field)
+		return f
+	}
+	if from != nil {
+		if f.from, f.err = intValue(field, from); f.err != nil {
+			return f
+		}
+		f.hasFrom = true
+	}
+	if to != nil {
+		if f.to, f.err = intValue(field, to); f.err != nil {
+			return f
+		}
+		f.hasTo = true
+	}
+	return f
+}
+
+func And(filters ...Filter) Filter {
+	return &logicFilter{op: "and", filters: filters}
+}
+
+func Or(filters ...Filter) Filter {
+	return &logicFilter{op: "or", filters: filters}
+}
+
+func Not(filter Filter) Filter {
+	return &notFilter{filter: filter}
+}
+
+type inFilter struct {
+	field  Field
+	values []any
+	list   bool
+	err    error
+}
+
+func (f *inFilter) Expr() (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+
+	literals := make([]string, len(f.values))
+	for i, value := range f.values {
+		literals[i] = literal(value)
+	}
+	target := fieldExpr(f.field)
+
+	if filterFields[f.field] == kindTags {
+		return f.containsExpr(target, literals), nil
+	}
+	expr := fmt.Sprintf("%s == %s", target, literals[0])
+	if f.list {
+		expr = fmt.Sprintf("%s in [%s]", target, strings.Join(literals, ", "))
+	}
+	if f.field == FieldDataSource {
+		expr = fmt.Sprintf("(%s or %s)", expr, f.containsExpr(fieldExpr(fieldSources), literals))
+	}
+	return expr, nil
+}
+
+// containsExpr matches a JSON list holding one of literals.
+func (f *inFilter) containsExpr(target string, literals []string) string {
+	if !f.list {
+		return fmt.Sprintf("json_contains(%s, %s)", target, literals[0])
+	}
+	return fmt.Sprintf("json_contains_any(%s, [%s])", target, strings.Join(literals, ", "))
+}
+
+func (f *inFilter) Match(item VectorItem) bool {
+	if f.err != nil {
+		return false
+	}
+	switch f.field {
+	case FieldTags:
+		return f.containsAny(item.Metadata.Tags)
+	case FieldDataSource:
+		return slices.Contains(f.values, any(item.DataSource)) || f.containsAny(item.Metadata.Sources)
+	}
+	value, ok := itemValue(item, f.field)
+	return ok && slices.Contains(f.values, value)
+}
+
+func (f *inFilter) containsAny(list []string) bool {
+	for _, value := range list {
+		if slices.Contains(f.values, any(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+type rangeFilter struct {
+	field          Field
+	from, to       int64
+	hasFrom, hasTo bool
+	err            error
+}
+
+func (f *rangeFilter) Expr() (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+
+	target := fieldExpr(f.field)
+	parts := make([]string, 0, 2)
+	if f.hasFrom {
+		parts = append(parts, fmt.Sprintf("%s >= %d", target, f.from))
+	}
+	if f.hasTo {
+		parts = append(parts, fmt.Sprintf("%s < %d", target, f.to))
+	}
+	return strings.Join(parts, " and "), nil
+}
+
+func (f *rangeFilter) Match(item VectorItem) bool {
+	if f.err != nil {
+		return false
+	}
+	value, ok := itemValue(item, f.field)
+	if !ok {
+		return false
+	}
+	n := value.(int64)
+	return (!f.hasFrom || n >= f.from) && (!f.hasTo || n < f.to)
+}
+
+type logicFilter struct {
+	op      string
+	filters []Filter
+}
+
+func (f *logicFilter) Expr() (string, error) {
+	if len(f.filters) == 0 {
+		return "", fmt.Errorf("%s filter has no operands", f.op)
+	}
+
+	parts := make([]string, len(f.filters))
+	for i, filter := range f.filters {
+		if filter == nil {
+			return "", fmt.Errorf("%s filter has a nil operand", f.op)
+		}
+		expr, err := filter.Expr()
+		if err != nil {
+			return "", err
+		}
+		parts[i] = "(" + expr + ")"
+	}
+	return strings.Join(parts, " "+f.op+" "), nil
+}
+
+func (f *logicFilter) Match(item VectorItem) bool {
+	if len(f.filters) == 0 {
+		return false
+	}
+	for _, filter := range f.filters {
+		if filter == nil {
+			return false
+		}
+		matched := filter.Match(item)
+		if f.op == "or" && matched {
+			return true
+		}
+		if f.op == "and" && !matched {
+			return false
+		}
+	}
+	return f.op == "and"
+}
+
+type notFilter struct {
+	filter Filter
+}
+
+func (f *notFilter) Expr() (string, error) {
+	if f.filter == nil {
+		return "", fmt.Errorf("not filter has no operand")
+	}
+	expr, err := f.filter.Expr()
+	if err != nil {
+		return "", err
+	}
+	return "not (" + expr + ")", nil
+}
+
+// Match is false for an invalid operand rather than its negation.
+func (f *notFilter) Match(item VectorItem) bool {
+	if _, err := f.Expr(); err != nil {
+		return false
+	}
+	return !f.filter.Match(item)
+}
+
+func filterValues(field Field, values []any) ([]any, error) {
+	kind, ok := filterFields[field]
+	if !ok {
+		return nil, fmt.Errorf("field %q cannot be filtered", field)
+	}
+
+	normalized := make([]any, len(values))
+	for i, value := range values {
+		if kind == kindInt {
+			n, err := intValue(field, value)
+			if err != nil {
+				return nil, err
+			}
+			normalized[i] = n
+			continue
+		}
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("field %s wants a string, got %T", field, value)
+		}
+		normalized[i] = s
+	}
+	return normalized, nil
+}
+
+func intValue(field Field, value any) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case time.Time:
+		if field == FieldUpdatedAt {
+			return v.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("field %s wants an integer, got %T", field, value)
+}
+
+// fieldExpr is the Milvus name of a field: the columns for id and
+// data_source, a key of the JSON metadata otherwise.
+func fieldExpr(field Field) string {
+	switch field {
+	case FieldID, FieldDataSource:
+		return string(field)
+	default:
+		return fmt.Sprintf("metadata[%q]", string(field))
+	}
+}
+
+// literalEscaper escapes the only two characters the Milvus expression
+// parser treats specially inside a double-quoted literal. Anything else,
+// non-ASCII text and control characters included, is kept as is; Go escapes
+// such as \u or \x would not read back the same.
+var literalEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// literal quotes strings so no value can end the literal early.
+func literal(value any) string {
+	if n, ok := value.(int64); ok {
+		return strconv.FormatInt(n, 10)
+	}
+	return `"` + literalEscaper.Replace(value.(string)) + `"`
+}
+
+// itemValue returns the value of a scalar field of item. The metadata keys
+// are left out of the stored JSON when empty, so a zero value reports a
+// missing key, which no comparison matches.
+func itemValue(item VectorItem, field Field) (any, bool) {
+	switch field {
+	case FieldID:
+		return item.ID, true
+	case FieldDataSource:
+		return item.DataSource, true
+	case FieldDocType:
+		return item.Metadata.DocType, item.Metadata.DocType != ""
+	case FieldUpdatedAt:
+		return item.Metadata.UpdatedAt, item.Metadata.UpdatedAt != 0
+	default:
+		return nil, false
+	}
+}
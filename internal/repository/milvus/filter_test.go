@@ -0,0 +1,175 @@
+package milvus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilterExpr(t *testing.T) {
+	updated := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "string equality",
+			filter: Eq(FieldDataSource, "docs/a.md"),
+			want:   `(data_source == "docs/a.md" or json_contains(metadata["sources"], "docs/a.md"))`,
+		},
+		{
+			name:   "quote in a value",
+			filter: Eq(FieldDataSource, `a" or id > 0 or data_source == "b`),
+			want:   `(data_source == "a\" or id > 0 or data_source == \"b" or json_contains(metadata["sources"], "a\" or id > 0 or data_source == \"b"))`,
+		},
+		{
+			name:   "backslash and newline",
+			filter: Eq(FieldDocType, "c:\\docs\n"),
+			want:   "metadata[\"doc_type\"] == \"c:\\\\docs\n\"",
+		},
+		{
+			name:   "control characters kept as is",
+			filter: Eq(FieldDocType, "a\tb\x00\x1bc"),
+			want:   "metadata[\"doc_type\"] == \"a\tb\x00\x1bc\"",
+		},
+		{
+			name:   "non-ASCII kept as is",
+			filter: Eq(FieldDocType, "прайс\u00a0№1 \u2028✓"),
+			want:   "metadata[\"doc_type\"] == \"прайс\u00a0№1 \u2028✓\"",
+		},
+		{
+			name:   "cyrillic value",
+			filter: Eq(FieldDataSource, "Цены.docx"),
+			want:   `(data_source == "Цены.docx" or json_contains(metadata["sources"], "Цены.docx"))`,
+		},
+		{
+			name:   "in list",
+			filter: In(FieldID, 1, int64(2)),
+			want:   `id in [1, 2]`,
+		},
+		{
+			name:   "sources in list",
+			filter: In(FieldDataSource, "a.md", "b.md"),
+			want:   `(data_source in ["a.md", "b.md"] or json_contains_any(metadata["sources"], ["a.md", "b.md"]))`,
+		},
+		{
+			name:   "tag",
+			filter: Eq(FieldTags, "price"),
+			want:   `json_contains(metadata["tags"], "price")`,
+		},
+		{
+			name:   "any tag",
+			filter: In(FieldTags, "price", `"quoted"`),
+			want:   `json_contains_any(metadata["tags"], ["price", "\"quoted\""])`,
+		},
+		{
+			name:   "time range",
+			filter: Range(FieldUpdatedAt, updated, nil),
+			want:   `metadata["updated_at"] >= 1735787045`,
+		},
+		{
+			name:   "closed range",
+			filter: Range(FieldID, 10, 20),
+			want:   `id >= 10 and id < 20`,
+		},
+		{
+			name:   "logic",
+			filter: And(Eq(FieldDocType, "docx"), Not(Or(Eq(FieldID, 1), Eq(FieldID, 2)))),
+			want:   `(metadata["doc_type"] == "docx") and (not ((id == 1) or (id == 2)))`,
+		},
+		{name: "unknown field", filter: Eq(FieldPayload, "x"), wantErr: true},
+		{name: "wrong value type", filter: Eq(FieldID, "1"), wantErr: true},
+		{name: "string for a tag list", filter: In(FieldTags, 1), wantErr: true},
+		{name: "empty in", filter: In(FieldDataSource), wantErr: true},
+		{name: "range on a string field", filter: Range(FieldDataSource, 1, 2), wantErr: true},
+		{name: "open range", filter: Range(FieldID, nil, nil), wantErr: true},
+		{name: "empty and", filter: And(), wantErr: true},
+		{name: "nil operand", filter: Or(Eq(FieldID, 1), nil), wantErr: true},
+		{name: "invalid operand", filter: Not(Eq(FieldID, "x")), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.filter.Expr()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expr() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Expr() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	item := VectorItem{
+		ID:         7,
+		DataSource: `a"b.md`,
+		Metadata:   ChunkMetadata{DocType: "docx", Tags: []string{"price", "chair"}, UpdatedAt: 100},
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{name: "quoted source", filter: Eq(FieldDataSource, `a"b.md`), want: true},
+		{name: "other source", filter: Eq(FieldDataSource, "a.md"), want: false},
+		{name: "id in list", filter: In(FieldID, 1, 7), want: true},
+		{name: "tag", filter: Eq(FieldTags, "chair"), want: true},
+		{name: "any tag", filter: In(FieldTags, "lamp", "price"), want: true},
+		{name: "missing tag", filter: Eq(FieldTags, "lamp"), want: false},
+		{name: "range lower bound included", filter: Range(FieldUpdatedAt, 100, nil), want: true},
+		{name: "range upper bound excluded", filter: Range(FieldUpdatedAt, nil, 100), want: false},
+		{name: "and", filter: And(Eq(FieldDocType, "docx"), Eq(FieldID, 7)), want: true},
+		{name: "or", filter: Or(Eq(FieldDocType, "pdf"), Eq(FieldID, 7)), want: true},
+		{name: "not", filter: Not(Eq(FieldDocType, "docx")), want: false},
+		{name: "invalid filter", filter: Eq(FieldID, "7"), want: false},
+		{name: "not of an invalid filter", filter: Not(Eq(FieldID, "7")), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(item); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFilterMatchLikeMilvus covers the items Milvus evaluates in a way the
+// fields alone do not show: metadata keys missing from the stored JSON and
+// chunks shared by several documents.
+func TestFilterMatchLikeMilvus(t *testing.T) {
+	bare := VectorItem{ID: 1, DataSource: "a.md"}
+	shared := VectorItem{ID: 2, DataSource: "a.md", Metadata: ChunkMetadata{Sources: []string{"a.md", "b.md"}}}
+
+	tests := []struct {
+		name   string
+		item   VectorItem
+		filter Filter
+		want   bool
+	}{
+		{name: "open range on a missing key", item: bare, filter: Range(FieldUpdatedAt, nil, 100), want: false},
+		{name: "not of a range on a missing key", item: bare, filter: Not(Range(FieldUpdatedAt, nil, 100)), want: true},
+		{name: "empty value on a missing key", item: bare, filter: Eq(FieldDocType, ""), want: false},
+		{name: "not of an empty value on a missing key", item: bare, filter: Not(Eq(FieldDocType, "")), want: true},
+		{name: "tag on missing tags", item: bare, filter: Eq(FieldTags, ""), want: false},
+		{name: "primary source", item: shared, filter: Eq(FieldDataSource, "a.md"), want: true},
+		{name: "secondary source", item: shared, filter: Eq(FieldDataSource, "b.md"), want: true},
+		{name: "secondary source in list", item: shared, filter: In(FieldDataSource, "c.md", "b.md"), want: true},
+		{name: "not of a secondary source", item: shared, filter: Not(Eq(FieldDataSource, "b.md")), want: false},
+		{name: "other source", item: shared, filter: Eq(FieldDataSource, "c.md"), want: false},
+		{name: "source of an unshared chunk", item: bare, filter: Eq(FieldDataSource, "b.md"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.item); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package milvus
+
+import "math"
+
+type Metric string
+
+const (
+	MetricL2     Metric = "L2"
+	MetricIP     Metric = "IP"
+	MetricCosine Metric = "COSINE"
+)
+
+// NormalizeScore turns a raw Milvus score into one where higher always means
+// more relevant: L2 distances map to 1/(1+d), inner product and cosine
+// similarities are kept as they are.
+func NormalizeScore(metric Metric, raw float32) float32 {
+	if metric == MetricL2 {
+		return 1 / (1 + raw)
+	}
+	return raw
+}
+
+// Similarity computes the normalized score of b for the query a, for the
+// stores that search without Milvus.
+func Similarity(metric Metric, a, b []float32) float32 {
+	switch metric {
+	case MetricIP:
+		return float32(dot(a, b))
+	case MetricCosine:
+		na, nb := dot(a, a), dot(b, b)
+		if na == 0 || nb == 0 {
+			return 0
+		}
+		return float32(dot(a, b) / (math.Sqrt(na) * math.Sqrt(nb)))
+	default:
+		var sum float64
+		for i := range a {
+			d := float64(a[i]) - float64(b[i])
+			sum += d * d
+		}
+		return NormalizeScore(MetricL2, float32(sum))
+	}
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
@@ -5,11 +5,73 @@ type VectorItem struct {
 	Embedding  []float32
 	Payload    string
 	DataSource string
+	Metadata   ChunkMetadata
 }
 
+// Clone returns a copy of i that shares no slices with it, for the stores
+// that keep items in memory.
+func (i VectorItem) Clone() VectorItem {
+	i.Embedding = append([]float32(nil), i.Embedding...)
+	i.Metadata = i.Metadata.Clone()
+	return i
+}
+
+// Hit returns i as a search hit with score.
+func (i VectorItem) Hit(score float32) SearchHit {
+	return SearchHit{
+		ID:         i.ID,
+		Score:      score,
+		Payload:    i.Payload,
+		DataSource: i.DataSource,
+		Metadata:   i.Metadata.Clone(),
+	}
+}
+
+// SearchHit scores are normalized so that higher is more relevant for every
+// metric; see NormalizeScore.
 type SearchHit struct {
 	ID         int64
 	Score      float32
 	Payload    string
 	DataSource string
+	Metadata   ChunkMetadata
+}
+
+// ChunkMetadata is stored in the JSON "metadata" field. Offsets are rune
+// offsets into the document Markdown; Truncated marks a payload cut to the
+// collection max_length whose full text is kept elsewhere. Sources lists
+// every document that contains the chunk when near-duplicates were merged.
+// ParentID links a generated question to the chunk it was asked about;
+// Context is the generated text situating the chunk in its document and
+// SectionID the larger parent section kept in the text store. DocType is
+// the loader that read the document, Tags come from its front matter and
+// UpdatedAt is its modification time in Unix seconds; filters match on them.
+type ChunkMetadata struct {
+	Title       string   `json:"title,omitempty"`
+	HeadingPath []string `json:"heading_path,omitempty"`
+	Ordinal     int      `json:"ordinal"`
+	StartOffset int      `json:"start_offset"`
+	EndOffset   int      `json:"end_offset"`
+	Truncated   bool     `json:"truncated,omitempty"`
+	Sources     []string `json:"sources,omitempty"`
+	ParentID    int64    `json:"parent_id,omitempty"`
+	Context     string   `json:"context,omitempty"`
+	SectionID   int64    `json:"section_id,omitempty"`
+	DocType     string   `json:"doc_type,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	UpdatedAt   int64    `json:"updated_at,omitempty"`
+}
+
+// Clone returns a copy of m that shares no slices with it.
+func (m ChunkMetadata) Clone() ChunkMetadata {
+	if m.HeadingPath != nil {
+		m.HeadingPath = append([]string(nil), m.HeadingPath...)
+	}
+	if m.Sources != nil {
+		m.Sources = append([]string(nil), m.Sources...)
+	}
+	if m.Tags != nil {
+		m.Tags = append([]string(nil), m.Tags...)
+	}
+	return m
 }
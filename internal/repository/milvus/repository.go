@@ -2,24 +2,46 @@ package milvus
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 )
 
 type VectorRepository interface {
-	EnsureCollection(ctx context.Context, name string, dim int) error
+	EnsureCollection(ctx context.Context, name string, cfg CollectionConfig) error
 	Upsert(ctx context.Context, collection string, items []VectorItem) error
-	Search(ctx context.Context, collection string, vector []float32, topK int) ([]SearchHit, error)
-	SearchByDataSource(ctx context.Context, collection string, vector []float32, topK int, dataSource string) ([]SearchHit, error)
+	Delete(ctx context.Context, collection string, ids []int64) error
+	Get(ctx context.Context, collection string, ids []int64) ([]VectorItem, error)
+	Search(ctx context.Context, collection string, req SearchRequest) ([]SearchHit, error)
+	// SearchBatch runs req once per vector, ignoring req.Vector, and returns
+	// the hit lists in the order of vectors.
+	SearchBatch(ctx context.Context, collection string, vectors [][]float32, req SearchRequest) ([][]SearchHit, error)
+	ListCollections(ctx context.Context) ([]string, error)
+	DescribeCollection(ctx context.Context, name string) (CollectionInfo, error)
+	// Scan calls fn with the items matching req, a page at a time in
+	// ascending ID order, until the items run out or fn returns an error.
+	// fn may delete the items it is given.
+	Scan(ctx context.Context, collection string, req ScanRequest, fn func([]VectorItem) error) error
+	DropCollection(ctx context.Context, name string) error
+	// Compact reclaims the space held by deleted and overwritten items.
+	Compact(ctx context.Context, name string) error
 	Close() error
 }
 
+// migrationSuffix names the copy migrateMetadata builds of a collection.
+const migrationSuffix = "_migrating"
+
 type MilvusRepository struct {
 	client client.Client
+
+	mu      sync.RWMutex
+	configs map[string]CollectionConfig
 }
 
 func NewMilvusRepository(ctx context.Context, addr string) (*MilvusRepository, error) {
@@ -27,62 +49,292 @@ func NewMilvusRepository(ctx context.Context, addr string) (*MilvusRepository, e
 	if err != nil {
 		return nil, err
 	}
-	return &MilvusRepository{client: c}, nil
+	return &MilvusRepository{client: c, configs: make(map[string]CollectionConfig)}, nil
 }
 
 func (r *MilvusRepository) Close() error {
 	return r.client.Close()
 }
 
-func (r *MilvusRepository) EnsureCollection(ctx context.Context, name string, dim int) error {
+// EnsureCollection creates the collection or, when it exists, adopts the
+// settings it was created with; see CollectionConfig.Adopt.
+func (r *MilvusRepository) EnsureCollection(ctx context.Context, name string, want CollectionConfig) error {
+	cfg := want.WithDefaults()
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
 	exists, err := r.client.HasCollection(ctx, name)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		schema := &entity.Schema{
-			CollectionName: name,
-			Description:    "Documents with embeddings",
-			AutoID:         false,
-			Fields: []*entity.Field{
-				{
-					Name:       "id",
-					DataType:   entity.FieldTypeInt64,
-					PrimaryKey: true,
-					AutoID:     false,
-				},
-				{
-					Name:       "embedding",
-					DataType:   entity.FieldTypeFloatVector,
-					TypeParams: map[string]string{"dim": strconv.Itoa(dim)},
-				},
-				{
-					Name:       "payload",
-					DataType:   entity.FieldTypeVarChar,
-					TypeParams: map[string]string{"max_length": "4096"},
-				},
-				{
-					Name:       "data_source",
-					DataType:   entity.FieldTypeVarChar,
-					TypeParams: map[string]string{"max_length": "1024"},
-				},
-			},
+		resumed, err := r.resumeMigration(ctx, name)
+		if err != nil {
+			return err
 		}
-
-		if err := r.client.CreateCollection(ctx, schema, 2); err != nil {
+		exists = resumed
+	}
+	if !exists {
+		if err := r.createCollection(ctx, name, cfg); err != nil {
 			return err
 		}
-
-		index, err := entity.NewIndexIvfFlat(entity.L2, 128)
+	} else {
+		existing, err := r.describeConfig(ctx, name)
 		if err != nil {
 			return err
 		}
-		if err := r.client.CreateIndex(ctx, name, "embedding", index, false); err != nil {
+		if cfg, err = want.Adopt(name, existing); err != nil {
 			return err
 		}
+		if err := r.migrateMetadata(ctx, name, cfg); err != nil {
+			return fmt.Errorf("add metadata field to collection %s: %w", name, err)
+		}
+	}
+
+	if err := r.client.LoadCollection(ctx, name, false); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.configs[name] = cfg
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *MilvusRepository) createCollection(ctx context.Context, name string, cfg CollectionConfig) error {
+	schema := &entity.Schema{
+		CollectionName: name,
+		Description:    "Documents with embeddings",
+		AutoID:         false,
+		Fields: []*entity.Field{
+			{
+				Name:       "id",
+				DataType:   entity.FieldTypeInt64,
+				PrimaryKey: true,
+				AutoID:     false,
+			},
+			{
+				Name:       "embedding",
+				DataType:   entity.FieldTypeFloatVector,
+				TypeParams: map[string]string{"dim": strconv.Itoa(cfg.Dim)},
+			},
+			{
+				Name:       "payload",
+				DataType:   entity.FieldTypeVarChar,
+				TypeParams: map[string]string{"max_length": strconv.Itoa(cfg.MaxPayloadBytes)},
+			},
+			{
+				Name:       "data_source",
+				DataType:   entity.FieldTypeVarChar,
+				TypeParams: map[string]string{"max_length": "1024"},
+			},
+			{
+				Name:     "metadata",
+				DataType: entity.FieldTypeJSON,
+			},
+		},
+	}
+
+	if err := r.client.CreateCollection(ctx, schema, 2); err != nil {
+		return err
+	}
+
+	index, err := newIndex(cfg)
+	if err != nil {
+		return err
+	}
+	return r.client.CreateIndex(ctx, name, "embedding", index, false)
+}
+
+// config is the configuration a collection was ensured with by this
+// repository. Any other collection, e.g. the previous version behind an
+// alias, is described once and searched with the default search parameters.
+func (r *MilvusRepository) config(ctx context.Context, collection string) (CollectionConfig, error) {
+	r.mu.RLock()
+	cfg, ok := r.configs[collection]
+	r.mu.RUnlock()
+	if ok {
+		return cfg, nil
+	}
+
+	cfg, err := r.describeConfig(ctx, collection)
+	if err != nil {
+		return CollectionConfig{}, err
+	}
+
+	r.mu.Lock()
+	r.configs[collection] = cfg
+	r.mu.Unlock()
+
+	return cfg, nil
+}
+
+func (r *MilvusRepository) describeConfig(ctx context.Context, name string) (CollectionConfig, error) {
+	coll, err := r.client.DescribeCollection(ctx, name)
+	if err != nil {
+		return CollectionConfig{}, err
+	}
+
+	var cfg CollectionConfig
+	for _, field := range coll.Schema.Fields {
+		switch field.Name {
+		case "embedding":
+			cfg.Dim, _ = strconv.Atoi(field.TypeParams["dim"])
+		case "payload":
+			cfg.MaxPayloadBytes, _ = strconv.Atoi(field.TypeParams["max_length"])
+		}
+	}
+
+	indexes, err := r.client.DescribeIndex(ctx, name, "embedding")
+	if err != nil {
+		return CollectionConfig{}, err
+	}
+	if len(indexes) == 0 {
+		return CollectionConfig{}, fmt.Errorf("collection %s has no embedding index", name)
 	}
+	params := indexParams(indexes[0])
+	cfg.Index = IndexType(strings.ToUpper(params["index_type"]))
+	cfg.Metric = Metric(strings.ToUpper(params["metric_type"]))
+	cfg.NList, _ = strconv.Atoi(params["nlist"])
+	cfg.M, _ = strconv.Atoi(params["M"])
+	cfg.EfConstruction, _ = strconv.Atoi(params["efConstruction"])
+
+	return cfg.WithDefaults(), nil
+}
+
+func newIndex(cfg CollectionConfig) (entity.Index, error) {
+	metric := entity.MetricType(cfg.Metric)
+	switch cfg.Index {
+	case IndexFlat:
+		return entity.NewIndexFlat(metric)
+	case IndexIVFSQ8:
+		return entity.NewIndexIvfSQ8(metric, cfg.NList)
+	case IndexHNSW:
+		return entity.NewIndexHNSW(metric, cfg.M, cfg.EfConstruction)
+	default:
+		return entity.NewIndexIvfFlat(metric, cfg.NList)
+	}
+}
 
-	return r.client.LoadCollection(ctx, name, false)
+// searchParam builds the search parameters of the collection index; HNSW
+// needs ef of at least topK.
+func searchParam(cfg CollectionConfig, topK int) (entity.SearchParam, error) {
+	switch cfg.Index {
+	case IndexFlat:
+		return entity.NewIndexFlatSearchParam()
+	case IndexIVFSQ8:
+		return entity.NewIndexIvfSQ8SearchParam(cfg.NProbe)
+	case IndexHNSW:
+		return entity.NewIndexHNSWSearchParam(max(cfg.Ef, topK))
+	default:
+		return entity.NewIndexIvfFlatSearchParam(cfg.NProbe)
+	}
+}
+
+// indexParams flattens the description of an index. Older servers nest the
+// build parameters in a JSON "params" value, newer ones list them next to
+// the index type.
+func indexParams(index entity.Index) map[string]string {
+	params := make(map[string]string)
+	for key, value := range index.Params() {
+		params[key] = value
+	}
+	if nested := params["params"]; nested != "" {
+		var values map[string]any
+		if err := json.Unmarshal([]byte(nested), &values); err == nil {
+			for key, value := range values {
+				params[key] = fmt.Sprint(value)
+			}
+		}
+	}
+	return params
+}
+
+// migrateMetadata upgrades a collection created before chunk metadata was
+// stored. Milvus cannot add a field to a collection, so the items are
+// copied with empty metadata into <name>_migrating, which replaces the old
+// collection once every item is there; resumeMigration finishes the rename
+// when a run stops between the drop and the rename.
+func (r *MilvusRepository) migrateMetadata(ctx context.Context, name string, cfg CollectionConfig) error {
+	coll, err := r.client.DescribeCollection(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, field := range coll.Schema.Fields {
+		if field.Name == "metadata" {
+			return nil
+		}
+	}
+
+	staging := name + migrationSuffix
+	slog.Info("copying collection to add the metadata field", slog.String("collection", name), slog.String("staging", staging))
+
+	exists, err := r.client.HasCollection(ctx, staging)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if err := r.DropCollection(ctx, staging); err != nil {
+			return err
+		}
+	}
+	if err := r.createCollection(ctx, staging, cfg); err != nil {
+		return err
+	}
+	if err := r.client.LoadCollection(ctx, staging, false); err != nil {
+		return err
+	}
+	if err := r.client.LoadCollection(ctx, name, false); err != nil {
+		return err
+	}
+
+	// The legacy collection has no metadata column to ask for.
+	req := ScanRequest{OutputFields: []Field{FieldPayload, FieldDataSource}, Embeddings: true}
+	err = r.Scan(ctx, name, req, func(items []VectorItem) error {
+		return r.Upsert(ctx, staging, items)
+	})
+	if err != nil {
+		return err
+	}
+
+	want, err := r.Count(ctx, name)
+	if err != nil {
+		return err
+	}
+	got, err := r.Count(ctx, staging)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("copied %d of %d items into %s", got, want, staging)
+	}
+
+	if err := r.DropCollection(ctx, name); err != nil {
+		return err
+	}
+	_, err = r.resumeMigration(ctx, name)
+	return err
+}
+
+// resumeMigration renames a complete migration copy of name into place
+// and reports whether there was one.
+func (r *MilvusRepository) resumeMigration(ctx context.Context, name string) (bool, error) {
+	staging := name + migrationSuffix
+	exists, err := r.client.HasCollection(ctx, staging)
+	if err != nil || !exists {
+		return false, err
+	}
+	if err := r.client.RenameCollection(ctx, staging, name); err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	delete(r.configs, staging)
+	r.mu.Unlock()
+
+	return true, nil
 }
 
 func (r *MilvusRepository) Upsert(ctx context.Context, collection string, items []VectorItem) error {
@@ -90,10 +342,16 @@ func (r *MilvusRepository) Upsert(ctx context.Context, collection string, items
 		return nil
 	}
 
+	cfg, err := r.config(ctx, collection)
+	if err != nil {
+		return err
+	}
+
 	ids := make([]int64, 0, len(items))
 	vectors := make([][]float32, 0, len(items))
 	payloads := make([]string, 0, len(items))
 	dataSources := make([]string, 0, len(items))
+	metadata := make([][]byte, 0, len(items))
 
 	var dim int
 	for i, item := range items {
@@ -108,11 +366,20 @@ func (r *MilvusRepository) Upsert(ctx context.Context, collection string, items
 		if strings.TrimSpace(item.DataSource) == "" {
 			return fmt.Errorf("data_source is required for item id %d", item.ID)
 		}
+		if limit := cfg.MaxPayloadBytes; len(item.Payload) > limit {
+			return fmt.Errorf("%w: item id %d has %d bytes, limit %d", ErrPayloadTooLarge, item.ID, len(item.Payload), limit)
+		}
+
+		meta, err := json.Marshal(item.Metadata)
+		if err != nil {
+			return fmt.Errorf("encode metadata for item id %d: %w", item.ID, err)
+		}
 
 		ids = append(ids, item.ID)
 		vectors = append(vectors, item.Embedding)
 		payloads = append(payloads, item.Payload)
 		dataSources = append(dataSources, item.DataSource)
+		metadata = append(metadata, meta)
 	}
 
 	columns := []entity.Column{
@@ -120,97 +387,119 @@ func (r *MilvusRepository) Upsert(ctx context.Context, collection string, items
 		entity.NewColumnFloatVector("embedding", dim, vectors),
 		entity.NewColumnVarChar("payload", payloads),
 		entity.NewColumnVarChar("data_source", dataSources),
+		entity.NewColumnJSONBytes("metadata", metadata),
 	}
 
-	_, err := r.client.Insert(ctx, collection, "", columns...)
-	if err != nil {
+	if _, err := r.client.Upsert(ctx, collection, "", columns...); err != nil {
 		return err
 	}
 
 	return r.client.Flush(ctx, collection, false)
 }
 
-func (r *MilvusRepository) Search(ctx context.Context, collection string, vector []float32, topK int) ([]SearchHit, error) {
-	if len(vector) == 0 {
-		return nil, fmt.Errorf("empty query vector")
+func (r *MilvusRepository) Delete(ctx context.Context, collection string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := r.client.DeleteByPks(ctx, collection, "", entity.NewColumnInt64("id", ids)); err != nil {
+		return err
 	}
 
-	query := []entity.Vector{entity.FloatVector(vector)}
-	searchParams, err := entity.NewIndexIvfFlatSearchParam(64)
-	if err != nil {
-		return nil, err
+	return r.client.Flush(ctx, collection, false)
+}
+
+func (r *MilvusRepository) Get(ctx context.Context, collection string, ids []int64) ([]VectorItem, error) {
+	if len(ids) == 0 {
+		return nil, nil
 	}
 
-	results, err := r.client.Search(
+	rs, err := r.client.QueryByPks(
 		ctx,
 		collection,
 		[]string{},
-		"",
-		[]string{"id", "payload", "data_source"},
-		query,
-		"embedding",
-		entity.L2,
-		topK,
-		searchParams,
+		entity.NewColumnInt64("id", ids),
+		[]string{"id", "embedding", "payload", "data_source", "metadata"},
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	hits := make([]SearchHit, 0, topK)
-	for _, result := range results {
-		idColumn := result.Fields.GetColumn("id")
-		if idColumn == nil {
-			idColumn = result.IDs
+	idColumn := rs.GetColumn("id")
+	vectorColumn, ok := rs.GetColumn("embedding").(*entity.ColumnFloatVector)
+	payloadColumn := rs.GetColumn("payload")
+	sourceColumn := rs.GetColumn("data_source")
+	metadataColumn := rs.GetColumn("metadata")
+	if idColumn == nil || !ok || payloadColumn == nil || sourceColumn == nil || metadataColumn == nil {
+		return nil, fmt.Errorf("incomplete query result for collection %s", collection)
+	}
+
+	vectors := vectorColumn.Data()
+	items := make([]VectorItem, 0, idColumn.Len())
+	for i := 0; i < idColumn.Len(); i++ {
+		id, err := idColumn.GetAsInt64(i)
+		if err != nil {
+			return nil, err
 		}
-		payloadColumn := result.Fields.GetColumn("payload")
-		sourceColumn := result.Fields.GetColumn("data_source")
-		if idColumn == nil {
-			return nil, fmt.Errorf("missing id column in search result")
+		payload, err := payloadColumn.GetAsString(i)
+		if err != nil {
+			return nil, err
 		}
-		if payloadColumn == nil {
-			return nil, fmt.Errorf("missing payload column in search result")
+		source, err := sourceColumn.GetAsString(i)
+		if err != nil {
+			return nil, err
 		}
-		if sourceColumn == nil {
-			return nil, fmt.Errorf("missing data_source column in search result")
+		raw, err := metadataColumn.GetAsString(i)
+		if err != nil {
+			return nil, err
 		}
 
-		for i := 0; i < result.ResultCount; i++ {
-			id, err := idColumn.GetAsInt64(i)
-			if err != nil {
-				return nil, err
-			}
-			payload, err := payloadColumn.GetAsString(i)
-			if err != nil {
-				return nil, err
-			}
-			dataSource, err := sourceColumn.GetAsString(i)
-			if err != nil {
-				return nil, err
-			}
-
-			hits = append(hits, SearchHit{
-				ID:         id,
-				Score:      result.Scores[i],
-				Payload:    payload,
-				DataSource: dataSource,
-			})
+		item := VectorItem{ID: id, Embedding: vectors[i], Payload: payload, DataSource: source}
+		if err := json.Unmarshal([]byte(raw), &item.Metadata); err != nil {
+			return nil, fmt.Errorf("decode metadata of id %d: %w", id, err)
 		}
+		items = append(items, item)
 	}
 
-	return hits, nil
+	return items, nil
 }
 
-func (r *MilvusRepository) SearchByDataSource(ctx context.Context, collection string, vector []float32, topK int, dataSource string) ([]SearchHit, error) {
-	if len(vector) == 0 {
-		return nil, fmt.Errorf("empty query vector")
+func (r *MilvusRepository) Search(ctx context.Context, collection string, req SearchRequest) ([]SearchHit, error) {
+	hits, err := r.SearchBatch(ctx, collection, [][]float32{req.Vector}, req)
+	if err != nil {
+		return nil, err
 	}
-	if strings.TrimSpace(dataSource) == "" {
-		return nil, fmt.Errorf("data_source is required")
+	return hits[0], nil
+}
+
+// SearchBatch sends all vectors in one request. The filter is compiled to a
+// Milvus expression and only the selected output fields are fetched.
+func (r *MilvusRepository) SearchBatch(ctx context.Context, collection string, vectors [][]float32, req SearchRequest) ([][]SearchHit, error) {
+	if len(vectors) == 0 {
+		return nil, nil
+	}
+	query := make([]entity.Vector, len(vectors))
+	for i, vector := range vectors {
+		req.Vector = vector
+		if err := req.Validate(); err != nil {
+			return nil, err
+		}
+		query[i] = entity.FloatVector(vector)
+	}
+
+	expr := ""
+	if req.Filter != nil {
+		var err error
+		if expr, err = req.Filter.Expr(); err != nil {
+			return nil, err
+		}
 	}
 
-	query := []entity.Vector{entity.FloatVector(vector)}
-	searchParams, err := entity.NewIndexIvfFlatSearchParam(64)
+	cfg, err := r.config(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	searchParams, err := searchParam(cfg, req.Offset+req.TopK)
 	if err != nil {
 		return nil, err
 	}
@@ -219,72 +508,88 @@ func (r *MilvusRepository) SearchByDataSource(ctx context.Context, collection st
 		ctx,
 		collection,
 		[]string{},
-		buildSingleDataSourceExpr(dataSource),
-		[]string{"id", "payload", "data_source"},
+		expr,
+		req.outputFields(),
 		query,
 		"embedding",
-		entity.L2,
-		topK,
+		entity.MetricType(cfg.Metric),
+		req.TopK,
 		searchParams,
+		client.WithOffset(int64(req.Offset)),
 	)
 	if err != nil {
 		return nil, err
 	}
+	if len(results) != len(vectors) {
+		return nil, fmt.Errorf("search returned %d result sets for %d vectors", len(results), len(vectors))
+	}
 
-	hits := make([]SearchHit, 0, topK)
-	for _, result := range results {
-		idColumn := result.Fields.GetColumn("id")
-		if idColumn == nil {
-			idColumn = result.IDs
-		}
-		payloadColumn := result.Fields.GetColumn("payload")
-		sourceColumn := result.Fields.GetColumn("data_source")
-		if idColumn == nil {
-			return nil, fmt.Errorf("missing id column in search result")
+	batch := make([][]SearchHit, len(results))
+	for i, result := range results {
+		hits, err := parseSearchResult(result, req, cfg.Metric)
+		if err != nil {
+			return nil, err
 		}
-		if payloadColumn == nil {
-			return nil, fmt.Errorf("missing payload column in search result")
+		batch[i] = req.aboveMinScore(hits)
+	}
+	return batch, nil
+}
+
+// parseSearchResult reads the hits of one query vector and normalizes the
+// scores so that higher is more relevant whatever the metric.
+func parseSearchResult(result client.SearchResult, req SearchRequest, metric Metric) ([]SearchHit, error) {
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	idColumn := result.Fields.GetColumn("id")
+	if idColumn == nil {
+		idColumn = result.IDs
+	}
+	payloadColumn := result.Fields.GetColumn("payload")
+	sourceColumn := result.Fields.GetColumn("data_source")
+	metadataColumn := result.Fields.GetColumn("metadata")
+	if idColumn == nil {
+		return nil, fmt.Errorf("missing id column in search result")
+	}
+	if payloadColumn == nil && req.Wants(FieldPayload) {
+		return nil, fmt.Errorf("missing payload column in search result")
+	}
+	if sourceColumn == nil && req.Wants(FieldDataSource) {
+		return nil, fmt.Errorf("missing data_source column in search result")
+	}
+
+	hits := make([]SearchHit, 0, result.ResultCount)
+	for i := 0; i < result.ResultCount; i++ {
+		id, err := idColumn.GetAsInt64(i)
+		if err != nil {
+			return nil, err
 		}
-		if sourceColumn == nil {
-			return nil, fmt.Errorf("missing data_source column in search result")
+		hit := SearchHit{
+			ID:    id,
+			Score: NormalizeScore(metric, result.Scores[i]),
 		}
-
-		for i := 0; i < result.ResultCount; i++ {
-			id, err := idColumn.GetAsInt64(i)
-			if err != nil {
+		if payloadColumn != nil {
+			if hit.Payload, err = payloadColumn.GetAsString(i); err != nil {
 				return nil, err
 			}
-			payload, err := payloadColumn.GetAsString(i)
-			if err != nil {
+		}
+		if sourceColumn != nil {
+			if hit.DataSource, err = sourceColumn.GetAsString(i); err != nil {
 				return nil, err
 			}
-			source, err := sourceColumn.GetAsString(i)
+		}
+		if metadataColumn != nil {
+			raw, err := metadataColumn.GetAsString(i)
 			if err != nil {
 				return nil, err
 			}
-
-			hits = append(hits, SearchHit{
-				ID:         id,
-				Score:      result.Scores[i],
-				Payload:    payload,
-				DataSource: source,
-			})
+			if err := json.Unmarshal([]byte(raw), &hit.Metadata); err != nil {
+				return nil, fmt.Errorf("decode metadata of id %d: %w", id, err)
+			}
 		}
+		hits = append(hits, hit)
 	}
 
 	return hits, nil
 }
-
-func buildSingleDataSourceExpr(source string) string {
-	trimmed := strings.TrimSpace(source)
-	if trimmed == "" {
-		return ""
-	}
-	return "data_source == \"" + escapeMilvusString(trimmed) + "\""
-}
-
-func escapeMilvusString(value string) string {
-	value = strings.ReplaceAll(value, "\\", "\\\\")
-	value = strings.ReplaceAll(value, "\"", "\\\"")
-	return value
-}
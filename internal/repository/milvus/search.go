@@ -0,0 +1,110 @@
+package milvus
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var defaultOutputFields = []Field{FieldPayload, FieldDataSource, FieldMetadata}
+
+// SearchRequest is one vector search. Filter limits the candidates, nil
+// matches every item. OutputFields selects the returned columns among
+// payload, data_source and metadata, all of them when empty; IDs and
+// scores are always returned. Offset skips that many best hits for paging
+// and MinScore drops hits scoring below it, zero keeps them all.
+type SearchRequest struct {
+	Vector       []float32
+	TopK         int
+	Offset       int
+	Filter       Filter
+	OutputFields []Field
+	MinScore     float32
+}
+
+func (r SearchRequest) Validate() error {
+	if len(r.Vector) == 0 {
+		return errors.New("empty query vector")
+	}
+	if r.TopK <= 0 {
+		return fmt.Errorf("topK must be positive, got %d", r.TopK)
+	}
+	if r.Offset < 0 {
+		return fmt.Errorf("offset must not be negative, got %d", r.Offset)
+	}
+	if err := validateOutputFields(r.OutputFields); err != nil {
+		return err
+	}
+	if r.Filter != nil {
+		if _, err := r.Filter.Expr(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Wants reports whether field is among the returned columns.
+func (r SearchRequest) Wants(field Field) bool {
+	return wants(r.OutputFields, field)
+}
+
+func (r SearchRequest) outputFields() []string {
+	return outputFields(r.OutputFields)
+}
+
+// Window cuts hits ordered best first to the requested page, drops the ones
+// below MinScore and clears the columns that were not asked for. The stores
+// that search without Milvus apply it to their full ranking.
+func (r SearchRequest) Window(hits []SearchHit) []SearchHit {
+	hits = r.aboveMinScore(hits)
+	if r.Offset >= len(hits) {
+		return hits[:0]
+	}
+	hits = hits[r.Offset:]
+	if len(hits) > r.TopK {
+		hits = hits[:r.TopK]
+	}
+
+	for i := range hits {
+		if !r.Wants(FieldPayload) {
+			hits[i].Payload = ""
+		}
+		if !r.Wants(FieldDataSource) {
+			hits[i].DataSource = ""
+		}
+		if !r.Wants(FieldMetadata) {
+			hits[i].Metadata = ChunkMetadata{}
+		}
+	}
+	return hits
+}
+
+func (r SearchRequest) aboveMinScore(hits []SearchHit) []SearchHit {
+	if r.MinScore == 0 {
+		return hits
+	}
+	return slices.DeleteFunc(hits, func(hit SearchHit) bool { return hit.Score < r.MinScore })
+}
+
+func validateOutputFields(fields []Field) error {
+	for _, field := range fields {
+		if !slices.Contains(defaultOutputFields, field) {
+			return fmt.Errorf("field %q cannot be returned", field)
+		}
+	}
+	return nil
+}
+
+func wants(fields []Field, field Field) bool {
+	return len(fields) == 0 || slices.Contains(fields, field)
+}
+
+func outputFields(selected []Field) []string {
+	fields := []string{string(FieldID)}
+	for _, field := range defaultOutputFields {
+		if wants(selected, field) {
+			fields = append(fields, string(field))
+		}
+	}
+	return fields
+}
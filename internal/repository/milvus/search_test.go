@@ -0,0 +1,123 @@
+package milvus
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// searchClient records the one search request it gets and answers it with
+// results.
+type searchClient struct {
+	client.Client
+
+	calls   int
+	expr    string
+	output  []string
+	vectors []entity.Vector
+	metric  entity.MetricType
+	topK    int
+	options client.SearchQueryOption
+	results []client.SearchResult
+}
+
+func (c *searchClient) Search(_ context.Context, _ string, _ []string, expr string, outputFields []string, vectors []entity.Vector, _ string, metricType entity.MetricType, topK int, _ entity.SearchParam, opts ...client.SearchQueryOptionFunc) ([]client.SearchResult, error) {
+	c.calls++
+	c.expr, c.output, c.vectors, c.metric, c.topK = expr, outputFields, vectors, metricType, topK
+	for _, opt := range opts {
+		opt(&c.options)
+	}
+	return c.results, nil
+}
+
+func searchResult(ids []int64, scores []float32, source string) client.SearchResult {
+	sources := make([]string, len(ids))
+	for i := range sources {
+		sources[i] = source
+	}
+	return client.SearchResult{
+		ResultCount: len(ids),
+		IDs:         entity.NewColumnInt64("id", ids),
+		Fields:      client.ResultSet{entity.NewColumnVarChar("data_source", sources)},
+		Scores:      scores,
+	}
+}
+
+func TestSearchBatch(t *testing.T) {
+	ctx := context.Background()
+	fake := &searchClient{results: []client.SearchResult{
+		searchResult([]int64{3, 4}, []float32{0.9, 0.2}, "b.md"),
+		searchResult([]int64{2}, []float32{0.8}, "b.md"),
+	}}
+	repo := &MilvusRepository{
+		client:  fake,
+		configs: map[string]CollectionConfig{"kb": CollectionConfig{Dim: 2, Metric: MetricCosine}.WithDefaults()},
+	}
+
+	queries := [][]float32{{0, 1}, {1, 0}}
+	req := SearchRequest{
+		TopK:         2,
+		Offset:       1,
+		Filter:       Eq(FieldDocType, "docx"),
+		OutputFields: []Field{FieldDataSource},
+		MinScore:     0.5,
+	}
+	batch, err := repo.SearchBatch(ctx, "kb", queries, req)
+	if err != nil {
+		t.Fatalf("SearchBatch: %v", err)
+	}
+
+	if fake.calls != 1 {
+		t.Errorf("sent %d search requests, want 1", fake.calls)
+	}
+	if len(fake.vectors) != len(queries) {
+		t.Fatalf("sent %d query vectors, want %d", len(fake.vectors), len(queries))
+	}
+	for i, vector := range fake.vectors {
+		if got := []float32(vector.(entity.FloatVector)); !slices.Equal(got, queries[i]) {
+			t.Errorf("query vector %d = %v, want %v", i, got, queries[i])
+		}
+	}
+	if want := `metadata["doc_type"] == "docx"`; fake.expr != want {
+		t.Errorf("expr = %s, want %s", fake.expr, want)
+	}
+	if want := []string{"id", "data_source"}; !slices.Equal(fake.output, want) {
+		t.Errorf("output fields = %v, want %v", fake.output, want)
+	}
+	if fake.topK != 2 || fake.options.Offset != 1 {
+		t.Errorf("topK, offset = %d, %d; want 2, 1", fake.topK, fake.options.Offset)
+	}
+	if fake.metric != entity.COSINE {
+		t.Errorf("metric = %s, want COSINE", fake.metric)
+	}
+
+	// Each result set belongs to the query at its index; MinScore is
+	// applied to every one.
+	want := [][]int64{{3}, {2}}
+	if len(batch) != len(want) {
+		t.Fatalf("got %d result lists, want %d", len(batch), len(want))
+	}
+	for i, hits := range batch {
+		ids := make([]int64, 0, len(hits))
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+			if hit.DataSource != "b.md" {
+				t.Errorf("hit %d has data_source %q, want b.md", hit.ID, hit.DataSource)
+			}
+		}
+		if !slices.Equal(ids, want[i]) {
+			t.Errorf("query %d: hits = %v, want %v", i, ids, want[i])
+		}
+	}
+
+	fake.results = fake.results[:1]
+	if _, err := repo.SearchBatch(ctx, "kb", queries, req); err == nil {
+		t.Error("SearchBatch accepted fewer result sets than queries")
+	}
+	if _, err := repo.SearchBatch(ctx, "kb", [][]float32{{1, 0}, nil}, req); err == nil {
+		t.Error("SearchBatch accepted an empty query vector")
+	}
+}
@@ -0,0 +1,291 @@
+package textstore
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"unicode/utf8"
+
+	milvusrepo "rag-test/internal/repository/milvus"
+)
+
+// Repository wraps a VectorRepository so that payloads over the collection
+// max_length are cut to fit and their full text goes to the Store. Search
+// results get the full text back, so callers never see a truncated chunk.
+// The max_length is read from the stored collection, so a collection made
+// by another process or an import is cut at its own limit.
+type Repository struct {
+	milvusrepo.VectorRepository
+
+	store *Store
+
+	mu     sync.RWMutex
+	limits map[string]int
+}
+
+func NewRepository(inner milvusrepo.VectorRepository, store *Store) *Repository {
+	return &Repository{
+		VectorRepository: inner,
+		store:            store,
+		limits:           make(map[string]int),
+	}
+}
+
+func (r *Repository) EnsureCollection(ctx context.Context, name string, cfg milvusrepo.CollectionConfig) error {
+	if err := r.VectorRepository.EnsureCollection(ctx, name, cfg); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	delete(r.limits, name)
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *Repository) Upsert(ctx context.Context, collection string, items []milvusrepo.VectorItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	limit, err := r.limit(ctx, collection)
+	if err != nil {
+		return err
+	}
+
+	fitted := make([]milvusrepo.VectorItem, len(items))
+	fitting := make([]int64, 0, len(items))
+	for i, item := range items {
+		if len(item.Payload) <= limit {
+			item.Metadata.Truncated = false
+			fitted[i] = item
+			fitting = append(fitting, item.ID)
+			continue
+		}
+
+		if err := r.store.Put(collection, item.ID, item.Payload); err != nil {
+			return err
+		}
+		slog.Debug(
+			"payload moved to text store",
+			slog.Int64("id", item.ID),
+			slog.Int("bytes", len(item.Payload)),
+			slog.Int("limit", limit),
+		)
+
+		item.Payload = truncateBytes(item.Payload, limit)
+		item.Metadata.Truncated = true
+		fitted[i] = item
+	}
+
+	if err := r.VectorRepository.Upsert(ctx, collection, fitted); err != nil {
+		return err
+	}
+
+	// A chunk that used to be oversized may fit now.
+	return r.store.Delete(collection, fitting)
+}
+
+func (r *Repository) Delete(ctx context.Context, collection string, ids []int64) error {
+	if err := r.VectorRepository.Delete(ctx, collection, ids); err != nil {
+		return err
+	}
+	return r.store.Delete(collection, ids)
+}
+
+func (r *Repository) Search(ctx context.Context, collection string, req milvusrepo.SearchRequest) ([]milvusrepo.SearchHit, error) {
+	hits, err := r.SearchBatch(ctx, collection, [][]float32{req.Vector}, req)
+	if err != nil {
+		return nil, err
+	}
+	return hits[0], nil
+}
+
+// SearchBatch rehydrates the payloads it returns. It needs the Truncated
+// flag for that, so metadata is fetched whenever the payload is and cleared
+// again when it was not asked for.
+func (r *Repository) SearchBatch(ctx context.Context, collection string, vectors [][]float32, req milvusrepo.SearchRequest) ([][]milvusrepo.SearchHit, error) {
+	if !req.Wants(milvusrepo.FieldPayload) {
+		return r.VectorRepository.SearchBatch(ctx, collection, vectors, req)
+	}
+
+	inner := req
+	withMetadata := req.Wants(milvusrepo.FieldMetadata)
+	if !withMetadata {
+		inner.OutputFields = append(slices.Clone(req.OutputFields), milvusrepo.FieldMetadata)
+	}
+
+	batch, err := r.VectorRepository.SearchBatch(ctx, collection, vectors, inner)
+	if err != nil {
+		return nil, err
+	}
+	for i, hits := range batch {
+		if batch[i], err = r.rehydrate(collection, hits); err != nil {
+			return nil, err
+		}
+		if !withMetadata {
+			for j := range batch[i] {
+				batch[i][j].Metadata = milvusrepo.ChunkMetadata{}
+			}
+		}
+	}
+	return batch, nil
+}
+
+func (r *Repository) Get(ctx context.Context, collection string, ids []int64) ([]milvusrepo.VectorItem, error) {
+	items, err := r.VectorRepository.Get(ctx, collection, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	truncated := make([]int64, 0)
+	for _, item := range items {
+		if item.Metadata.Truncated {
+			truncated = append(truncated, item.ID)
+		}
+	}
+	texts, err := r.fullTexts(collection, truncated)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, item := range items {
+		if text, ok := texts[item.ID]; ok {
+			items[i].Payload = text
+			items[i].Metadata.Truncated = false
+		}
+	}
+	return items, nil
+}
+
+// Scan rehydrates payloads like SearchBatch.
+func (r *Repository) Scan(ctx context.Context, collection string, req milvusrepo.ScanRequest, fn func([]milvusrepo.VectorItem) error) error {
+	if !req.Wants(milvusrepo.FieldPayload) {
+		return r.VectorRepository.Scan(ctx, collection, req, fn)
+	}
+
+	inner := req
+	withMetadata := req.Wants(milvusrepo.FieldMetadata)
+	if !withMetadata {
+		inner.OutputFields = append(slices.Clone(req.OutputFields), milvusrepo.FieldMetadata)
+	}
+
+	return r.VectorRepository.Scan(ctx, collection, inner, func(items []milvusrepo.VectorItem) error {
+		truncated := make([]int64, 0)
+		for _, item := range items {
+			if item.Metadata.Truncated {
+				truncated = append(truncated, item.ID)
+			}
+		}
+		texts, err := r.fullTexts(collection, truncated)
+		if err != nil {
+			return err
+		}
+
+		for i, item := range items {
+			if text, ok := texts[item.ID]; ok {
+				items[i].Payload = text
+				items[i].Metadata.Truncated = false
+			}
+			if !withMetadata {
+				items[i].Metadata = milvusrepo.ChunkMetadata{}
+			}
+		}
+		return fn(items)
+	})
+}
+
+// DropCollection also removes the full texts and parent sections of the
+// collection.
+func (r *Repository) DropCollection(ctx context.Context, name string) error {
+	if err := r.VectorRepository.DropCollection(ctx, name); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	delete(r.limits, name)
+	r.mu.Unlock()
+
+	if err := r.store.Drop(name); err != nil {
+		return err
+	}
+	return r.store.Drop(SectionsNamespace(name))
+}
+
+// rehydrate swaps truncated payloads for the full text.
+func (r *Repository) rehydrate(collection string, hits []milvusrepo.SearchHit) ([]milvusrepo.SearchHit, error) {
+	truncated := make([]int64, 0)
+	for _, hit := range hits {
+		if hit.Metadata.Truncated {
+			truncated = append(truncated, hit.ID)
+		}
+	}
+	texts, err := r.fullTexts(collection, truncated)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, hit := range hits {
+		if text, ok := texts[hit.ID]; ok {
+			hits[i].Payload = text
+			hits[i].Metadata.Truncated = false
+		}
+	}
+	return hits, nil
+}
+
+// fullTexts loads the stored texts of truncated chunks. A missing text is
+// logged and the caller keeps the truncated payload.
+func (r *Repository) fullTexts(collection string, ids []int64) (map[int64]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	texts, err := r.store.Get(collection, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if _, ok := texts[id]; !ok {
+			slog.Warn("full text of truncated chunk is missing", slog.Int64("id", id))
+		}
+	}
+	return texts, nil
+}
+
+// limit is the payload max_length of collection as the inner repository
+// describes it, cached until the collection is ensured or dropped again.
+func (r *Repository) limit(ctx context.Context, collection string) (int, error) {
+	r.mu.RLock()
+	limit, ok := r.limits[collection]
+	r.mu.RUnlock()
+	if ok {
+		return limit, nil
+	}
+
+	info, err := r.VectorRepository.DescribeCollection(ctx, collection)
+	if err != nil {
+		return 0, err
+	}
+	limit = info.Config.MaxPayloadBytes
+	if limit <= 0 {
+		limit = milvusrepo.DefaultMaxPayloadBytes
+	}
+
+	r.mu.Lock()
+	r.limits[collection] = limit
+	r.mu.Unlock()
+
+	return limit, nil
+}
+
+func truncateBytes(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut]
+}
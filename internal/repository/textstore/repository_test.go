@@ -0,0 +1,87 @@
+package textstore
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"rag-test/internal/repository/memory"
+	milvusrepo "rag-test/internal/repository/milvus"
+)
+
+const testCollection = "kb"
+
+// TestPayloadLimit creates the collection on the inner repository, as
+// another process or an import would, and checks that the wrapper cuts
+// payloads at the stored max_length and returns the full text.
+func TestPayloadLimit(t *testing.T) {
+	const limit = 16
+
+	tests := []struct {
+		name          string
+		payload       string
+		wantTruncated bool
+	}{
+		{name: "fits", payload: "short text"},
+		{name: "exactly the limit", payload: strings.Repeat("a", limit)},
+		{name: "over the limit", payload: strings.Repeat("a", limit+1), wantTruncated: true},
+		{name: "cut inside a rune", payload: strings.Repeat("я", limit), wantTruncated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			inner, err := memory.NewRepository(memory.Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := inner.EnsureCollection(ctx, testCollection, milvusrepo.CollectionConfig{Dim: 2, MaxPayloadBytes: limit}); err != nil {
+				t.Fatal(err)
+			}
+			store, err := NewStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo := NewRepository(inner, store)
+
+			item := milvusrepo.VectorItem{ID: 1, Embedding: []float32{1, 0}, Payload: tt.payload, DataSource: "doc.md"}
+			if err := repo.Upsert(ctx, testCollection, []milvusrepo.VectorItem{item}); err != nil {
+				t.Fatalf("Upsert: %v", err)
+			}
+
+			stored, err := inner.Get(ctx, testCollection, []int64{1})
+			if err != nil || len(stored) != 1 {
+				t.Fatalf("inner Get = %v, %v", stored, err)
+			}
+			if len(stored[0].Payload) > limit {
+				t.Errorf("stored payload has %d bytes, limit %d", len(stored[0].Payload), limit)
+			}
+			if !strings.HasPrefix(tt.payload, stored[0].Payload) {
+				t.Errorf("stored payload %q is not a prefix of the chunk", stored[0].Payload)
+			}
+			if stored[0].Metadata.Truncated != tt.wantTruncated {
+				t.Errorf("truncated = %v, want %v", stored[0].Metadata.Truncated, tt.wantTruncated)
+			}
+
+			items, err := repo.Get(ctx, testCollection, []int64{1})
+			if err != nil || len(items) != 1 {
+				t.Fatalf("Get = %v, %v", items, err)
+			}
+			if items[0].Payload != tt.payload || items[0].Metadata.Truncated {
+				t.Errorf("Get payload = %q (truncated %v), want the full text", items[0].Payload, items[0].Metadata.Truncated)
+			}
+
+			hits, err := repo.Search(ctx, testCollection, milvusrepo.SearchRequest{
+				Vector:       []float32{1, 0},
+				TopK:         1,
+				OutputFields: []milvusrepo.Field{milvusrepo.FieldPayload},
+			})
+			if err != nil || len(hits) != 1 {
+				t.Fatalf("Search = %v, %v", hits, err)
+			}
+			if hits[0].Payload != tt.payload {
+				t.Errorf("Search payload = %q, want the full text", hits[0].Payload)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package textstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"rag-test/internal/storage/atomicfile"
+)
+
+// Store keeps full chunk texts that do not fit into the vector store payload,
+// one file per chunk under <dir>/<collection>/<id>.txt.
+type Store struct {
+	dir string
+}
+
+// SectionsNamespace is where the parent sections of the chunks in collection
+// are kept.
+func SectionsNamespace(collection string) string {
+	return collection + "_sections"
+}
+
+func NewStore(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, errors.New("text store dir is empty")
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) Put(collection string, id int64, text string) error {
+	return atomicfile.Write(s.path(collection, id), []byte(text))
+}
+
+// Get returns the stored texts; ids without a stored text are left out.
+func (s *Store) Get(collection string, ids []int64) (map[int64]string, error) {
+	texts := make(map[int64]string, len(ids))
+	for _, id := range ids {
+		data, err := os.ReadFile(s.path(collection, id))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read full text of chunk %d: %w", id, err)
+		}
+		texts[id] = string(data)
+	}
+	return texts, nil
+}
+
+func (s *Store) Delete(collection string, ids []int64) error {
+	for _, id := range ids {
+		if err := os.Remove(s.path(collection, id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Copy duplicates the texts of a collection under another name, replacing
+// whatever that name held. A collection without texts is no error.
+func (s *Store) Copy(from, to string) error {
+	if err := s.Drop(to); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(filepath.Join(s.dir, from))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Join(s.dir, to), 0o755); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".txt" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, from, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(s.dir, to, entry.Name()), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Drop removes every text of a collection.
+func (s *Store) Drop(collection string) error {
+	return os.RemoveAll(filepath.Join(s.dir, collection))
+}
+
+func (s *Store) path(collection string, id int64) string {
+	return filepath.Join(s.dir, collection, strconv.FormatInt(id, 10)+".txt")
+}
@@ -0,0 +1,60 @@
+// Package vectorstore opens the vector store backend the commands are
+// configured with.
+package vectorstore
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"rag-test/internal/repository/hnsw"
+	"rag-test/internal/repository/memory"
+	milvusrepo "rag-test/internal/repository/milvus"
+)
+
+// Config selects the backend and holds the settings of each one.
+type Config struct {
+	// Backend is milvus, memory or hnsw.
+	Backend        string
+	MilvusAddress  string
+	MemorySnapshot string
+	HNSWDir        string
+	HNSW           hnsw.Config
+}
+
+// DefaultConfig is the Milvus server on localhost, with the local backends
+// kept under .rag.
+func DefaultConfig() Config {
+	return Config{
+		Backend:        "milvus",
+		MilvusAddress:  "localhost:19530",
+		MemorySnapshot: ".rag/vectors.json",
+		HNSWDir:        ".rag/hnsw",
+	}
+}
+
+// RegisterFlags adds the vector store flags to fs, with the current values
+// of c as defaults.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Backend, "vector-store", c.Backend, "vector store: milvus, memory or hnsw")
+	fs.StringVar(&c.MilvusAddress, "milvus", c.MilvusAddress, "address of the Milvus server")
+	fs.StringVar(&c.MemorySnapshot, "memory-snapshot", c.MemorySnapshot, "file the memory vector store is kept in, empty keeps it in memory only")
+	fs.StringVar(&c.HNSWDir, "hnsw-dir", c.HNSWDir, "directory of the hnsw vector store")
+	fs.IntVar(&c.HNSW.M, "hnsw-m", 16, "links per node of the hnsw index")
+	fs.IntVar(&c.HNSW.EfConstruction, "hnsw-ef-construction", 200, "beam width of the hnsw index while inserting")
+	fs.IntVar(&c.HNSW.EfSearch, "hnsw-ef-search", 64, "beam width of the hnsw index while searching, higher raises recall")
+}
+
+// Open connects to or loads the configured backend.
+func Open(ctx context.Context, cfg Config) (milvusrepo.VectorRepository, error) {
+	switch cfg.Backend {
+	case "milvus":
+		return milvusrepo.NewMilvusRepository(ctx, cfg.MilvusAddress)
+	case "memory":
+		return memory.NewRepository(memory.Config{SnapshotPath: cfg.MemorySnapshot})
+	case "hnsw":
+		return hnsw.NewRepository(cfg.HNSWDir, cfg.HNSW)
+	default:
+		return nil, fmt.Errorf("unknown vector store %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,43 @@
+package vectorstore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		backend string
+		wantErr bool
+	}{
+		{backend: "memory"},
+		{backend: "hnsw"},
+		{backend: "faiss", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Backend = tt.backend
+			cfg.MemorySnapshot = filepath.Join(dir, "vectors.json")
+			cfg.HNSWDir = filepath.Join(dir, "hnsw")
+
+			repo, err := Open(context.Background(), cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Open succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer repo.Close()
+			if _, err := repo.ListCollections(context.Background()); err != nil {
+				t.Errorf("ListCollections: %v", err)
+			}
+		})
+	}
+}
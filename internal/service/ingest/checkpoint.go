@@ -0,0 +1,140 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"rag-test/internal/repository/checkpoint"
+	"rag-test/internal/repository/manifest"
+)
+
+// recoverCheckpoints deletes chunks that a crashed run wrote but never
+// recorded in the manifest. Without Resume the embedding progress is dropped
+// as well, so every file starts from its first batch.
+func (s *Service) recoverCheckpoints(ctx context.Context, m *manifest.Manifest) error {
+	if s.checkpoints == nil {
+		return nil
+	}
+
+	checkpoints, err := s.checkpoints.List()
+	if err != nil {
+		return err
+	}
+
+	chunks := make(map[int64]struct{})
+	questions := make(map[int64]struct{})
+	for _, entry := range m.Files {
+		for _, id := range entry.ChunkIDs {
+			chunks[id] = struct{}{}
+		}
+		for _, id := range entry.QuestionIDs {
+			questions[id] = struct{}{}
+		}
+	}
+
+	orphans := make([]int64, 0)
+	orphanQuestions := make([]int64, 0)
+	for _, cp := range checkpoints {
+		for _, id := range cp.PendingIDs {
+			if _, ok := chunks[id]; !ok {
+				orphans = append(orphans, id)
+			}
+		}
+		for _, id := range cp.PendingQuestionIDs {
+			if _, ok := questions[id]; !ok {
+				orphanQuestions = append(orphanQuestions, id)
+			}
+		}
+	}
+	if len(orphans) > 0 {
+		slog.Warn("deleting chunks of an interrupted run", slog.Int("count", len(orphans)))
+		if err := s.remover.deleteChunks(ctx, orphans); err != nil {
+			return err
+		}
+	}
+	if len(orphanQuestions) > 0 {
+		slog.Warn("deleting questions of an interrupted run", slog.Int("count", len(orphanQuestions)))
+		if err := s.remover.deleteQuestions(ctx, orphanQuestions); err != nil {
+			return err
+		}
+	}
+
+	if s.cfg.Resume {
+		for _, cp := range checkpoints {
+			cp.PendingIDs = nil
+			cp.PendingQuestionIDs = nil
+			if err := s.checkpoints.Save(cp); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return s.checkpoints.Reset()
+}
+
+// jobCheckpoint returns the checkpoint to continue the file from, or a fresh
+// one when there is none or it was made for other file contents, chunks,
+// model or pipeline version.
+func (s *Service) jobCheckpoint(job *fileJob) (*checkpoint.Checkpoint, error) {
+	if job.checkpoint != nil {
+		return job.checkpoint, nil
+	}
+
+	inputs := s.embeddingInputs(job)
+	fresh := &checkpoint.Checkpoint{
+		Path:           job.file.Path,
+		Hash:           job.hash,
+		Inputs:         hashInputs(inputs),
+		Pipeline:       pipelineVersion,
+		EmbeddingModel: s.embeddingsRepo.Model(),
+		Dim:            s.embeddingsRepo.Dim(),
+	}
+	job.checkpoint = fresh
+	if s.checkpoints == nil || !s.cfg.Resume {
+		return fresh, nil
+	}
+
+	cp, err := s.checkpoints.Load(job.file.Path)
+	if err != nil || cp == nil {
+		return fresh, err
+	}
+	if cp.Hash != fresh.Hash ||
+		cp.Inputs != fresh.Inputs ||
+		cp.Pipeline != fresh.Pipeline ||
+		cp.EmbeddingModel != fresh.EmbeddingModel ||
+		cp.Dim != fresh.Dim ||
+		len(cp.Embeddings) > len(inputs) ||
+		len(cp.Questions) > len(inputs) {
+		return fresh, nil
+	}
+
+	job.checkpoint = cp
+	return cp, nil
+}
+
+func (s *Service) saveCheckpoint(cp *checkpoint.Checkpoint) error {
+	if s.checkpoints == nil {
+		return nil
+	}
+	cp.UpdatedAt = time.Now().UTC()
+	return s.checkpoints.Save(cp)
+}
+
+func (s *Service) dropCheckpoint(path string) error {
+	if s.checkpoints == nil {
+		return nil
+	}
+	return s.checkpoints.Delete(path)
+}
+
+func hashInputs(inputs []string) string {
+	h := sha256.New()
+	for _, input := range inputs {
+		h.Write([]byte(input))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
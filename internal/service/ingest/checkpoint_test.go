@@ -0,0 +1,181 @@
+package ingest
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"rag-test/internal/helpers"
+	"rag-test/internal/loader"
+	"rag-test/internal/repository/checkpoint"
+	"rag-test/internal/repository/embeddings"
+	"rag-test/internal/repository/manifest"
+	"rag-test/internal/repository/memory"
+	milvusrepo "rag-test/internal/repository/milvus"
+)
+
+const testCollection = "kb"
+
+// newTestService returns a Service over an in-memory vector store holding
+// the testCollection. The embeddings repository is never called.
+func newTestService(t *testing.T, deps Dependencies, cfg Config) (*Service, *memory.Repository) {
+	t.Helper()
+	vectors, err := memory.NewRepository(memory.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vectors.EnsureCollection(context.Background(), testCollection, milvusrepo.CollectionConfig{Dim: 2}); err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := manifest.NewRepository(filepath.Join(t.TempDir(), "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedder, err := embeddings.NewRepository("test", embeddings.Config{Dim: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deps.Loaders = loader.NewRegistry()
+	deps.Embeddings = embedder
+	deps.Vectors = vectors
+	deps.Manifest = manifests
+	cfg.Collection = testCollection
+	svc, err := NewService(deps, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc, vectors
+}
+
+func newCheckpoints(t *testing.T) *checkpoint.Repository {
+	t.Helper()
+	repo, err := checkpoint.NewRepository(filepath.Join(t.TempDir(), "checkpoints"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestRecoverCheckpoints(t *testing.T) {
+	for _, resume := range []bool{true, false} {
+		t.Run(map[bool]string{true: "resume", false: "restart"}[resume], func(t *testing.T) {
+			ctx := context.Background()
+			checkpoints := newCheckpoints(t)
+			svc, vectors := newTestService(t, Dependencies{Checkpoints: checkpoints}, Config{Resume: resume})
+
+			// The crash came after chunks 1 and 2 were written, but only
+			// chunk 1 made it into the manifest.
+			err := vectors.Upsert(ctx, testCollection, []milvusrepo.VectorItem{
+				{ID: 1, Embedding: []float32{1, 0}, Payload: "a", DataSource: "a.md"},
+				{ID: 2, Embedding: []float32{0, 1}, Payload: "b", DataSource: "b.md"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := manifest.New(testCollection)
+			m.Files["a.md"] = manifest.FileEntry{Path: "a.md", ChunkIDs: []int64{1}}
+			cp := &checkpoint.Checkpoint{Path: "b.md", Embeddings: [][]float32{{0, 1}}, PendingIDs: []int64{1, 2}}
+			if err := checkpoints.Save(cp); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := svc.recoverCheckpoints(ctx, m); err != nil {
+				t.Fatalf("recoverCheckpoints: %v", err)
+			}
+
+			items, err := vectors.Get(ctx, testCollection, []int64{1, 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != 1 || items[0].ID != 1 {
+				t.Errorf("stored chunks = %+v, want only chunk 1", items)
+			}
+
+			kept, err := checkpoints.Load("b.md")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !resume {
+				if kept != nil {
+					t.Errorf("checkpoint kept without resume: %+v", kept)
+				}
+				return
+			}
+			if kept == nil || len(kept.PendingIDs) != 0 || len(kept.Embeddings) != 1 {
+				t.Errorf("checkpoint = %+v, want its embeddings without pending IDs", kept)
+			}
+		})
+	}
+}
+
+func TestJobCheckpoint(t *testing.T) {
+	newJob := func(hash string) *fileJob {
+		return &fileJob{
+			file:   documentFile{Path: "a.md"},
+			hash:   hash,
+			chunks: []helpers.TextChunk{{Text: "первый"}, {Text: "второй"}},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		resume     bool
+		hash       string
+		edit       func(cp *checkpoint.Checkpoint)
+		wantResume bool
+	}{
+		{name: "resume after a partial run", resume: true, hash: "h1", wantResume: true},
+		{name: "resume disabled", hash: "h1"},
+		{name: "file changed", resume: true, hash: "h2"},
+		{name: "other pipeline version", resume: true, hash: "h1", edit: func(cp *checkpoint.Checkpoint) { cp.Pipeline = pipelineVersion - 1 }},
+		{name: "other model", resume: true, hash: "h1", edit: func(cp *checkpoint.Checkpoint) { cp.EmbeddingModel = "other" }},
+		{
+			name:   "more embeddings than chunks",
+			resume: true,
+			hash:   "h1",
+			edit: func(cp *checkpoint.Checkpoint) {
+				cp.Embeddings = append(cp.Embeddings, []float32{1, 1}, []float32{1, 1})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkpoints := newCheckpoints(t)
+			svc, _ := newTestService(t, Dependencies{Checkpoints: checkpoints}, Config{Resume: tt.resume})
+
+			// The first run embedded one batch of the two chunks.
+			first, err := svc.jobCheckpoint(newJob("h1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			first.Embeddings = [][]float32{{1, 0}}
+			if tt.edit != nil {
+				tt.edit(first)
+			}
+			if err := svc.saveCheckpoint(first); err != nil {
+				t.Fatal(err)
+			}
+
+			cp, err := svc.jobCheckpoint(newJob(tt.hash))
+			if err != nil {
+				t.Fatalf("jobCheckpoint: %v", err)
+			}
+			if cp.Pipeline != pipelineVersion || cp.Hash != tt.hash {
+				t.Errorf("checkpoint = %+v, want hash %s of pipeline %d", cp, tt.hash, pipelineVersion)
+			}
+			wantEmbeddings := 0
+			if tt.wantResume {
+				wantEmbeddings = 1
+			}
+			if len(cp.Embeddings) != wantEmbeddings {
+				t.Errorf("checkpoint has %d embeddings, want %d", len(cp.Embeddings), wantEmbeddings)
+			}
+			if tt.wantResume && !slices.Equal(cp.Embeddings[0], []float32{1, 0}) {
+				t.Errorf("resumed embedding = %v, want [1 0]", cp.Embeddings[0])
+			}
+		})
+	}
+}
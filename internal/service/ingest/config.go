@@ -0,0 +1,85 @@
+package ingest
+
+import "rag-test/internal/helpers"
+
+// pipelineVersion is recorded in the manifest; bumping it re-ingests every
+// file on the next run after a change to how chunks are produced or indexed.
+const pipelineVersion = 5
+
+const (
+	defaultWorkers      = 4
+	defaultEmbedWorkers = 2
+	defaultLLMWorkers   = 2
+	defaultWriteWorkers = 1
+	defaultBatchTokens  = 8000
+	defaultBatchSize    = 256
+)
+
+type Config struct {
+	Collection   string
+	DocumentsDir string
+
+	LoadWorkers  int
+	SplitWorkers int
+	EmbedWorkers int
+	WriteWorkers int
+	// LLMWorkers limits the concurrent chat model requests of the context
+	// and questions stages, which have their own rate limits and latency.
+	LLMWorkers int
+
+	// BatchTokens caps the estimated token count of one embeddings request,
+	// BatchSize caps the number of inputs in it.
+	BatchTokens       int
+	BatchSize         int
+	RequestsPerSecond float64
+
+	// ContextualHeaders asks the chat model for a short context situating
+	// every chunk in its whole document and embeds it with the chunk;
+	// ContextInPayload also stores it in front of the payload.
+	ContextualHeaders bool
+	ContextInPayload  bool
+
+	// SectionMaxTokens enables parent sections: consecutive chunks under the
+	// same headings are grouped into sections of up to this many tokens that
+	// the answer stage reads instead of the matched chunk.
+	SectionMaxTokens int
+
+	// QuestionsPerChunk enables the stage that generates likely user
+	// questions for every chunk; they are searched next to the chunks.
+	QuestionsPerChunk int
+
+	// Resume continues files from the embeddings saved in their checkpoints.
+	Resume bool
+
+	// LoaderSplitters maps a loader name ("docx") and FileSplitters a file
+	// name pattern ("Выжимка*") to a splitter; everything else uses
+	// SplitterFixed.
+	LoaderSplitters map[string]string
+	FileSplitters   map[string]string
+	Semantic        helpers.SemanticOptions
+}
+
+func (c Config) withDefaults() Config {
+	if c.LoadWorkers <= 0 {
+		c.LoadWorkers = defaultWorkers
+	}
+	if c.SplitWorkers <= 0 {
+		c.SplitWorkers = defaultWorkers
+	}
+	if c.EmbedWorkers <= 0 {
+		c.EmbedWorkers = defaultEmbedWorkers
+	}
+	if c.LLMWorkers <= 0 {
+		c.LLMWorkers = defaultLLMWorkers
+	}
+	if c.WriteWorkers <= 0 {
+		c.WriteWorkers = defaultWriteWorkers
+	}
+	if c.BatchTokens <= 0 {
+		c.BatchTokens = defaultBatchTokens
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	return c
+}
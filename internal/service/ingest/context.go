@@ -0,0 +1,149 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"rag-test/internal/helpers"
+	openairepo "rag-test/internal/repository/openai"
+)
+
+const (
+	contextSystemPrompt = `Ты — модуль подготовки поискового индекса. Тебе дан весь документ и один его фрагмент.
+Напиши одно-два предложения, которые объясняют, к чему относится фрагмент в документе: о каком товаре, разделе или условии идёт речь.
+Контекст должен делать фрагмент понятным без остального документа.
+Не пересказывай фрагмент и не добавляй фактов, которых нет в документе.
+Ответь только текстом контекста, без пояснений и без markdown.`
+
+	contextMaxTokens = 150
+	// contextDocumentRunes keeps long documents within the model context.
+	contextDocumentRunes = 60000
+
+	contextModeEmbedding = "embedding"
+	contextModePayload   = "payload"
+)
+
+func (s *Service) contextEnabled() bool {
+	return s.openaiRepo != nil && s.contextsRepo != nil && s.cfg.ContextualHeaders
+}
+
+// contextMode is recorded in the manifest, so switching the option
+// re-ingests every file.
+func (s *Service) contextMode() string {
+	switch {
+	case !s.contextEnabled():
+		return ""
+	case s.cfg.ContextInPayload:
+		return contextModePayload
+	default:
+		return contextModeEmbedding
+	}
+}
+
+// situateChunks generates the situating context of every chunk, reusing the
+// contexts cached for this document hash.
+func (s *Service) situateChunks(ctx context.Context, job *fileJob, _ *runState) error {
+	if !s.contextEnabled() {
+		return nil
+	}
+
+	cache, err := s.contextsRepo.Load(job.hash)
+	if err != nil {
+		return err
+	}
+
+	document := job.doc.Markdown
+	if runes := []rune(document); len(runes) > contextDocumentRunes {
+		document = string(runes[:contextDocumentRunes])
+	}
+
+	job.contexts = make([]string, len(job.chunks))
+	generated := 0
+	for i, chunk := range job.chunks {
+		if cached, ok := cache.Get(chunk.Text); ok {
+			job.contexts[i] = cached
+			continue
+		}
+
+		situated, err := s.situate(ctx, job.doc.Title, document, chunk.Text)
+		if err != nil {
+			return err
+		}
+		cache.Put(chunk.Text, situated)
+		if err := s.contextsRepo.Save(cache); err != nil {
+			return err
+		}
+		job.contexts[i] = situated
+		generated++
+	}
+	slog.Debug(
+		"chunk contexts ready",
+		slog.String("path", job.file.Path),
+		slog.Int("generated", generated),
+		slog.Int("cached", len(job.chunks)-generated),
+	)
+
+	if job.previous != nil && job.previous.Hash != job.hash {
+		if err := s.contextsRepo.Delete(job.previous.Hash); err != nil {
+			slog.Warn("failed to drop stale context cache", slog.String("path", job.file.Path), slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+func (s *Service) situate(ctx context.Context, title, document, chunk string) (string, error) {
+	if err := s.limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+
+	userPrompt := fmt.Sprintf("Документ «%s»:\n<document>\n%s\n</document>\n\nФрагмент:\n<chunk>\n%s\n</chunk>", title, document, chunk)
+	resp, err := s.openaiRepo.CreateChatCompletion(ctx, openairepo.ChatCompletionRequest{
+		Messages: []openairepo.Message{
+			{Role: openairepo.RoleSystem, Content: contextSystemPrompt},
+			{Role: openairepo.RoleUser, Content: userPrompt},
+		},
+		Temperature: 0,
+		MaxTokens:   contextMaxTokens,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(strings.Fields(resp.Content), " "), nil
+}
+
+// embeddingInputs are the normalized texts embedded for the chunks, with
+// the situating context in front.
+func (s *Service) embeddingInputs(job *fileJob) []string {
+	inputs := make([]string, len(job.chunks))
+	for i, chunk := range job.chunks {
+		inputs[i] = helpers.NormalizeText(withContext(jobContext(job, i), chunk.Text))
+	}
+	return inputs
+}
+
+// payloadText is the stored text of chunk i. The chunk text itself stays
+// as split, so IDs, duplicates, questions and sections do not depend on the
+// context option.
+func (s *Service) payloadText(job *fileJob, i int) string {
+	if s.cfg.ContextInPayload {
+		return withContext(jobContext(job, i), job.chunks[i].Text)
+	}
+	return job.chunks[i].Text
+}
+
+func withContext(situating, text string) string {
+	if situating == "" {
+		return text
+	}
+	return situating + "\n\n" + text
+}
+
+func jobContext(job *fileJob, i int) string {
+	if i < len(job.contexts) {
+		return job.contexts[i]
+	}
+	return ""
+}
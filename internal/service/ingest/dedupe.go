@@ -0,0 +1,73 @@
+package ingest
+
+import (
+	"slices"
+	"sort"
+
+	"rag-test/internal/helpers"
+	"rag-test/internal/repository/manifest"
+)
+
+// chunkIndex describes the chunks referenced by all files but one. A file
+// references its own chunks and the canonical copies of its near-duplicates,
+// so a chunk ID can be shared by several files.
+type chunkIndex struct {
+	fingerprints map[int64]uint64
+	refs         map[int64][]string
+}
+
+func buildChunkIndex(m *manifest.Manifest, except string) chunkIndex {
+	ix := chunkIndex{
+		fingerprints: make(map[int64]uint64),
+		refs:         make(map[int64][]string),
+	}
+
+	for path, entry := range m.Files {
+		if path == except {
+			continue
+		}
+		for i, id := range entry.ChunkIDs {
+			if i < len(entry.Fingerprints) {
+				ix.fingerprints[id] = entry.Fingerprints[i]
+			}
+			if !slices.Contains(ix.refs[id], path) {
+				ix.refs[id] = append(ix.refs[id], path)
+			}
+		}
+	}
+	for id := range ix.refs {
+		sort.Strings(ix.refs[id])
+	}
+
+	return ix
+}
+
+// nearest finds the closest near-duplicate; the scan is linear, which is
+// fine for the few thousand chunks of a document folder.
+func (ix chunkIndex) nearest(fingerprint uint64) (int64, bool) {
+	var (
+		best     int64
+		bestDist = helpers.NearDuplicateDistance + 1
+	)
+	for id, other := range ix.fingerprints {
+		if !helpers.IsNearDuplicate(fingerprint, other) {
+			continue
+		}
+		dist := helpers.HammingDistance(fingerprint, other)
+		if dist < bestDist || (dist == bestDist && id < best) {
+			best, bestDist = id, dist
+		}
+	}
+	return best, bestDist <= helpers.NearDuplicateDistance
+}
+
+// sources lists the files that reference id once path does (or no longer
+// does) so as well.
+func (ix chunkIndex) sources(id int64, path string, referenced bool) []string {
+	out := slices.Clone(ix.refs[id])
+	if referenced && !slices.Contains(out, path) {
+		out = append(out, path)
+		sort.Strings(out)
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+package ingest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"rag-test/internal/loader"
+)
+
+type documentFile struct {
+	Name    string
+	Path    string
+	ModTime time.Time
+
+	// page is set for crawled web pages, which are converted already and
+	// use their URL as Path.
+	page *loader.Document
+}
+
+func listDocumentFiles(documentsDir string) ([]documentFile, error) {
+	absDir, err := filepath.Abs(documentsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]documentFile, 0)
+	err = filepath.WalkDir(absDir, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		if isHiddenFile(entry.Name()) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		files = append(files, documentFile{
+			Name:    entry.Name(),
+			Path:    path,
+			ModTime: info.ModTime().UTC(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// isHiddenFile filters dotfiles and the "~$" lock files office suites leave
+// next to open documents.
+func isHiddenFile(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "~$")
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashText(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// documentTags splits the comma-joined "tags" front matter value.
+func documentTags(doc loader.Document) []string {
+	var tags []string
+	for _, tag := range strings.Split(doc.Metadata["tags"], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
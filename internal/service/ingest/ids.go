@@ -0,0 +1,48 @@
+package ingest
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+)
+
+// chunkID derives a stable primary key from the chunk's origin and content,
+// so re-ingesting an unchanged chunk overwrites the same record.
+func chunkID(dataSource string, ordinal int, text string) int64 {
+	contentHash := sha256.Sum256([]byte(text))
+
+	h := sha256.New()
+	h.Write([]byte(dataSource))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.Itoa(ordinal)))
+	h.Write([]byte{0})
+	h.Write(contentHash[:])
+
+	sum := h.Sum(nil)
+	return int64(binary.BigEndian.Uint64(sum[:8]) & 0x7fffffffffffffff)
+}
+
+// questionID derives the key of a generated question from its parent chunk.
+func questionID(parentID int64, ordinal int, text string) int64 {
+	return chunkID("question:"+strconv.FormatInt(parentID, 10), ordinal, text)
+}
+
+// sectionID derives the key of a parent section the same way as chunkID.
+func sectionID(dataSource string, ordinal int, text string) int64 {
+	return chunkID("section:"+dataSource, ordinal, text)
+}
+
+func staleIDs(previous, current []int64) []int64 {
+	keep := make(map[int64]struct{}, len(current))
+	for _, id := range current {
+		keep[id] = struct{}{}
+	}
+
+	stale := make([]int64, 0)
+	for _, id := range previous {
+		if _, ok := keep[id]; !ok {
+			stale = append(stale, id)
+		}
+	}
+	return stale
+}
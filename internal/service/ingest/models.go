@@ -0,0 +1,52 @@
+package ingest
+
+import (
+	"time"
+
+	"rag-test/internal/helpers"
+	"rag-test/internal/loader"
+	"rag-test/internal/repository/checkpoint"
+	"rag-test/internal/repository/manifest"
+)
+
+type Report struct {
+	Added     []string
+	Changed   []string
+	Removed   []string
+	Unchanged []string
+	Skipped   []string
+	Failed    []FileError
+	// Duplicates lists chunks that were linked to an existing near-duplicate
+	// instead of being stored again.
+	Duplicates []Duplicate
+	Chunks     int
+	// Resumed counts embeddings taken from checkpoints instead of the API.
+	Resumed  int
+	Batches  int
+	Duration time.Duration
+}
+
+type Duplicate struct {
+	Path   string
+	Of     string
+	Chunks int
+}
+
+type FileError struct {
+	Path  string
+	Stage string
+	Err   error
+}
+
+type fileJob struct {
+	file     documentFile
+	hash     string
+	previous *manifest.FileEntry
+	splitter string
+
+	doc        loader.Document
+	chunks     []helpers.TextChunk
+	contexts   []string
+	embeddings [][]float32
+	checkpoint *checkpoint.Checkpoint
+}
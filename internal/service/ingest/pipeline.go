@@ -0,0 +1,325 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"rag-test/internal/helpers"
+	"rag-test/internal/repository/manifest"
+	milvusrepo "rag-test/internal/repository/milvus"
+)
+
+type stageFunc func(ctx context.Context, job *fileJob, st *runState) error
+
+// runPipeline pushes jobs through convert → split → context → questions →
+// embed → write; the context and questions stages do nothing unless enabled.
+// Every stage
+// has its own worker pool; a failing file is recorded and dropped without
+// stopping the others.
+func (s *Service) runPipeline(ctx context.Context, jobs []*fileJob, st *runState) {
+	source := make(chan *fileJob)
+	go func() {
+		defer close(source)
+		for _, job := range jobs {
+			select {
+			case source <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	loaded := runStage(ctx, "convert", s.cfg.LoadWorkers, source, st, s.loadDocument)
+	split := runStage(ctx, "split", s.cfg.SplitWorkers, loaded, st, s.splitDocument)
+	situated := runStage(ctx, "context", s.cfg.LLMWorkers, split, st, s.situateChunks)
+	asked := runStage(ctx, "questions", s.cfg.LLMWorkers, situated, st, s.generateQuestions)
+	embedded := runStage(ctx, "embed", s.cfg.EmbedWorkers, asked, st, s.embedChunks)
+	written := runStage(ctx, "write", s.cfg.WriteWorkers, embedded, st, s.writeChunks)
+
+	for range written {
+	}
+}
+
+func runStage(ctx context.Context, stage string, workers int, in <-chan *fileJob, st *runState, fn stageFunc) <-chan *fileJob {
+	out := make(chan *fileJob)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range in {
+				if ctx.Err() != nil {
+					return
+				}
+				if err := fn(ctx, job, st); err != nil {
+					if ctx.Err() == nil {
+						slog.Error(
+							"failed to process file",
+							slog.String("stage", stage),
+							slog.String("path", job.file.Path),
+							slog.String("error", err.Error()),
+						)
+						st.fail(job, stage, err)
+					}
+					continue
+				}
+
+				select {
+				case out <- job:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+func (s *Service) loadDocument(ctx context.Context, job *fileJob, _ *runState) error {
+	slog.Info("❗❗processing file❗❗", slog.String("path", job.file.Path), slog.String("name", job.file.Name))
+
+	if job.file.page != nil {
+		job.doc = *job.file.page
+		return nil
+	}
+
+	doc, err := s.loaders.Load(ctx, job.file.Path)
+	if err != nil {
+		return err
+	}
+	job.doc = doc
+	return nil
+}
+
+func (s *Service) embedChunks(ctx context.Context, job *fileJob, st *runState) error {
+	inputs := s.embeddingInputs(job)
+
+	cp, err := s.jobCheckpoint(job)
+	if err != nil {
+		return err
+	}
+	if done := len(cp.Embeddings); done > 0 {
+		slog.Info("resuming embeddings from checkpoint", slog.String("path", job.file.Path), slog.Int("done", done), slog.Int("total", len(inputs)))
+		st.mu.Lock()
+		st.report.Resumed += done
+		st.mu.Unlock()
+	}
+
+	for _, batch := range batchByTokens(inputs[len(cp.Embeddings):], s.cfg.BatchTokens, s.cfg.BatchSize) {
+		embs, err := s.embedBatch(ctx, batch, st)
+		if err != nil {
+			return err
+		}
+		cp.Embeddings = append(cp.Embeddings, embs...)
+		if err := s.saveCheckpoint(cp); err != nil {
+			return err
+		}
+	}
+
+	job.embeddings = cp.Embeddings
+	return s.embedQuestions(ctx, job, st)
+}
+
+// embedBatch embeds one batch made by batchByTokens in a single request
+// under the rate limit.
+func (s *Service) embedBatch(ctx context.Context, batch []string, st *runState) ([][]float32, error) {
+	if err := s.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	embs, err := s.embeddingsRepo.CreateEmbeddings(ctx, batch...)
+	if err != nil {
+		return nil, err
+	}
+	if len(embs) != len(batch) {
+		return nil, fmt.Errorf("embeddings count mismatch: got %d, want %d", len(embs), len(batch))
+	}
+
+	st.mu.Lock()
+	st.report.Batches++
+	st.mu.Unlock()
+
+	return embs, nil
+}
+
+func (s *Service) writeChunks(ctx context.Context, job *fileJob, st *runState) error {
+	st.writeMu.Lock()
+	defer st.writeMu.Unlock()
+
+	st.mu.Lock()
+	index := buildChunkIndex(st.manifest, job.file.Path)
+	st.mu.Unlock()
+
+	sectionOf, sectionIDs, err := s.writeSections(job)
+	if err != nil {
+		return err
+	}
+
+	items := make([]milvusrepo.VectorItem, 0, len(job.chunks))
+	ids := make([]int64, 0, len(job.chunks))
+	fingerprints := make([]uint64, 0, len(job.chunks))
+	own := make([]bool, len(job.chunks))
+	duplicates := make(map[string]int)
+	for i, emb := range job.embeddings {
+		chunk := job.chunks[i]
+		fingerprint := helpers.SimHash(chunk.Text)
+		fingerprints = append(fingerprints, fingerprint)
+
+		if canonical, ok := index.nearest(fingerprint); ok {
+			ids = append(ids, canonical)
+			duplicates[index.refs[canonical][0]]++
+			continue
+		}
+
+		id := chunkID(job.file.Path, i, chunk.Text)
+		if owners := index.refs[id]; len(owners) > 0 && index.fingerprints[id] != fingerprint {
+			return fmt.Errorf("chunk id %d of %s collides with a chunk of %s", id, job.file.Path, owners[0])
+		}
+
+		var sources []string
+		if len(index.refs[id]) > 0 {
+			sources = index.sources(id, job.file.Path, true)
+		}
+		var section int64
+		if i < len(sectionOf) {
+			section = sectionOf[i]
+		}
+
+		items = append(items, milvusrepo.VectorItem{
+			ID:         id,
+			Embedding:  emb,
+			Payload:    s.payloadText(job, i),
+			DataSource: job.file.Path,
+			Metadata: milvusrepo.ChunkMetadata{
+				Title:       job.doc.Title,
+				HeadingPath: chunk.HeadingPath,
+				Ordinal:     chunk.Ordinal,
+				StartOffset: chunk.StartOffset,
+				EndOffset:   chunk.EndOffset,
+				Sources:     sources,
+				Context:     jobContext(job, i),
+				SectionID:   section,
+				DocType:     job.doc.Metadata["loader"],
+				Tags:        documentTags(job.doc),
+				UpdatedAt:   job.file.ModTime.Unix(),
+			},
+		})
+		ids = append(ids, id)
+		own[i] = true
+	}
+
+	questions := s.questionItems(job, ids, own)
+	questionIDs := make([]int64, 0, len(questions))
+	for _, question := range questions {
+		questionIDs = append(questionIDs, question.ID)
+	}
+
+	job.checkpoint.PendingIDs = ids
+	job.checkpoint.PendingQuestionIDs = questionIDs
+	if err := s.saveCheckpoint(job.checkpoint); err != nil {
+		return err
+	}
+	if err := s.vectorRepo.Upsert(ctx, s.cfg.Collection, items); err != nil {
+		return err
+	}
+	if err := s.vectorRepo.Upsert(ctx, milvusrepo.QuestionsCollection(s.cfg.Collection), questions); err != nil {
+		return err
+	}
+
+	var stale, staleQuestions, staleSections []int64
+	if job.previous != nil {
+		stale = staleIDs(job.previous.ChunkIDs, ids)
+		staleQuestions = staleIDs(job.previous.QuestionIDs, questionIDs)
+		staleSections = staleIDs(job.previous.SectionIDs, sectionIDs)
+	}
+	if err := s.remover.releaseChunks(ctx, job.file.Path, index, ids, stale); err != nil {
+		return err
+	}
+	if err := s.remover.deleteQuestions(ctx, staleQuestions); err != nil {
+		return err
+	}
+	if err := s.remover.deleteSections(ctx, staleSections); err != nil {
+		return err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.manifest.Files[job.file.Path] = manifest.FileEntry{
+		Path:           job.file.Path,
+		Hash:           job.hash,
+		ModTime:        job.file.ModTime,
+		ChunkIDs:       ids,
+		Fingerprints:   fingerprints,
+		QuestionIDs:    questionIDs,
+		Questions:      s.questionsPerChunk(),
+		Context:        s.contextMode(),
+		SectionIDs:     sectionIDs,
+		SectionTokens:  s.sectionMaxTokens(),
+		EmbeddingModel: s.embeddingsRepo.Model(),
+		Dim:            s.embeddingsRepo.Dim(),
+		Pipeline:       pipelineVersion,
+		Splitter:       job.splitter,
+		IngestedAt:     time.Now().UTC(),
+	}
+	st.unsaved = append(st.unsaved, job.file.Path)
+	if len(st.unsaved) >= manifestSaveBatch {
+		if err := s.saveManifest(st); err != nil {
+			return err
+		}
+	}
+
+	if job.previous != nil {
+		st.report.Changed = append(st.report.Changed, job.file.Path)
+	} else {
+		st.report.Added = append(st.report.Added, job.file.Path)
+	}
+	st.report.Chunks += len(items)
+	for source, count := range duplicates {
+		st.report.Duplicates = append(st.report.Duplicates, Duplicate{Path: job.file.Path, Of: source, Chunks: count})
+	}
+
+	slog.Info(
+		"✅✅processed file✅✅",
+		slog.String("path", job.file.Path),
+		slog.Int("chunks", len(items)),
+		slog.Int("duplicates", len(ids)-len(items)),
+		slog.Int("questions", len(questionIDs)),
+	)
+
+	return nil
+}
+
+// batchByTokens groups texts so that each embeddings request stays within the
+// token and input-count limits; an oversized text gets a batch of its own.
+func batchByTokens(texts []string, maxTokens, maxItems int) [][]string {
+	batches := make([][]string, 0)
+	current := make([]string, 0)
+	tokens := 0
+
+	for _, text := range texts {
+		n := helpers.EstimateTokens(text)
+		if len(current) > 0 && (tokens+n > maxTokens || len(current) >= maxItems) {
+			batches = append(batches, current)
+			current = make([]string, 0)
+			tokens = 0
+		}
+		current = append(current, text)
+		tokens += n
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+
+	return batches
+}
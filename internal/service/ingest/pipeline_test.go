@@ -0,0 +1,47 @@
+package ingest
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"rag-test/internal/helpers"
+)
+
+func TestBatchByTokens(t *testing.T) {
+	word := "слово"
+	text := func(words int) string { return strings.TrimSpace(strings.Repeat(word+" ", words)) }
+	tokens := helpers.EstimateTokens(text(10))
+
+	tests := []struct {
+		name      string
+		texts     []string
+		maxTokens int
+		maxItems  int
+		want      []int
+	}{
+		{name: "empty", want: []int{}},
+		{name: "one batch", texts: []string{text(10), text(10)}, maxTokens: 10 * tokens, maxItems: 10, want: []int{2}},
+		{name: "item limit", texts: []string{text(10), text(10), text(10)}, maxTokens: 10 * tokens, maxItems: 2, want: []int{2, 1}},
+		{name: "token limit", texts: []string{text(10), text(10), text(10)}, maxTokens: 2 * tokens, maxItems: 10, want: []int{2, 1}},
+		{name: "oversized text alone", texts: []string{text(10), text(100), text(10)}, maxTokens: 2 * tokens, maxItems: 10, want: []int{1, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batches := batchByTokens(tt.texts, tt.maxTokens, tt.maxItems)
+			got := make([]int, 0, len(batches))
+			total := 0
+			for _, batch := range batches {
+				got = append(got, len(batch))
+				total += len(batch)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("batch sizes = %v, want %v", got, tt.want)
+			}
+			if total != len(tt.texts) {
+				t.Errorf("batches hold %d texts, want %d", total, len(tt.texts))
+			}
+		})
+	}
+}
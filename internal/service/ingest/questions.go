@@ -0,0 +1,172 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"rag-test/internal/helpers"
+	milvusrepo "rag-test/internal/repository/milvus"
+	openairepo "rag-test/internal/repository/openai"
+)
+
+const (
+	questionsSystemPrompt = `Ты — модуль подготовки поискового индекса.
+По фрагменту документа сформулируй %d коротких вопроса, которые мог бы задать клиент в чате и на которые этот фрагмент отвечает.
+Пиши разговорным языком, как пишут покупатели. Не добавляй фактов, которых нет во фрагменте.
+Ответ должен быть ТОЛЬКО валидным JSON без комментариев, без пояснений и без markdown.
+Строго следуй схеме:
+{
+  "questions": string[]
+}`
+
+	questionsMaxTokens = 300
+)
+
+type questionsResult struct {
+	Questions []string `json:"questions"`
+}
+
+func (s *Service) questionsEnabled() bool {
+	return s.openaiRepo != nil && s.cfg.QuestionsPerChunk > 0
+}
+
+// questionsPerChunk is recorded in the manifest, so enabling or resizing the
+// stage re-ingests every file.
+func (s *Service) questionsPerChunk() int {
+	if !s.questionsEnabled() {
+		return 0
+	}
+	return s.cfg.QuestionsPerChunk
+}
+
+// generateQuestions asks the chat model for likely user questions per chunk.
+// Questions saved in the checkpoint are reused on resume.
+func (s *Service) generateQuestions(ctx context.Context, job *fileJob, _ *runState) error {
+	if !s.questionsEnabled() {
+		return nil
+	}
+
+	cp, err := s.jobCheckpoint(job)
+	if err != nil {
+		return err
+	}
+
+	for len(cp.Questions) < len(job.chunks) {
+		questions, err := s.askQuestions(ctx, job.chunks[len(cp.Questions)].Text)
+		if err != nil {
+			return err
+		}
+		cp.Questions = append(cp.Questions, questions)
+		if err := s.saveCheckpoint(cp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) askQuestions(ctx context.Context, chunk string) ([]string, error) {
+	if err := s.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.openaiRepo.CreateChatCompletion(ctx, openairepo.ChatCompletionRequest{
+		Messages: []openairepo.Message{
+			{Role: openairepo.RoleSystem, Content: fmt.Sprintf(questionsSystemPrompt, s.cfg.QuestionsPerChunk)},
+			{Role: openairepo.RoleUser, Content: "Фрагмент:\n" + chunk},
+		},
+		Temperature:    0,
+		MaxTokens:      questionsMaxTokens,
+		ResponseFormat: &openairepo.ResponseFormat{Type: openairepo.ResponseFormatTypeJSONObject},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed questionsResult
+	if err := helpers.DecodeJSON(resp.Content, &parsed); err != nil {
+		return nil, fmt.Errorf("parse generated questions: %w", err)
+	}
+
+	questions := make([]string, 0, s.cfg.QuestionsPerChunk)
+	for _, question := range parsed.Questions {
+		question = strings.TrimSpace(question)
+		if question != "" && len(questions) < s.cfg.QuestionsPerChunk {
+			questions = append(questions, question)
+		}
+	}
+	return questions, nil
+}
+
+// embedQuestions embeds the questions of all chunks in one flat list,
+// checkpointing after every batch like the chunks themselves.
+func (s *Service) embedQuestions(ctx context.Context, job *fileJob, st *runState) error {
+	if !s.questionsEnabled() {
+		return nil
+	}
+
+	cp := job.checkpoint
+	inputs := make([]string, 0)
+	for _, questions := range cp.Questions {
+		for _, question := range questions {
+			inputs = append(inputs, helpers.NormalizeText(question))
+		}
+	}
+	if len(cp.QuestionEmbeddings) > len(inputs) {
+		cp.QuestionEmbeddings = nil
+	}
+
+	for _, batch := range batchByTokens(inputs[len(cp.QuestionEmbeddings):], s.cfg.BatchTokens, s.cfg.BatchSize) {
+		embs, err := s.embedBatch(ctx, batch, st)
+		if err != nil {
+			return err
+		}
+		cp.QuestionEmbeddings = append(cp.QuestionEmbeddings, embs...)
+		if err := s.saveCheckpoint(cp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// questionItems turns the generated questions of the chunks stored as own
+// records into vectors pointing back to their parent chunk. Questions of
+// near-duplicates are dropped, the canonical chunk has its own.
+func (s *Service) questionItems(job *fileJob, ids []int64, own []bool) []milvusrepo.VectorItem {
+	if !s.questionsEnabled() {
+		return nil
+	}
+
+	cp := job.checkpoint
+	items := make([]milvusrepo.VectorItem, 0)
+	next := 0
+	for i, questions := range cp.Questions {
+		chunk := job.chunks[i]
+		for j, question := range questions {
+			emb := cp.QuestionEmbeddings[next]
+			next++
+			if !own[i] {
+				continue
+			}
+
+			items = append(items, milvusrepo.VectorItem{
+				ID:         questionID(ids[i], j, question),
+				Embedding:  emb,
+				Payload:    question,
+				DataSource: job.file.Path,
+				Metadata: milvusrepo.ChunkMetadata{
+					Title:       job.doc.Title,
+					HeadingPath: chunk.HeadingPath,
+					Ordinal:     chunk.Ordinal,
+					ParentID:    ids[i],
+					DocType:     job.doc.Metadata["loader"],
+					Tags:        documentTags(job.doc),
+					UpdatedAt:   job.file.ModTime.Unix(),
+				},
+			})
+		}
+	}
+	return items
+}
@@ -0,0 +1,191 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"rag-test/internal/repository/manifest"
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/repository/textstore"
+)
+
+// ErrUnknownSource is returned for a source the manifest has no entry for.
+var ErrUnknownSource = errors.New("source is not in the manifest")
+
+// Remover deletes what ingestion stored for a file: its chunks, questions
+// and parent sections. It needs none of the loaders or model clients, so
+// commands that only remove sources can use it without a Service.
+type Remover struct {
+	vectorRepo   milvusrepo.VectorRepository
+	manifestRepo *manifest.Repository
+	sectionStore *textstore.Store
+	collection   string
+}
+
+// NewRemover returns a Remover for collection. sectionStore may be nil when
+// parent sections are not stored.
+func NewRemover(vectorRepo milvusrepo.VectorRepository, manifestRepo *manifest.Repository, sectionStore *textstore.Store, collection string) *Remover {
+	return &Remover{
+		vectorRepo:   vectorRepo,
+		manifestRepo: manifestRepo,
+		sectionStore: sectionStore,
+		collection:   collection,
+	}
+}
+
+// RemoveSource takes one document out of the index as if it had been
+// deleted. A file still present in the sources comes back with the next
+// run.
+func (r *Remover) RemoveSource(ctx context.Context, path string) error {
+	m, err := r.manifestRepo.Load(r.collection)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.Files[path]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownSource, path)
+	}
+
+	if err := r.removeFile(ctx, m, path); err != nil {
+		return err
+	}
+	return r.manifestRepo.Save(m)
+}
+
+// removeFile deletes the chunks, questions and sections of a file and drops
+// it from the manifest. Chunks shared with other files are kept for them.
+func (r *Remover) removeFile(ctx context.Context, m *manifest.Manifest, path string) error {
+	entry := m.Files[path]
+	if err := r.releaseChunks(ctx, path, buildChunkIndex(m, path), nil, entry.ChunkIDs); err != nil {
+		return err
+	}
+	if err := r.deleteQuestions(ctx, entry.QuestionIDs); err != nil {
+		return err
+	}
+	if err := r.deleteSections(ctx, entry.SectionIDs); err != nil {
+		return err
+	}
+	delete(m.Files, path)
+	return nil
+}
+
+func (r *Remover) deleteChunks(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := r.vectorRepo.Delete(ctx, r.collection, ids); err != nil {
+		slog.Error("failed to delete stale chunks", slog.Int("count", len(ids)), slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// releaseChunks applies a new set of chunk references of path: chunks no
+// file references any more are deleted, and shared chunks get their source
+// list (and, if path owned them, data_source) rewritten.
+func (r *Remover) releaseChunks(ctx context.Context, path string, ix chunkIndex, current, stale []int64) error {
+	referenced := make(map[int64]bool, len(current))
+	for _, id := range current {
+		referenced[id] = true
+	}
+
+	unused := make([]int64, 0)
+	shared := make(map[int64][]string)
+	for _, id := range stale {
+		if referenced[id] {
+			continue
+		}
+		if len(ix.refs[id]) == 0 {
+			unused = append(unused, id)
+			continue
+		}
+		shared[id] = ix.sources(id, path, false)
+	}
+	for id := range referenced {
+		if len(ix.refs[id]) > 0 {
+			shared[id] = ix.sources(id, path, true)
+		}
+	}
+
+	if err := r.updateSources(ctx, shared); err != nil {
+		return err
+	}
+	return r.deleteChunks(ctx, slices.Compact(slices.Sorted(slices.Values(unused))))
+}
+
+func (r *Remover) updateSources(ctx context.Context, sources map[int64][]string) error {
+	if len(sources) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(sources))
+	for id := range sources {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	items, err := r.vectorRepo.Get(ctx, r.collection, ids)
+	if err != nil {
+		return err
+	}
+
+	changed := items[:0]
+	for _, item := range items {
+		want := sources[item.ID]
+		if len(want) == 0 {
+			continue
+		}
+
+		dataSource := item.DataSource
+		if !slices.Contains(want, dataSource) {
+			dataSource = want[0]
+		}
+		var merged []string
+		if len(want) > 1 {
+			merged = want
+		}
+		if dataSource == item.DataSource && slices.Equal(merged, item.Metadata.Sources) {
+			continue
+		}
+
+		item.DataSource = dataSource
+		item.Metadata.Sources = merged
+		changed = append(changed, item)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	slog.Debug("updating sources of shared chunks", slog.Int("count", len(changed)))
+	return r.vectorRepo.Upsert(ctx, r.collection, changed)
+}
+
+func (r *Remover) deleteQuestions(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := r.vectorRepo.Delete(ctx, milvusrepo.QuestionsCollection(r.collection), ids); err != nil {
+		slog.Error("failed to delete stale questions", slog.Int("count", len(ids)), slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+func (r *Remover) deleteSections(_ context.Context, ids []int64) error {
+	if len(ids) == 0 || r.sectionStore == nil {
+		return nil
+	}
+
+	if err := r.sectionStore.Delete(textstore.SectionsNamespace(r.collection), ids); err != nil {
+		slog.Error("failed to delete stale sections", slog.Int("count", len(ids)), slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"rag-test/internal/repository/manifest"
+	"rag-test/internal/repository/memory"
+	milvusrepo "rag-test/internal/repository/milvus"
+)
+
+func TestRemoveSource(t *testing.T) {
+	ctx := context.Background()
+
+	vectors, err := memory.NewRepository(memory.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vectors.EnsureCollection(ctx, testCollection, milvusrepo.CollectionConfig{Dim: 2}); err != nil {
+		t.Fatal(err)
+	}
+	// Chunk 2 is shared: a.md owns it and b.md references it as a duplicate.
+	shared := milvusrepo.VectorItem{ID: 2, Embedding: []float32{0, 1}, Payload: "shared", DataSource: "a.md"}
+	shared.Metadata.Sources = []string{"a.md", "b.md"}
+	err = vectors.Upsert(ctx, testCollection, []milvusrepo.VectorItem{
+		{ID: 1, Embedding: []float32{1, 0}, Payload: "own", DataSource: "a.md"},
+		shared,
+		{ID: 3, Embedding: []float32{1, 1}, Payload: "other", DataSource: "b.md"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifests, err := manifest.NewRepository(filepath.Join(t.TempDir(), "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := manifest.New(testCollection)
+	m.Files["a.md"] = manifest.FileEntry{Path: "a.md", ChunkIDs: []int64{1, 2}}
+	m.Files["b.md"] = manifest.FileEntry{Path: "b.md", ChunkIDs: []int64{2, 3}}
+	if err := manifests.Save(m); err != nil {
+		t.Fatal(err)
+	}
+
+	remover := NewRemover(vectors, manifests, nil, testCollection)
+	if err := remover.RemoveSource(ctx, "missing.md"); !errors.Is(err, ErrUnknownSource) {
+		t.Fatalf("RemoveSource of an unknown file = %v, want ErrUnknownSource", err)
+	}
+	if err := remover.RemoveSource(ctx, "a.md"); err != nil {
+		t.Fatalf("RemoveSource: %v", err)
+	}
+
+	items, err := vectors.Get(ctx, testCollection, []int64{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+		if item.ID == 2 && (item.DataSource != "b.md" || item.Metadata.Sources != nil) {
+			t.Errorf("shared chunk has data_source %q and sources %v, want b.md alone", item.DataSource, item.Metadata.Sources)
+		}
+	}
+	if !slices.Equal(ids, []int64{2, 3}) {
+		t.Errorf("ids = %v, want [2 3]", ids)
+	}
+
+	saved, err := manifests.Load(testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := saved.Files["a.md"]; ok || len(saved.Files) != 1 {
+		t.Errorf("manifest files = %v, want only b.md", saved.Files)
+	}
+}
@@ -0,0 +1,45 @@
+package ingest
+
+import (
+	"rag-test/internal/helpers"
+	"rag-test/internal/repository/textstore"
+)
+
+func (s *Service) sectionsEnabled() bool {
+	return s.sectionStore != nil && s.cfg.SectionMaxTokens > 0
+}
+
+// sectionMaxTokens is recorded in the manifest, so enabling or resizing
+// parent sections re-ingests every file.
+func (s *Service) sectionMaxTokens() int {
+	if !s.sectionsEnabled() {
+		return 0
+	}
+	return s.cfg.SectionMaxTokens
+}
+
+// writeSections stores the parent sections of the file's chunks and returns
+// the section ID of every chunk together with all section IDs.
+func (s *Service) writeSections(job *fileJob) ([]int64, []int64, error) {
+	if !s.sectionsEnabled() {
+		return nil, nil, nil
+	}
+
+	namespace := textstore.SectionsNamespace(s.cfg.Collection)
+	sections := helpers.GroupSections(job.doc.Markdown, job.chunks, s.cfg.SectionMaxTokens)
+
+	byChunk := make([]int64, len(job.chunks))
+	ids := make([]int64, 0, len(sections))
+	for ordinal, section := range sections {
+		id := sectionID(job.file.Path, ordinal, section.Text)
+		if err := s.sectionStore.Put(namespace, id, section.Text); err != nil {
+			return nil, nil, err
+		}
+		for _, child := range section.Children {
+			byChunk[child] = id
+		}
+		ids = append(ids, id)
+	}
+
+	return byChunk, ids, nil
+}
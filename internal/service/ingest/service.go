@@ -0,0 +1,280 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"rag-test/internal/crawler"
+	"rag-test/internal/loader"
+	"rag-test/internal/repository/checkpoint"
+	"rag-test/internal/repository/contexts"
+	"rag-test/internal/repository/embeddings"
+	"rag-test/internal/repository/manifest"
+	milvusrepo "rag-test/internal/repository/milvus"
+	openairepo "rag-test/internal/repository/openai"
+	"rag-test/internal/repository/textstore"
+)
+
+// Dependencies are the repositories and clients a Service works with.
+// Loaders, Embeddings, Vectors and Manifest are required; a nil Crawler,
+// Checkpoints, OpenAI, Contexts or Sections turns the stage using it off.
+type Dependencies struct {
+	Loaders     *loader.Registry
+	Crawler     *crawler.Crawler
+	Embeddings  *embeddings.Repository
+	Vectors     milvusrepo.VectorRepository
+	Manifest    *manifest.Repository
+	Checkpoints *checkpoint.Repository
+	OpenAI      *openairepo.Repository
+	Contexts    *contexts.Repository
+	Sections    *textstore.Store
+}
+
+type Service struct {
+	loaders        *loader.Registry
+	webCrawler     *crawler.Crawler
+	embeddingsRepo *embeddings.Repository
+	vectorRepo     milvusrepo.VectorRepository
+	manifestRepo   *manifest.Repository
+	checkpoints    *checkpoint.Repository
+	openaiRepo     *openairepo.Repository
+	contextsRepo   *contexts.Repository
+	sectionStore   *textstore.Store
+	remover        *Remover
+	limiter        *rateLimiter
+	cfg            Config
+}
+
+func NewService(deps Dependencies, cfg Config) (*Service, error) {
+	switch {
+	case deps.Loaders == nil:
+		return nil, errors.New("ingest: loaders are required")
+	case deps.Embeddings == nil:
+		return nil, errors.New("ingest: embeddings repository is required")
+	case deps.Vectors == nil:
+		return nil, errors.New("ingest: vector repository is required")
+	case deps.Manifest == nil:
+		return nil, errors.New("ingest: manifest repository is required")
+	}
+	cfg = cfg.withDefaults()
+
+	return &Service{
+		loaders:        deps.Loaders,
+		webCrawler:     deps.Crawler,
+		embeddingsRepo: deps.Embeddings,
+		vectorRepo:     deps.Vectors,
+		manifestRepo:   deps.Manifest,
+		checkpoints:    deps.Checkpoints,
+		openaiRepo:     deps.OpenAI,
+		contextsRepo:   deps.Contexts,
+		sectionStore:   deps.Sections,
+		remover:        NewRemover(deps.Vectors, deps.Manifest, deps.Sections, cfg.Collection),
+		limiter:        newRateLimiter(cfg.RequestsPerSecond),
+		cfg:            cfg,
+	}, nil
+}
+
+// manifestSaveBatch is how many written files are recorded in the manifest
+// before it is saved; rewriting it after every file is quadratic in the
+// number of files.
+const manifestSaveBatch = 32
+
+// runState is shared by the pipeline workers of a single Run. writeMu
+// serializes writes, which read and update chunks shared between files.
+// unsaved holds the files written since the manifest was last saved.
+type runState struct {
+	mu       sync.Mutex
+	writeMu  sync.Mutex
+	manifest *manifest.Manifest
+	unsaved  []string
+	report   Report
+}
+
+func (st *runState) fail(job *fileJob, stage string, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.report.Failed = append(st.report.Failed, FileError{Path: job.file.Path, Stage: stage, Err: err})
+}
+
+func (s *Service) Run(ctx context.Context) (Report, error) {
+	started := time.Now()
+
+	if err := s.cfg.validateSplitters(); err != nil {
+		return Report{}, err
+	}
+
+	files, err := listDocumentFiles(s.cfg.DocumentsDir)
+	if err != nil {
+		slog.Error("failed to list documents", slog.String("error", err.Error()))
+		return Report{}, err
+	}
+
+	pages, unreachable, err := s.crawlPages(ctx)
+	if err != nil {
+		slog.Error("failed to crawl web pages", slog.String("error", err.Error()))
+		return Report{}, err
+	}
+	files = append(files, pages...)
+
+	m, err := s.manifestRepo.Load(s.cfg.Collection)
+	if err != nil {
+		slog.Error("failed to load manifest", slog.String("error", err.Error()))
+		return Report{}, err
+	}
+
+	if err := s.recoverCheckpoints(ctx, m); err != nil {
+		slog.Error("failed to recover checkpoints", slog.String("error", err.Error()))
+		return Report{}, err
+	}
+
+	st := &runState{manifest: m}
+	jobs, seen := s.plan(files, st)
+	// Pages that could not be fetched this time keep their chunks.
+	for _, failed := range unreachable {
+		seen[failed.URL] = struct{}{}
+		st.report.Failed = append(st.report.Failed, FileError{Path: failed.URL, Stage: "fetch", Err: failed.Err})
+	}
+
+	s.runPipeline(ctx, jobs, st)
+	if err := ctx.Err(); err != nil {
+		// Keep the files finished before the cancellation.
+		if err := s.saveManifest(st); err != nil {
+			slog.Error("failed to save manifest", slog.String("error", err.Error()))
+		}
+		st.report.Duration = time.Since(started)
+		return st.report, err
+	}
+
+	removed := make([]string, 0)
+	for path := range m.Files {
+		if _, ok := seen[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(removed)
+
+	for _, path := range removed {
+		if err := s.remover.removeFile(ctx, m, path); err != nil {
+			st.report.Failed = append(st.report.Failed, FileError{Path: path, Stage: "delete", Err: err})
+			continue
+		}
+		st.report.Removed = append(st.report.Removed, path)
+	}
+
+	if err := s.saveManifest(st); err != nil {
+		slog.Error("failed to save manifest", slog.String("error", err.Error()))
+		return st.report, err
+	}
+
+	sort.Strings(st.report.Added)
+	sort.Strings(st.report.Changed)
+	sort.Slice(st.report.Duplicates, func(i, j int) bool {
+		a, b := st.report.Duplicates[i], st.report.Duplicates[j]
+		return a.Path < b.Path || (a.Path == b.Path && a.Of < b.Of)
+	})
+	sort.Slice(st.report.Failed, func(i, j int) bool {
+		return st.report.Failed[i].Path < st.report.Failed[j].Path
+	})
+	st.report.Duration = time.Since(started)
+
+	return st.report, nil
+}
+
+// plan decides which files have to be (re)ingested. Files that cannot be
+// hashed are reported as failed but still count as seen, so their chunks are kept.
+// saveManifest saves the manifest, then drops the checkpoints of the files
+// it now records; until then they let recoverCheckpoints clean up the chunks
+// of those files after a crash. The caller holds st.mu or runs alone.
+func (s *Service) saveManifest(st *runState) error {
+	if err := s.manifestRepo.Save(st.manifest); err != nil {
+		return err
+	}
+	for _, path := range st.unsaved {
+		if err := s.dropCheckpoint(path); err != nil {
+			slog.Warn("failed to drop checkpoint", slog.String("path", path), slog.String("error", err.Error()))
+		}
+	}
+	st.unsaved = st.unsaved[:0]
+	return nil
+}
+
+func (s *Service) plan(files []documentFile, st *runState) ([]*fileJob, map[string]struct{}) {
+	jobs := make([]*fileJob, 0)
+	seen := make(map[string]struct{}, len(files))
+
+	for _, file := range files {
+		loaderName, hash, ok := s.identify(file, st, seen)
+		if !ok {
+			continue
+		}
+
+		job := &fileJob{file: file, hash: hash, splitter: s.cfg.configuredSplitter(file.Path, loaderName)}
+		if entry, exists := st.manifest.Files[file.Path]; exists {
+			if s.isUpToDate(entry, hash, job.splitter) {
+				if !entry.ModTime.Equal(file.ModTime) {
+					entry.ModTime = file.ModTime
+					st.manifest.Files[file.Path] = entry
+				}
+				st.report.Unchanged = append(st.report.Unchanged, file.Path)
+				continue
+			}
+			job.previous = &entry
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, seen
+}
+
+// identify resolves the loader name and content hash of a file and marks it
+// as seen unless it is unsupported.
+func (s *Service) identify(file documentFile, st *runState, seen map[string]struct{}) (string, string, bool) {
+	if file.page != nil {
+		seen[file.Path] = struct{}{}
+		return webLoaderName, hashText(file.page.Markdown), true
+	}
+
+	ld, err := s.loaders.Resolve(file.Path)
+	if err != nil {
+		if !errors.Is(err, loader.ErrUnsupported) {
+			slog.Error("failed to resolve document loader", slog.String("path", file.Path), slog.String("error", err.Error()))
+			seen[file.Path] = struct{}{}
+			st.fail(&fileJob{file: file}, "resolve", err)
+			return "", "", false
+		}
+		slog.Warn("skipping unsupported document", slog.String("path", file.Path))
+		st.report.Skipped = append(st.report.Skipped, file.Path)
+		return "", "", false
+	}
+	seen[file.Path] = struct{}{}
+
+	hash, err := hashFile(file.Path)
+	if err != nil {
+		slog.Error("failed to hash file", slog.String("path", file.Path), slog.String("error", err.Error()))
+		st.fail(&fileJob{file: file}, "hash", err)
+		return "", "", false
+	}
+
+	return ld.Name(), hash, true
+}
+
+func (s *Service) isUpToDate(entry manifest.FileEntry, hash, splitter string) bool {
+	previous := entry.Splitter
+	if previous == "" {
+		previous = SplitterFixed
+	}
+
+	return entry.Hash == hash &&
+		previous == splitter &&
+		entry.Questions == s.questionsPerChunk() &&
+		entry.Context == s.contextMode() &&
+		entry.SectionTokens == s.sectionMaxTokens() &&
+		entry.Pipeline == pipelineVersion &&
+		entry.EmbeddingModel == s.embeddingsRepo.Model() &&
+		entry.Dim == s.embeddingsRepo.Dim()
+}
@@ -0,0 +1,101 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"rag-test/internal/helpers"
+)
+
+const (
+	SplitterFixed    = "fixed"
+	SplitterSemantic = "semantic"
+
+	// splitterMetadataKey lets a single document pick its splitter, e.g. via
+	// "splitter: semantic" in Markdown front matter.
+	splitterMetadataKey = "splitter"
+)
+
+func validSplitter(name string) bool {
+	return name == SplitterFixed || name == SplitterSemantic
+}
+
+func (c Config) validateSplitters() error {
+	for key, name := range c.LoaderSplitters {
+		if !validSplitter(name) {
+			return fmt.Errorf("unknown splitter %q for loader %s", name, key)
+		}
+	}
+	for pattern, name := range c.FileSplitters {
+		if !validSplitter(name) {
+			return fmt.Errorf("unknown splitter %q for files %s", name, pattern)
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad file pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// configuredSplitter resolves the splitter from the configuration alone:
+// file name patterns win over loader names. It is recorded in the manifest,
+// so changing the configuration re-ingests the affected files.
+func (c Config) configuredSplitter(path, loaderName string) string {
+	patterns := make([]string, 0, len(c.FileSplitters))
+	for pattern := range c.FileSplitters {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	base := filepath.Base(path)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return c.FileSplitters[pattern]
+		}
+	}
+	if name, ok := c.LoaderSplitters[loaderName]; ok {
+		return name
+	}
+	return SplitterFixed
+}
+
+// documentSplitter lets document metadata override the configured splitter.
+func documentSplitter(job *fileJob) string {
+	if name := job.doc.Metadata[splitterMetadataKey]; validSplitter(name) {
+		return name
+	}
+	return job.splitter
+}
+
+func (s *Service) splitDocument(ctx context.Context, job *fileJob, st *runState) error {
+	var (
+		split []helpers.TextChunk
+		err   error
+	)
+
+	switch documentSplitter(job) {
+	case SplitterSemantic:
+		embed := func(ctx context.Context, texts []string) ([][]float32, error) {
+			out := make([][]float32, 0, len(texts))
+			for _, batch := range batchByTokens(texts, s.cfg.BatchTokens, s.cfg.BatchSize) {
+				embs, err := s.embedBatch(ctx, batch, st)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, embs...)
+			}
+			return out, nil
+		}
+		split, err = helpers.SplitTextSemantically(ctx, job.doc.Markdown, embed, s.cfg.Semantic)
+	default:
+		split, err = helpers.SplitTextByChunks(job.doc.Markdown)
+	}
+	if err != nil {
+		return err
+	}
+
+	job.chunks = split
+	return nil
+}
@@ -0,0 +1,55 @@
+package ingest
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"rag-test/internal/crawler"
+	"rag-test/internal/loader"
+)
+
+// webLoaderName is the loader name of crawled pages, e.g. for
+// Config.LoaderSplitters.
+const webLoaderName = "web"
+
+// crawlPages runs the crawler and turns the pages into documents keyed by
+// their URL, which becomes the data source of their chunks. The URLs that
+// failed are returned separately, so their earlier chunks are kept.
+func (s *Service) crawlPages(ctx context.Context) ([]documentFile, []crawler.FetchError, error) {
+	if s.webCrawler == nil {
+		return nil, nil, nil
+	}
+
+	result, err := s.webCrawler.Crawl(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := make([]documentFile, 0, len(result.Pages))
+	for _, page := range result.Pages {
+		title := strings.TrimSpace(page.Title)
+		if title == "" {
+			title = page.URL
+		}
+		files = append(files, documentFile{
+			Name:    title,
+			Path:    page.URL,
+			ModTime: page.FetchedAt,
+			page: &loader.Document{
+				Title:    title,
+				Markdown: page.Markdown,
+				Metadata: map[string]string{"loader": webLoaderName},
+			},
+		})
+	}
+
+	slog.Info(
+		"crawled web pages",
+		slog.Int("pages", len(result.Pages)),
+		slog.Int("failed", len(result.Failed)),
+		slog.Int("blocked", len(result.Blocked)),
+	)
+
+	return files, result.Failed, nil
+}
@@ -1,24 +1,189 @@
 package rag
 
 import (
+	"context"
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
 
+	"rag-test/internal/helpers"
 	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/repository/textstore"
 )
 
+const quoteTrimChars = " \t\n\"'«»“”„.…"
+
 func buildChunks(hits []milvusrepo.SearchHit) []Chunk {
 	chunks := make([]Chunk, 0, len(hits))
 	for i, hit := range hits {
 		chunks = append(chunks, Chunk{
-			ID:         fmt.Sprintf("C%d", i+1),
-			DataSource: hit.DataSource,
-			Text:       hit.Payload,
+			ID:          fmt.Sprintf("C%d", i+1),
+			RecordID:    hit.ID,
+			DataSource:  hit.DataSource,
+			Sources:     hitSources(hit),
+			Text:        hit.Payload,
+			ParentID:    hit.Metadata.SectionID,
+			Title:       hit.Metadata.Title,
+			HeadingPath: hit.Metadata.HeadingPath,
+			Ordinal:     hit.Metadata.Ordinal,
+			StartOffset: hit.Metadata.StartOffset,
+			EndOffset:   hit.Metadata.EndOffset,
 		})
 	}
 	return chunks
 }
 
+func hitSources(hit milvusrepo.SearchHit) []string {
+	if len(hit.Metadata.Sources) > 0 {
+		return hit.Metadata.Sources
+	}
+	return []string{hit.DataSource}
+}
+
+// mergeQuestionHits replaces question hits by their parent chunks. A chunk
+// found both ways keeps the better (higher) score; parents that the
+// chunk search missed are loaded by ID.
+func (s *Service) mergeQuestionHits(ctx context.Context, hits, questionHits []milvusrepo.SearchHit) ([]milvusrepo.SearchHit, error) {
+	byID := make(map[int64]int, len(hits))
+	merged := make([]milvusrepo.SearchHit, len(hits))
+	copy(merged, hits)
+	for i, hit := range merged {
+		byID[hit.ID] = i
+	}
+
+	missing := make(map[int64]float32)
+	for _, question := range questionHits {
+		parent := question.Metadata.ParentID
+		if parent == 0 {
+			continue
+		}
+		if i, ok := byID[parent]; ok {
+			merged[i].Score = max(merged[i].Score, question.Score)
+			continue
+		}
+		if score, ok := missing[parent]; !ok || question.Score > score {
+			missing[parent] = question.Score
+		}
+	}
+
+	if len(missing) > 0 {
+		ids := make([]int64, 0, len(missing))
+		for id := range missing {
+			ids = append(ids, id)
+		}
+		parents, err := s.vectorRepo.Get(ctx, s.collection, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, parent := range parents {
+			merged = append(merged, milvusrepo.SearchHit{
+				ID:         parent.ID,
+				Score:      missing[parent.ID],
+				Payload:    parent.Payload,
+				DataSource: parent.DataSource,
+				Metadata:   parent.Metadata,
+			})
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Score > merged[j].Score
+	})
+	return merged, nil
+}
+
+// collapseDuplicates keeps the best-scored hit of every group of
+// near-duplicate payloads, merging the sources of the others into it, and
+// returns at most limit hits.
+func collapseDuplicates(hits []milvusrepo.SearchHit, limit int) []milvusrepo.SearchHit {
+	kept := make([]milvusrepo.SearchHit, 0, min(len(hits), limit))
+	fingerprints := make([]uint64, 0, cap(kept))
+
+	for _, hit := range hits {
+		fingerprint := helpers.SimHash(hit.Payload)
+		merged := false
+		for i, other := range fingerprints {
+			if kept[i].ID != hit.ID && !helpers.IsNearDuplicate(fingerprint, other) {
+				continue
+			}
+			sources := hitSources(kept[i])
+			for _, source := range hitSources(hit) {
+				if !slices.Contains(sources, source) {
+					sources = append(sources, source)
+				}
+			}
+			kept[i].Metadata.Sources = sources
+			merged = true
+			break
+		}
+		if merged || len(kept) == limit {
+			continue
+		}
+
+		kept = append(kept, hit)
+		fingerprints = append(fingerprints, fingerprint)
+	}
+
+	return kept
+}
+
+// uniqueSections keeps the best hit per parent section and returns at most
+// limit hits; hits without a section are kept as they are.
+func uniqueSections(hits []milvusrepo.SearchHit, limit int) []milvusrepo.SearchHit {
+	seen := make(map[int64]struct{})
+	kept := make([]milvusrepo.SearchHit, 0, min(len(hits), limit))
+	for _, hit := range hits {
+		if len(kept) == limit {
+			break
+		}
+		if section := hit.Metadata.SectionID; section != 0 {
+			if _, ok := seen[section]; ok {
+				continue
+			}
+			seen[section] = struct{}{}
+		}
+		kept = append(kept, hit)
+	}
+	return kept
+}
+
+// attachParents loads the parent sections of the chunks in rank order while
+// they fit into the token budget; a chunk whose section does not fit is
+// passed on alone.
+func (s *Service) attachParents(chunks []Chunk, budget int) error {
+	if s.sectionStore == nil {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(chunks))
+	for _, chunk := range chunks {
+		if chunk.ParentID != 0 {
+			ids = append(ids, chunk.ParentID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	parents, err := s.sectionStore.Get(textstore.SectionsNamespace(s.collection), ids)
+	if err != nil {
+		return err
+	}
+
+	used := 0
+	for i, chunk := range chunks {
+		parent, ok := parents[chunk.ParentID]
+		if ok && used+helpers.EstimateTokens(parent) <= budget {
+			chunks[i].ParentText = parent
+			used += helpers.EstimateTokens(parent)
+			continue
+		}
+		used += helpers.EstimateTokens(chunk.Text)
+	}
+	return nil
+}
+
 func formatChunks(chunks []Chunk) string {
 	if len(chunks) == 0 {
 		return ""
@@ -27,10 +192,57 @@ func formatChunks(chunks []Chunk) string {
 	lines := make([]string, 0, len(chunks)*4)
 	for _, chunk := range chunks {
 		lines = append(lines, fmt.Sprintf("[%s]", chunk.ID))
-		lines = append(lines, fmt.Sprintf("data_source: %s", chunk.DataSource))
-		lines = append(lines, fmt.Sprintf("text: %s", chunk.Text))
+		lines = append(lines, fmt.Sprintf("data_source: %s", strings.Join(chunk.Sources, ", ")))
+		if section := Breadcrumb(chunk.Title, chunk.HeadingPath); section != "" {
+			lines = append(lines, fmt.Sprintf("section: %s", section))
+		}
+		lines = append(lines, fmt.Sprintf("text: %s", chunk.ContextText()))
 		lines = append(lines, "")
 	}
 
 	return strings.TrimSpace(strings.Join(lines, "\n"))
 }
+
+func resolveCitations(citations []Citation, chunks []Chunk) []Citation {
+	if len(citations) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]Chunk, len(chunks))
+	for _, chunk := range chunks {
+		byID[chunk.ID] = chunk
+	}
+
+	out := make([]Citation, len(citations))
+	for i, citation := range citations {
+		chunk, ok := byID[strings.TrimSpace(citation.ID)]
+		if ok {
+			citation.RecordID = chunk.RecordID
+			citation.DataSource = chunk.DataSource
+			citation.Title = chunk.Title
+			citation.HeadingPath = chunk.HeadingPath
+			if quote, found := helpers.FindOriginal(chunk.ContextText(), strings.Trim(citation.Quote, quoteTrimChars)); found {
+				citation.Quote = quote
+			}
+		}
+		out[i] = citation
+	}
+	return out
+}
+
+// Breadcrumb renders the document title and heading path as
+// "Цены › Стулья › Доставка"; a first heading repeating the title is skipped.
+func Breadcrumb(title string, headingPath []string) string {
+	parts := make([]string, 0, len(headingPath)+1)
+	if title = strings.TrimSpace(title); title != "" {
+		parts = append(parts, title)
+	}
+	for i, heading := range headingPath {
+		heading = strings.TrimSpace(heading)
+		if heading == "" || (i == 0 && strings.EqualFold(heading, title)) {
+			continue
+		}
+		parts = append(parts, heading)
+	}
+	return strings.Join(parts, " › ")
+}
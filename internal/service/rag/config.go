@@ -2,6 +2,11 @@ package rag
 
 const (
 	defaultTopK = 6
+	// duplicateOverfetch widens the search so that topK hits remain after
+	// near-duplicates are collapsed.
+	duplicateOverfetch = 2
+	// contextTokenBudget caps the parent sections passed to the answer stage.
+	contextTokenBudget = 6000
 
 	analysisMaxTokens   = 300
 	rewriteMaxTokens    = 200
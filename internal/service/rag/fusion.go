@@ -0,0 +1,157 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"rag-test/internal/repository/lexical"
+	milvusrepo "rag-test/internal/repository/milvus"
+)
+
+const (
+	FusionRRF      = "rrf"
+	FusionWeighted = "weighted"
+
+	defaultRRFK          = 60
+	defaultLexicalWeight = 0.3
+)
+
+// FusionConfig selects how the dense and lexical rankings are merged. RRFK
+// damps the lead of the top ranks in reciprocal rank fusion; LexicalWeight
+// is the share of the normalized BM25 score in weighted fusion, from 0 to 1;
+// nil takes the default.
+type FusionConfig struct {
+	Method        string
+	RRFK          int
+	LexicalWeight *float64
+}
+
+func (c FusionConfig) withDefaults() FusionConfig {
+	if c.Method == "" {
+		c.Method = FusionRRF
+	}
+	if c.RRFK <= 0 {
+		c.RRFK = defaultRRFK
+	}
+	if c.LexicalWeight == nil {
+		weight := defaultLexicalWeight
+		c.LexicalWeight = &weight
+	}
+	return c
+}
+
+func (c FusionConfig) Validate() error {
+	if c.Method != "" && c.Method != FusionRRF && c.Method != FusionWeighted {
+		return fmt.Errorf("unknown fusion method %q", c.Method)
+	}
+	if w := c.LexicalWeight; w != nil && !(*w >= 0 && *w <= 1) {
+		return fmt.Errorf("lexical weight %v is outside [0, 1]", *w)
+	}
+	return nil
+}
+
+// HitScores explains the rank of a chunk. Dense is the normalized score of
+// the vector search and Lexical the BM25 score; a rank of 0 means that search
+// missed the chunk. Fused is the score the chunks were ordered by.
+type HitScores struct {
+	Dense       float32
+	DenseRank   int
+	Lexical     float64
+	LexicalRank int
+	Fused       float64
+}
+
+// fuseHits merges the dense hits with the lexical ones, loading the chunks
+// only the lexical search found, and orders them by the fused score.
+func (s *Service) fuseHits(ctx context.Context, dense []milvusrepo.SearchHit, lexicalHits []lexical.Hit) ([]milvusrepo.SearchHit, map[int64]HitScores, error) {
+	scores := make(map[int64]HitScores, len(dense)+len(lexicalHits))
+	for i, hit := range dense {
+		scores[hit.ID] = HitScores{Dense: hit.Score, DenseRank: i + 1}
+	}
+
+	missing := make([]int64, 0)
+	for i, hit := range lexicalHits {
+		score, found := scores[hit.ID]
+		if !found {
+			missing = append(missing, hit.ID)
+		}
+		score.Lexical, score.LexicalRank = hit.Score, i+1
+		scores[hit.ID] = score
+	}
+
+	hits := make([]milvusrepo.SearchHit, len(dense), len(dense)+len(missing))
+	copy(hits, dense)
+	if len(missing) > 0 {
+		items, err := s.vectorRepo.Get(ctx, s.collection, missing)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, item := range items {
+			hits = append(hits, milvusrepo.SearchHit{
+				ID:         item.ID,
+				Payload:    item.Payload,
+				DataSource: item.DataSource,
+				Metadata:   item.Metadata,
+			})
+		}
+	}
+
+	switch s.fusion.Method {
+	case FusionWeighted:
+		weightedFusion(scores, dense, lexicalHits, *s.fusion.LexicalWeight)
+	default:
+		rrfFusion(scores, s.fusion.RRFK)
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return scores[hits[i].ID].Fused > scores[hits[j].ID].Fused
+	})
+	return hits, scores, nil
+}
+
+func rrfFusion(scores map[int64]HitScores, k int) {
+	for id, score := range scores {
+		score.Fused = 0
+		if score.DenseRank > 0 {
+			score.Fused += 1 / float64(k+score.DenseRank)
+		}
+		if score.LexicalRank > 0 {
+			score.Fused += 1 / float64(k+score.LexicalRank)
+		}
+		scores[id] = score
+	}
+}
+
+// weightedFusion scales the vector scores to [0, 1] with the closest hit at
+// 1 and the BM25 scores by the best one, then mixes them.
+func weightedFusion(scores map[int64]HitScores, dense []milvusrepo.SearchHit, lexicalHits []lexical.Hit, lexicalWeight float64) {
+	var nearest, farthest float32
+	for i, hit := range dense {
+		if i == 0 || hit.Score > nearest {
+			nearest = hit.Score
+		}
+		if i == 0 || hit.Score < farthest {
+			farthest = hit.Score
+		}
+	}
+	var best float64
+	for _, hit := range lexicalHits {
+		best = max(best, hit.Score)
+	}
+
+	for id, score := range scores {
+		var denseNorm, lexicalNorm float64
+		if score.DenseRank > 0 {
+			denseNorm = 1
+			if nearest > farthest {
+				denseNorm = float64(score.Dense-farthest) / float64(nearest-farthest)
+			}
+		}
+		if score.LexicalRank > 0 && best > 0 {
+			lexicalNorm = score.Lexical / best
+		}
+		score.Fused = (1-lexicalWeight)*denseNorm + lexicalWeight*lexicalNorm
+		scores[id] = score
+	}
+}
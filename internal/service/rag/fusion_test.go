@@ -0,0 +1,140 @@
+package rag
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"rag-test/internal/repository/lexical"
+	"rag-test/internal/repository/memory"
+	milvusrepo "rag-test/internal/repository/milvus"
+)
+
+func TestFuseHits(t *testing.T) {
+	ctx := context.Background()
+	repo, err := memory.NewRepository(memory.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.EnsureCollection(ctx, "kb", milvusrepo.CollectionConfig{Dim: 2}); err != nil {
+		t.Fatal(err)
+	}
+	items := make([]milvusrepo.VectorItem, 0, 4)
+	for id := int64(1); id <= 4; id++ {
+		items = append(items, milvusrepo.VectorItem{ID: id, Embedding: []float32{1, 0}, Payload: "chunk", DataSource: "doc.md"})
+	}
+	if err := repo.Upsert(ctx, "kb", items); err != nil {
+		t.Fatal(err)
+	}
+
+	dense := []milvusrepo.SearchHit{
+		{ID: 1, Score: 0.9, Payload: "chunk"},
+		{ID: 2, Score: 0.8, Payload: "chunk"},
+		{ID: 3, Score: 0.5, Payload: "chunk"},
+	}
+
+	tests := []struct {
+		name    string
+		fusion  FusionConfig
+		lexical []lexical.Hit
+		want    []int64
+	}{
+		{
+			name:   "dense only",
+			fusion: FusionConfig{Method: FusionRRF},
+			want:   []int64{1, 2, 3},
+		},
+		{
+			name:    "rrf rewards agreement",
+			fusion:  FusionConfig{Method: FusionRRF},
+			lexical: []lexical.Hit{{ID: 2, Score: 12}, {ID: 3, Score: 10}},
+			want:    []int64{2, 3, 1},
+		},
+		{
+			name:    "rrf loads lexical only hits",
+			fusion:  FusionConfig{Method: FusionRRF},
+			lexical: []lexical.Hit{{ID: 4, Score: 5}},
+			want:    []int64{1, 4, 2, 3},
+		},
+		{
+			name:    "weighted keeps the dense lead",
+			fusion:  FusionConfig{Method: FusionWeighted, LexicalWeight: weight(0.3)},
+			lexical: []lexical.Hit{{ID: 3, Score: 12}, {ID: 2, Score: 6}},
+			want:    []int64{1, 2, 3},
+		},
+		{
+			name:    "weighted with a heavy lexical share",
+			fusion:  FusionConfig{Method: FusionWeighted, LexicalWeight: weight(0.9)},
+			lexical: []lexical.Hit{{ID: 3, Score: 12}, {ID: 4, Score: 6}},
+			want:    []int64{3, 4, 1, 2},
+		},
+		{
+			name:    "weighted without the lexical share",
+			fusion:  FusionConfig{Method: FusionWeighted, LexicalWeight: weight(0)},
+			lexical: []lexical.Hit{{ID: 3, Score: 12}, {ID: 4, Score: 6}},
+			want:    []int64{1, 2, 3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{vectorRepo: repo, collection: "kb", fusion: tt.fusion.withDefaults()}
+			hits, scores, err := s.fuseHits(ctx, slices.Clone(dense), tt.lexical)
+			if err != nil {
+				t.Fatalf("fuseHits: %v", err)
+			}
+
+			got := make([]int64, 0, len(hits))
+			for _, hit := range hits {
+				got = append(got, hit.ID)
+				if hit.Payload == "" {
+					t.Errorf("hit %d has no payload", hit.ID)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+			for i := 1; i < len(hits); i++ {
+				if scores[hits[i-1].ID].Fused < scores[hits[i].ID].Fused {
+					t.Errorf("hits not ordered by fused score: %v", scores)
+				}
+			}
+			for _, hit := range tt.lexical {
+				if scores[hit.ID].LexicalRank == 0 {
+					t.Errorf("lexical hit %d has no lexical rank", hit.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestFusionConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		fusion  FusionConfig
+		wantErr bool
+	}{
+		{name: "defaults", fusion: FusionConfig{}},
+		{name: "no lexical share", fusion: FusionConfig{Method: FusionWeighted, LexicalWeight: weight(0)}},
+		{name: "lexical only", fusion: FusionConfig{Method: FusionWeighted, LexicalWeight: weight(1)}},
+		{name: "negative weight", fusion: FusionConfig{Method: FusionWeighted, LexicalWeight: weight(-0.1)}, wantErr: true},
+		{name: "weight above one", fusion: FusionConfig{Method: FusionWeighted, LexicalWeight: weight(1.5)}, wantErr: true},
+		{name: "unknown method", fusion: FusionConfig{Method: "max"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fusion.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if got := *(FusionConfig{LexicalWeight: weight(0)}).withDefaults().LexicalWeight; got != 0 {
+		t.Errorf("withDefaults kept lexical weight %v, want 0", got)
+	}
+}
+
+func weight(w float64) *float64 {
+	return &w
+}
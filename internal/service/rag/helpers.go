@@ -55,16 +55,6 @@ func copyChunks(values []Chunk) []Chunk {
 	return out
 }
 
-func copyCitations(values []Citation) []Citation {
-	if len(values) == 0 {
-		return nil
-	}
-
-	out := make([]Citation, len(values))
-	copy(out, values)
-	return out
-}
-
 func trimOptional(value *string) string {
 	if value == nil {
 		return ""
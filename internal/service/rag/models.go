@@ -28,16 +28,39 @@ type Response struct {
 	Validation    ValidationResult
 }
 
+// Chunk is a search hit: Text is the matched chunk, ParentText the larger
+// section around it when one was stored and fit into the context budget.
 type Chunk struct {
-	ID         string
-	DataSource string
-	Text       string
+	ID          string
+	RecordID    int64
+	DataSource  string
+	Sources     []string
+	Text        string
+	ParentID    int64
+	ParentText  string
+	Title       string
+	HeadingPath []string
+	Ordinal     int
+	StartOffset int
+	EndOffset   int
+	Scores      HitScores
+}
+
+// ContextText is what the answer stage reads for the chunk.
+func (c Chunk) ContextText() string {
+	if c.ParentText != "" {
+		return c.ParentText
+	}
+	return c.Text
 }
 
 type Citation struct {
-	ID         string
-	Quote      string
-	DataSource string
+	ID          string
+	RecordID    int64 `json:"-"`
+	Quote       string
+	DataSource  string
+	Title       string   `json:"-"`
+	HeadingPath []string `json:"-"`
 }
 
 type ValidationResult struct {
@@ -6,23 +6,36 @@ import (
 	"strings"
 
 	"rag-test/internal/repository/embeddings"
+	"rag-test/internal/repository/lexical"
 	milvusrepo "rag-test/internal/repository/milvus"
 	openairepo "rag-test/internal/repository/openai"
+	"rag-test/internal/repository/textstore"
 )
 
 type Service struct {
 	openaiRepo     *openairepo.Repository
 	embeddingsRepo *embeddings.Repository
 	vectorRepo     milvusrepo.VectorRepository
-	collection     string
-	defaultTopK    int
+	sectionStore   *textstore.Store
+	// lexicalRepo enables hybrid retrieval; nil searches vectors only.
+	lexicalRepo *lexical.Repository
+	fusion      FusionConfig
+	collection  string
+	// questionsCollection holds generated questions pointing to parent
+	// chunks; empty disables searching it.
+	questionsCollection string
+	defaultTopK         int
 }
 
 func NewService(
 	openaiRepo *openairepo.Repository,
 	embeddingsRepo *embeddings.Repository,
 	vectorRepo milvusrepo.VectorRepository,
+	sectionStore *textstore.Store,
+	lexicalRepo *lexical.Repository,
+	fusion FusionConfig,
 	collection string,
+	questionsCollection string,
 	topK int,
 ) *Service {
 	if topK <= 0 {
@@ -30,11 +43,15 @@ func NewService(
 	}
 
 	return &Service{
-		openaiRepo:     openaiRepo,
-		embeddingsRepo: embeddingsRepo,
-		vectorRepo:     vectorRepo,
-		collection:     collection,
-		defaultTopK:    topK,
+		openaiRepo:          openaiRepo,
+		embeddingsRepo:      embeddingsRepo,
+		vectorRepo:          vectorRepo,
+		sectionStore:        sectionStore,
+		lexicalRepo:         lexicalRepo,
+		fusion:              fusion.withDefaults(),
+		collection:          collection,
+		questionsCollection: questionsCollection,
+		defaultTopK:         topK,
 	}
 }
 
@@ -44,6 +61,10 @@ func (s *Service) Answer(ctx context.Context, req Request) (*Response, error) {
 		return nil, errors.New("question is empty")
 	}
 
+	if err := s.fusion.Validate(); err != nil {
+		return nil, err
+	}
+
 	dialogContext := resolveDialogContext(req)
 
 	chunks, err := s.fetchChunks(ctx, question, req.TopK)
@@ -82,7 +103,7 @@ func (s *Service) Answer(ctx context.Context, req Request) (*Response, error) {
 
 	response.Answer = strings.TrimSpace(answer.Text)
 	response.CitationsUsed = copyStrings(answer.CitationsUsed)
-	response.Citations = copyCitations(answer.Citations)
+	response.Citations = resolveCitations(answer.Citations, chunks)
 
 	validation, err := s.validateAnswer(ctx, question, response.Answer, chunksText)
 	if err != nil {
@@ -98,7 +119,7 @@ func (s *Service) Answer(ctx context.Context, req Request) (*Response, error) {
 
 		response.Answer = strings.TrimSpace(rewritten.Text)
 		response.CitationsUsed = copyStrings(rewritten.CitationsUsed)
-		response.Citations = copyCitations(rewritten.Citations)
+		response.Citations = resolveCitations(rewritten.Citations, chunks)
 	}
 
 	return response, nil
@@ -6,6 +6,11 @@ import (
 	"errors"
 	"log/slog"
 	"strings"
+	"sync"
+
+	"rag-test/internal/helpers"
+	"rag-test/internal/repository/lexical"
+	milvusrepo "rag-test/internal/repository/milvus"
 )
 
 type clarificationResult struct {
@@ -49,7 +54,7 @@ func normalizeAnswer(parsed answerResult) answerResult {
 
 func parseAnswer(content, userPrompt, chunks, question, stage string) (answerResult, error) {
 	var parsed answerResult
-	if err := decodeJSON(content, &parsed); err != nil {
+	if err := helpers.DecodeJSON(content, &parsed); err != nil {
 		slog.Error(
 			"failed to parse answer response",
 			slog.String("error", err.Error()),
@@ -93,7 +98,7 @@ func (s *Service) checkClarification(ctx context.Context, question, dialogContex
 	}
 
 	var parsed clarificationResult
-	if err := decodeJSON(content, &parsed); err != nil {
+	if err := helpers.DecodeJSON(content, &parsed); err != nil {
 		slog.Error("failed to parse clarification response", slog.String("error", err.Error()))
 		return clarificationResult{}, err
 	}
@@ -114,7 +119,7 @@ func (s *Service) rewriteQueries(ctx context.Context, question, dialogContext st
 	}
 
 	var parsed rewriteResult
-	if err := decodeJSON(content, &parsed); err != nil {
+	if err := helpers.DecodeJSON(content, &parsed); err != nil {
 		slog.Error("failed to parse rewrite response", slog.String("error", err.Error()))
 		return rewriteResult{}, err
 	}
@@ -127,7 +132,63 @@ func (s *Service) fetchChunks(ctx context.Context, question string, topK int) ([
 		topK = s.defaultTopK
 	}
 
-	vectors, err := s.embeddingsRepo.CreateEmbeddings(ctx, question)
+	// The lexical search does not need the query embedding, so it runs
+	// while the embedding is created and the vectors are searched.
+	var (
+		wg          sync.WaitGroup
+		lexicalHits []lexical.Hit
+		lexicalErr  error
+	)
+	if s.lexicalRepo != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lexicalHits, lexicalErr = s.lexicalRepo.SearchText(s.collection, question, topK*duplicateOverfetch)
+		}()
+	}
+
+	hits, err := s.denseHits(ctx, question, topK)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	if lexicalErr != nil {
+		slog.Error("failed to search chunks lexically", slog.String("error", lexicalErr.Error()))
+		return nil, lexicalErr
+	}
+
+	scores := make(map[int64]HitScores, len(hits))
+	if s.lexicalRepo != nil {
+		hits, scores, err = s.fuseHits(ctx, hits, lexicalHits)
+		if err != nil {
+			slog.Error("failed to load lexical hits", slog.String("error", err.Error()))
+			return nil, err
+		}
+	} else {
+		for i, hit := range hits {
+			scores[hit.ID] = HitScores{Dense: hit.Score, DenseRank: i + 1}
+		}
+	}
+
+	hits = uniqueSections(collapseDuplicates(hits, len(hits)), topK)
+	chunks := buildChunks(hits)
+	for i := range chunks {
+		chunks[i].Scores = scores[chunks[i].RecordID]
+	}
+	if err := s.attachParents(chunks, contextTokenBudget); err != nil {
+		slog.Error("failed to load parent sections", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return chunks, nil
+}
+
+// denseHits embeds the question and searches the chunks and, when enabled,
+// the generated questions, ordered by score. It has one query vector for
+// two collections, while SearchBatch sends several vectors to one
+// collection, so the two searches stay separate requests.
+func (s *Service) denseHits(ctx context.Context, question string, topK int) ([]milvusrepo.SearchHit, error) {
+	vectors, err := s.embeddingsRepo.CreateEmbeddings(ctx, helpers.NormalizeText(question))
 	if err != nil {
 		slog.Error("failed to create embeddings", slog.String("error", err.Error()))
 		return nil, err
@@ -136,13 +197,27 @@ func (s *Service) fetchChunks(ctx context.Context, question string, topK int) ([
 		return nil, errors.New("empty embeddings")
 	}
 
-	hits, err := s.vectorRepo.Search(ctx, s.collection, vectors[0], topK)
+	req := milvusrepo.SearchRequest{Vector: vectors[0], TopK: topK * duplicateOverfetch}
+	hits, err := s.vectorRepo.Search(ctx, s.collection, req)
 	if err != nil {
 		slog.Error("failed to search chunks", slog.String("error", err.Error()))
 		return nil, err
 	}
 
-	return buildChunks(hits), nil
+	if s.questionsCollection != "" {
+		questionHits, err := s.vectorRepo.Search(ctx, s.questionsCollection, req)
+		if err != nil {
+			slog.Error("failed to search questions", slog.String("error", err.Error()))
+			return nil, err
+		}
+		hits, err = s.mergeQuestionHits(ctx, hits, questionHits)
+		if err != nil {
+			slog.Error("failed to load parent chunks", slog.String("error", err.Error()))
+			return nil, err
+		}
+	}
+
+	return hits, nil
 }
 
 func (s *Service) generateAnswer(ctx context.Context, question, dialogContext, chunks string) (answerResult, error) {
@@ -198,7 +273,7 @@ func (s *Service) validateAnswer(ctx context.Context, question, answerText, chun
 	}
 
 	var parsed validationPayload
-	if err := decodeJSON(content, &parsed); err != nil {
+	if err := helpers.DecodeJSON(content, &parsed); err != nil {
 		slog.Warn(
 			"failed to parse validation response",
 			slog.String("error", err.Error()),
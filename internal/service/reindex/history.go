@@ -0,0 +1,93 @@
+package reindex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"rag-test/internal/storage/atomicfile"
+)
+
+const historyVersion = 1
+
+// Version is one collection an alias pointed at, with the embedding model
+// its vectors were made with; queries against it must use the same model.
+type Version struct {
+	Collection     string    `json:"collection"`
+	EmbeddingModel string    `json:"embedding_model"`
+	Dim            int       `json:"dim"`
+	ActivatedAt    time.Time `json:"activated_at"`
+}
+
+// History lists the versions an alias pointed at, the active one last.
+type History struct {
+	Version  int       `json:"version"`
+	Alias    string    `json:"alias"`
+	Versions []Version `json:"versions"`
+}
+
+func (h *History) Active() (Version, bool) {
+	if len(h.Versions) == 0 {
+		return Version{}, false
+	}
+	return h.Versions[len(h.Versions)-1], true
+}
+
+// Lookup finds the version kept in collection.
+func (h *History) Lookup(collection string) (Version, bool) {
+	for _, v := range h.Versions {
+		if v.Collection == collection {
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+// HistoryStore keeps one history file per alias under dir.
+type HistoryStore struct {
+	dir string
+}
+
+func NewHistoryStore(dir string) (*HistoryStore, error) {
+	if dir == "" {
+		return nil, errors.New("alias history dir is empty")
+	}
+
+	return &HistoryStore{dir: dir}, nil
+}
+
+// Load returns an empty history for an alias that was never switched.
+func (s *HistoryStore) Load(alias string) (*History, error) {
+	data, err := os.ReadFile(s.path(alias))
+	if errors.Is(err, os.ErrNotExist) {
+		return &History{Version: historyVersion, Alias: alias}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var h History
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("decode alias history %s: %w", s.path(alias), err)
+	}
+	if h.Version != historyVersion {
+		return nil, fmt.Errorf("alias history %s has version %d, want %d", s.path(alias), h.Version, historyVersion)
+	}
+	return &h, nil
+}
+
+func (s *HistoryStore) Save(h *History) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.Write(s.path(h.Alias), data)
+}
+
+func (s *HistoryStore) path(alias string) string {
+	return filepath.Join(s.dir, alias+".json")
+}
@@ -0,0 +1,654 @@
+package reindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"rag-test/internal/repository/embeddings"
+	"rag-test/internal/repository/lexical"
+	"rag-test/internal/repository/manifest"
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/repository/textstore"
+)
+
+const (
+	defaultSampleSize   = 50
+	defaultTopK         = 10
+	defaultMinOverlap   = 0.5
+	defaultMaxCountDrop = 0.05
+
+	// maxQueryRunes keeps sampled chunk texts at the length of a question.
+	maxQueryRunes = 500
+)
+
+// Config tunes the validation of a new version: SampleSize chunks of it are
+// used as queries against both versions, and the new one passes when the
+// documents of the top TopK hits overlap by at least MinOverlap on average
+// and it lost at most MaxCountDrop of the old entity count.
+type Config struct {
+	SampleSize   int
+	TopK         int
+	MinOverlap   float64
+	MaxCountDrop float64
+}
+
+func (c Config) withDefaults() Config {
+	if c.SampleSize <= 0 {
+		c.SampleSize = defaultSampleSize
+	}
+	if c.TopK <= 0 {
+		c.TopK = defaultTopK
+	}
+	if c.MinOverlap <= 0 || c.MinOverlap > 1 {
+		c.MinOverlap = defaultMinOverlap
+	}
+	if c.MaxCountDrop <= 0 || c.MaxCountDrop > 1 {
+		c.MaxCountDrop = defaultMaxCountDrop
+	}
+	return c
+}
+
+// Stores are the local stores kept per collection. They are copied with a
+// collection when it is adopted and go away when it is dropped.
+// LegacyManifest is the manifest of the collection made before versions.
+type Stores struct {
+	Texts          *textstore.Store
+	LexicalDir     string
+	StateDir       string
+	LegacyManifest string
+}
+
+type Embedder interface {
+	CreateEmbeddings(ctx context.Context, data ...string) ([][]float32, error)
+}
+
+// Target is a collection with the embedder its vectors were made with.
+type Target struct {
+	Collection string
+	Embedder   Embedder
+}
+
+// Validation compares a new version with the active one. Overlap is the
+// mean share of the documents in the old top hits that the new top hits
+// also come from.
+type Validation struct {
+	OldCount int64
+	NewCount int64
+	Queries  int
+	Overlap  float64
+	Problems []string
+}
+
+func (v Validation) OK() bool {
+	return len(v.Problems) == 0
+}
+
+// AliasRepository is a vector store that puts aliases on collections the
+// way Milvus does: an alias cannot take the name of a collection, and
+// ResolveAlias returns name itself for a plain collection and "" when
+// nothing has the name.
+type AliasRepository interface {
+	milvusrepo.VectorRepository
+	ResolveAlias(ctx context.Context, name string) (string, error)
+	SwapAlias(ctx context.Context, alias, collection string) error
+	RenameCollection(ctx context.Context, from, to string) error
+	Count(ctx context.Context, collection string) (int64, error)
+}
+
+var _ AliasRepository = (*milvusrepo.MilvusRepository)(nil)
+
+// Service switches the alias the application reads from between versioned
+// collections named <alias>_v<version>. Only the main collection has an
+// alias; its questions collection and local stores follow by name.
+type Service struct {
+	vectors AliasRepository
+	history *HistoryStore
+	stores  Stores
+	cfg     Config
+}
+
+func NewService(vectors AliasRepository, history *HistoryStore, stores Stores, cfg Config) *Service {
+	return &Service{
+		vectors: vectors,
+		history: history,
+		stores:  stores,
+		cfg:     cfg.withDefaults(),
+	}
+}
+
+func VersionName(alias, version string) string {
+	return alias + "_v" + version
+}
+
+// ManifestPath and CheckpointDir place the ingestion state of a versioned
+// collection under stateDir, apart from the other versions.
+func ManifestPath(stateDir, collection string) string {
+	return filepath.Join(versionDir(stateDir, collection), "manifest.json")
+}
+
+func CheckpointDir(stateDir, collection string) string {
+	return filepath.Join(versionDir(stateDir, collection), "checkpoints")
+}
+
+func versionDir(stateDir, collection string) string {
+	return filepath.Join(stateDir, "versions", collection)
+}
+
+// Resolve returns the collection behind alias: a version, the plain
+// collection made before versions, or "" when neither exists.
+func (s *Service) Resolve(ctx context.Context, alias string) (string, error) {
+	return s.vectors.ResolveAlias(ctx, alias)
+}
+
+// Owner returns the alias that recorded collection as one of its versions,
+// false for a collection made without versions.
+func (s *Service) Owner(collection string) (string, bool, error) {
+	for i := strings.Index(collection, "_v"); i > 0; {
+		alias := collection[:i]
+		h, err := s.history.Load(alias)
+		if err != nil {
+			return "", false, err
+		}
+		if _, ok := h.Lookup(collection); ok {
+			return alias, true, nil
+		}
+
+		next := strings.Index(collection[i+1:], "_v")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return "", false, nil
+}
+
+// ManifestRepository opens the ingestion manifest of a versioned collection.
+func (s *Service) ManifestRepository(collection string) (*manifest.Repository, error) {
+	return manifest.NewRepository(ManifestPath(s.stores.StateDir, collection))
+}
+
+// Active returns the version alias points at; false when there is no alias
+// yet, as for a collection made before versions.
+func (s *Service) Active(ctx context.Context, alias string) (Version, bool, error) {
+	current, err := s.vectors.ResolveAlias(ctx, alias)
+	if err != nil {
+		return Version{}, false, err
+	}
+	if current == alias {
+		return Version{}, false, nil
+	}
+
+	h, err := s.history.Load(alias)
+	if err != nil {
+		return Version{}, false, err
+	}
+	if current == "" {
+		// Activate records a version before it replaces the plain
+		// collection with the alias; readers go straight to it meanwhile.
+		return s.recorded(ctx, h)
+	}
+	if v, ok := h.Lookup(current); ok {
+		return v, true, nil
+	}
+	// An alias made by hand; assume the model collections always had.
+	return Version{Collection: current, EmbeddingModel: embeddings.DefaultModel, Dim: embeddings.DefaultDim}, true, nil
+}
+
+// Adopt copies a plain collection named alias, made before versions, into
+// version 0. The plain collection keeps serving until Activate puts the
+// alias in its place, and the copy is rebuilt on every call until then, so
+// it is safe to rerun after a crash. Once alias is an alias Adopt is a
+// no-op. It returns the active version, zero when nothing is indexed yet.
+func (s *Service) Adopt(ctx context.Context, alias string) (Version, error) {
+	legacy := VersionName(alias, "0")
+
+	current, err := s.vectors.ResolveAlias(ctx, alias)
+	if err != nil {
+		return Version{}, err
+	}
+	switch current {
+	case alias:
+	case "":
+		h, err := s.history.Load(alias)
+		if err != nil {
+			return Version{}, err
+		}
+		v, ok, err := s.recorded(ctx, h)
+		if err != nil || !ok {
+			return Version{}, err
+		}
+		// A crash came between renaming the plain collection aside and
+		// creating the alias.
+		if err := s.vectors.SwapAlias(ctx, alias, v.Collection); err != nil {
+			return Version{}, err
+		}
+		return v, s.dropLegacy(ctx, alias)
+	default:
+		v, _, err := s.Active(ctx, alias)
+		return v, err
+	}
+
+	if err := s.copyCollection(ctx, alias, legacy); err != nil {
+		return Version{}, err
+	}
+	if err := s.copyStores(alias, legacy); err != nil {
+		return Version{}, err
+	}
+	model, dim, err := s.adoptManifest(alias, legacy)
+	if err != nil {
+		return Version{}, err
+	}
+
+	v := Version{Collection: legacy, EmbeddingModel: model, Dim: dim, ActivatedAt: time.Now().UTC()}
+	if err := s.record(alias, v); err != nil {
+		return Version{}, err
+	}
+	slog.Info("copied collection into version 0", slog.String("alias", alias), slog.String("collection", legacy))
+	return v, nil
+}
+
+// Validate compares next with old, which may be empty for the first
+// version. Sampled chunk texts of next are embedded with each version's own
+// model and searched in both. Hits are compared by the document they come
+// from rather than by ID, since a new version usually splits, contextualizes
+// or embeds the chunks differently and so has other chunk IDs.
+func (s *Service) Validate(ctx context.Context, old, next Target, sampleIDs []int64) (Validation, error) {
+	var (
+		v   Validation
+		err error
+	)
+
+	if v.NewCount, err = s.vectors.Count(ctx, next.Collection); err != nil {
+		return v, err
+	}
+	if v.NewCount == 0 {
+		v.Problems = append(v.Problems, fmt.Sprintf("collection %s is empty", next.Collection))
+	}
+	if old.Collection == "" {
+		return v, nil
+	}
+
+	if v.OldCount, err = s.vectors.Count(ctx, old.Collection); err != nil {
+		return v, err
+	}
+	if float64(v.NewCount) < float64(v.OldCount)*(1-s.cfg.MaxCountDrop) {
+		v.Problems = append(v.Problems, fmt.Sprintf("collection %s has %d entities, %s has %d", next.Collection, v.NewCount, old.Collection, v.OldCount))
+	}
+
+	queries, err := s.sampleQueries(ctx, next.Collection, sampleIDs)
+	if err != nil || len(queries) == 0 {
+		return v, err
+	}
+	oldHits, err := s.search(ctx, old, queries)
+	if err != nil {
+		return v, err
+	}
+	newHits, err := s.search(ctx, next, queries)
+	if err != nil {
+		return v, err
+	}
+
+	var total float64
+	for i := range queries {
+		if len(oldHits[i]) == 0 {
+			continue
+		}
+		want := hitSources(oldHits[i])
+		shared := 0
+		for source := range hitSources(newHits[i]) {
+			if _, ok := want[source]; ok {
+				shared++
+			}
+		}
+		total += float64(shared) / float64(len(want))
+		v.Queries++
+	}
+	if v.Queries > 0 {
+		v.Overlap = total / float64(v.Queries)
+		if v.Overlap < s.cfg.MinOverlap {
+			v.Problems = append(v.Problems, fmt.Sprintf("document overlap of the top %d hits %.2f is below %.2f", s.cfg.TopK, v.Overlap, s.cfg.MinOverlap))
+		}
+	}
+
+	return v, nil
+}
+
+// Activate points alias at v and records it, keeping the previous version
+// for Rollback.
+//
+// A plain collection named alias has to give up its name first, since
+// Milvus cannot put an alias on the name of a collection; Adopt has copied
+// it into version 0. It is renamed aside rather than dropped, renamed back
+// when the alias cannot be created and only dropped once the alias is in
+// place. Readers of the name miss it between the rename and the alias,
+// while Active already returns v.
+func (s *Service) Activate(ctx context.Context, alias string, v Version) error {
+	current, err := s.vectors.ResolveAlias(ctx, alias)
+	if err != nil {
+		return err
+	}
+
+	v.ActivatedAt = time.Now().UTC()
+	if current != alias {
+		if err := s.vectors.SwapAlias(ctx, alias, v.Collection); err != nil {
+			return err
+		}
+		return s.record(alias, v)
+	}
+
+	if err := s.record(alias, v); err != nil {
+		return err
+	}
+	retired := retiredName(alias)
+	if err := s.dropIfExists(ctx, retired); err != nil {
+		return err
+	}
+	if err := s.vectors.RenameCollection(ctx, alias, retired); err != nil {
+		return err
+	}
+	if err := s.vectors.SwapAlias(ctx, alias, v.Collection); err != nil {
+		if restoreErr := s.vectors.RenameCollection(ctx, retired, alias); restoreErr != nil {
+			return errors.Join(err, fmt.Errorf("rename %s back to %s: %w", retired, alias, restoreErr))
+		}
+		return err
+	}
+	return s.dropLegacy(ctx, alias)
+}
+
+// Replace activates v and then drops the collection it replaced, for
+// imports that overwrite a collection rather than add a version to roll
+// back to.
+func (s *Service) Replace(ctx context.Context, alias string, v Version) error {
+	current, err := s.vectors.ResolveAlias(ctx, alias)
+	if err != nil {
+		return err
+	}
+	if err := s.Activate(ctx, alias, v); err != nil {
+		return err
+	}
+	// Activate already dropped a plain collection.
+	if current == "" || current == alias || current == v.Collection {
+		return nil
+	}
+	return s.Drop(ctx, alias, current)
+}
+
+// Rollback points alias back at the version before the active one. The
+// retired collection is kept until Drop.
+func (s *Service) Rollback(ctx context.Context, alias string) (Version, error) {
+	h, err := s.history.Load(alias)
+	if err != nil {
+		return Version{}, err
+	}
+	if len(h.Versions) < 2 {
+		return Version{}, fmt.Errorf("alias %s has no previous version", alias)
+	}
+
+	prev := h.Versions[len(h.Versions)-2]
+	exists, err := s.vectors.ResolveAlias(ctx, prev.Collection)
+	if err != nil {
+		return Version{}, err
+	}
+	if exists != prev.Collection {
+		return Version{}, fmt.Errorf("previous version %s no longer exists", prev.Collection)
+	}
+
+	if err := s.vectors.SwapAlias(ctx, alias, prev.Collection); err != nil {
+		return Version{}, err
+	}
+	h.Versions = h.Versions[:len(h.Versions)-1]
+	return prev, s.history.Save(h)
+}
+
+// Drop deletes a version that alias no longer points at, with its
+// questions collection and local stores.
+func (s *Service) Drop(ctx context.Context, alias, collection string) error {
+	current, err := s.vectors.ResolveAlias(ctx, alias)
+	if err != nil {
+		return err
+	}
+	if current == collection {
+		return fmt.Errorf("collection %s is the active version of %s", collection, alias)
+	}
+
+	for _, name := range []string{collection, milvusrepo.QuestionsCollection(collection)} {
+		if err := s.dropIfExists(ctx, name); err != nil {
+			return err
+		}
+	}
+	if err := s.dropStores(collection); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(versionDir(s.stores.StateDir, collection)); err != nil {
+		return err
+	}
+
+	h, err := s.history.Load(alias)
+	if err != nil {
+		return err
+	}
+	h.Versions = slices.DeleteFunc(h.Versions, func(v Version) bool { return v.Collection == collection })
+	return s.history.Save(h)
+}
+
+func (s *Service) record(alias string, v Version) error {
+	h, err := s.history.Load(alias)
+	if err != nil {
+		return err
+	}
+	h.Versions = slices.DeleteFunc(h.Versions, func(old Version) bool { return old.Collection == v.Collection })
+	h.Versions = append(h.Versions, v)
+	return s.history.Save(h)
+}
+
+// recorded returns the active version of the history when its collection
+// still exists.
+func (s *Service) recorded(ctx context.Context, h *History) (Version, bool, error) {
+	v, ok := h.Active()
+	if !ok {
+		return Version{}, false, nil
+	}
+	current, err := s.vectors.ResolveAlias(ctx, v.Collection)
+	if err != nil || current != v.Collection {
+		return Version{}, false, err
+	}
+	return v, true, nil
+}
+
+// copyCollection replaces to with a copy of from and its questions
+// collection. The payloads are copied as stored, cut or not, next to the
+// full texts copyStores takes along.
+func (s *Service) copyCollection(ctx context.Context, from, to string) error {
+	pairs := [][2]string{{from, to}, {milvusrepo.QuestionsCollection(from), milvusrepo.QuestionsCollection(to)}}
+	for _, pair := range pairs {
+		if err := s.dropIfExists(ctx, pair[1]); err != nil {
+			return err
+		}
+		exists, err := s.vectors.ResolveAlias(ctx, pair[0])
+		if err != nil {
+			return err
+		}
+		if exists != pair[0] {
+			continue
+		}
+		if err := milvusrepo.CopyCollection(ctx, s.vectors, pair[0], pair[1]); err != nil {
+			return fmt.Errorf("copy %s to %s: %w", pair[0], pair[1], err)
+		}
+
+		want, err := s.vectors.Count(ctx, pair[0])
+		if err != nil {
+			return err
+		}
+		got, err := s.vectors.Count(ctx, pair[1])
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return fmt.Errorf("copy of %s has %d entities, want %d", pair[0], got, want)
+		}
+	}
+	return nil
+}
+
+func (s *Service) dropIfExists(ctx context.Context, name string) error {
+	exists, err := s.vectors.ResolveAlias(ctx, name)
+	if err != nil || exists != name {
+		return err
+	}
+	return s.vectors.DropCollection(ctx, name)
+}
+
+func (s *Service) copyStores(from, to string) error {
+	fromNamespaces, toNamespaces := storeNamespaces(from), storeNamespaces(to)
+	for i := range fromNamespaces {
+		if err := s.stores.Texts.Copy(fromNamespaces[i], toNamespaces[i]); err != nil {
+			return err
+		}
+	}
+	return lexical.CopyIndex(s.stores.LexicalDir, from, to)
+}
+
+func (s *Service) dropStores(collection string) error {
+	for _, namespace := range storeNamespaces(collection) {
+		if err := s.stores.Texts.Drop(namespace); err != nil {
+			return err
+		}
+	}
+	return lexical.DropIndex(s.stores.LexicalDir, collection)
+}
+
+// dropLegacy removes what is left of the plain collection once the alias
+// took its name: the collection itself, renamed aside, its questions
+// collection, local stores and manifest.
+func (s *Service) dropLegacy(ctx context.Context, alias string) error {
+	for _, name := range []string{retiredName(alias), milvusrepo.QuestionsCollection(alias)} {
+		if err := s.dropIfExists(ctx, name); err != nil {
+			return err
+		}
+	}
+	if err := s.dropStores(alias); err != nil {
+		return err
+	}
+	if s.stores.LegacyManifest == "" {
+		return nil
+	}
+	if err := os.Remove(s.stores.LegacyManifest); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// adoptManifest copies the legacy manifest to the version layout and
+// returns the embedding model it records.
+func (s *Service) adoptManifest(from, to string) (string, int, error) {
+	model, dim := embeddings.DefaultModel, embeddings.DefaultDim
+	if s.stores.LegacyManifest == "" {
+		return model, dim, nil
+	}
+
+	legacy, err := manifest.NewRepository(s.stores.LegacyManifest)
+	if err != nil {
+		return "", 0, err
+	}
+	m, err := legacy.Load(from)
+	if errors.Is(err, manifest.ErrMismatch) {
+		// The legacy manifest tracks another collection; from has none.
+		return model, dim, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if len(m.Files) == 0 {
+		return model, dim, nil
+	}
+	for _, entry := range m.Files {
+		model, dim = entry.EmbeddingModel, entry.Dim
+		break
+	}
+
+	target, err := manifest.NewRepository(ManifestPath(s.stores.StateDir, to))
+	if err != nil {
+		return "", 0, err
+	}
+	m.Collection = to
+	if err := target.Save(m); err != nil {
+		return "", 0, err
+	}
+	return model, dim, nil
+}
+
+// sampleQueries takes evenly spaced chunks of collection as queries.
+func (s *Service) sampleQueries(ctx context.Context, collection string, ids []int64) ([]string, error) {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	picked := ids
+	if len(ids) > s.cfg.SampleSize {
+		picked = make([]int64, 0, s.cfg.SampleSize)
+		for i := range s.cfg.SampleSize {
+			picked = append(picked, ids[i*len(ids)/s.cfg.SampleSize])
+		}
+	}
+
+	items, err := s.vectors.Get(ctx, collection, picked)
+	if err != nil {
+		return nil, err
+	}
+	queries := make([]string, 0, len(items))
+	for _, item := range items {
+		text := []rune(item.Payload)
+		if len(text) > maxQueryRunes {
+			text = text[:maxQueryRunes]
+		}
+		if len(text) > 0 {
+			queries = append(queries, string(text))
+		}
+	}
+	return queries, nil
+}
+
+func (s *Service) search(ctx context.Context, target Target, queries []string) ([][]milvusrepo.SearchHit, error) {
+	vectors, err := target.Embedder.CreateEmbeddings(ctx, queries...)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(queries) {
+		return nil, fmt.Errorf("got %d embeddings for %d queries", len(vectors), len(queries))
+	}
+	return s.vectors.SearchBatch(ctx, target.Collection, vectors, milvusrepo.SearchRequest{
+		TopK:         s.cfg.TopK,
+		OutputFields: []milvusrepo.Field{milvusrepo.FieldDataSource},
+	})
+}
+
+// hitSources returns the distinct documents of hits.
+func hitSources(hits []milvusrepo.SearchHit) map[string]struct{} {
+	sources := make(map[string]struct{}, len(hits))
+	for _, hit := range hits {
+		sources[hit.DataSource] = struct{}{}
+	}
+	return sources
+}
+
+// retiredName is where Activate moves the plain collection named alias
+// while it puts the alias in its place.
+func retiredName(alias string) string {
+	return alias + "_retired"
+}
+
+// storeNamespaces are the text store namespaces of a collection.
+func storeNamespaces(collection string) []string {
+	return []string{
+		collection,
+		milvusrepo.QuestionsCollection(collection),
+		textstore.SectionsNamespace(collection),
+	}
+}
@@ -0,0 +1,271 @@
+package reindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"rag-test/internal/repository/memory"
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/repository/textstore"
+)
+
+const testAlias = "kb"
+
+// aliasRepository puts aliases on the collections of a memory repository
+// the way Milvus does.
+type aliasRepository struct {
+	*memory.Repository
+
+	aliases  map[string]string
+	failSwap error
+}
+
+func newAliasRepository(t *testing.T) *aliasRepository {
+	t.Helper()
+	repo, err := memory.NewRepository(memory.Config{})
+	if err != nil {
+		t.Fatalf("NewRepository: %v", err)
+	}
+	return &aliasRepository{Repository: repo, aliases: map[string]string{}}
+}
+
+func (r *aliasRepository) resolve(name string) string {
+	if target, ok := r.aliases[name]; ok {
+		return target
+	}
+	return name
+}
+
+func (r *aliasRepository) ResolveAlias(ctx context.Context, name string) (string, error) {
+	if target, ok := r.aliases[name]; ok {
+		return target, nil
+	}
+	names, err := r.ListCollections(ctx)
+	if err != nil || !slices.Contains(names, name) {
+		return "", err
+	}
+	return name, nil
+}
+
+func (r *aliasRepository) SwapAlias(ctx context.Context, alias, collection string) error {
+	if r.failSwap != nil {
+		return r.failSwap
+	}
+	current, err := r.ResolveAlias(ctx, alias)
+	if err != nil {
+		return err
+	}
+	if current == alias {
+		return fmt.Errorf("%s is a collection, not an alias", alias)
+	}
+	r.aliases[alias] = collection
+	return nil
+}
+
+func (r *aliasRepository) RenameCollection(ctx context.Context, from, to string) error {
+	if err := milvusrepo.CopyCollection(ctx, r.Repository, from, to); err != nil {
+		return err
+	}
+	return r.Repository.DropCollection(ctx, from)
+}
+
+func (r *aliasRepository) Count(ctx context.Context, collection string) (int64, error) {
+	info, err := r.DescribeCollection(ctx, r.resolve(collection))
+	return info.Count, err
+}
+
+func (r *aliasRepository) Get(ctx context.Context, collection string, ids []int64) ([]milvusrepo.VectorItem, error) {
+	return r.Repository.Get(ctx, r.resolve(collection), ids)
+}
+
+func (r *aliasRepository) SearchBatch(ctx context.Context, collection string, vectors [][]float32, req milvusrepo.SearchRequest) ([][]milvusrepo.SearchHit, error) {
+	return r.Repository.SearchBatch(ctx, r.resolve(collection), vectors, req)
+}
+
+// embedder maps texts about chairs and everything else to two orthogonal
+// vectors.
+type embedder struct{}
+
+func (embedder) CreateEmbeddings(_ context.Context, data ...string) ([][]float32, error) {
+	vectors := make([][]float32, len(data))
+	for i, text := range data {
+		vectors[i] = []float32{0, 1}
+		if text == "chair" {
+			vectors[i] = []float32{1, 0}
+		}
+	}
+	return vectors, nil
+}
+
+func newService(t *testing.T, repo *aliasRepository) *Service {
+	t.Helper()
+	history, err := NewHistoryStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts, err := textstore.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := Stores{Texts: texts, LexicalDir: t.TempDir(), StateDir: t.TempDir()}
+	return NewService(repo, history, stores, Config{})
+}
+
+func fill(t *testing.T, repo *aliasRepository, collection string, items ...milvusrepo.VectorItem) {
+	t.Helper()
+	ctx := context.Background()
+	if err := repo.EnsureCollection(ctx, collection, milvusrepo.CollectionConfig{Dim: 2, Metric: milvusrepo.MetricCosine}); err != nil {
+		t.Fatalf("EnsureCollection: %v", err)
+	}
+	if err := repo.Upsert(ctx, collection, items); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+}
+
+func chunk(id int64, text, source string) milvusrepo.VectorItem {
+	vectors, _ := embedder{}.CreateEmbeddings(context.Background(), text)
+	return milvusrepo.VectorItem{ID: id, Embedding: vectors[0], Payload: text, DataSource: source}
+}
+
+func resolve(t *testing.T, s *Service, alias string) string {
+	t.Helper()
+	current, err := s.Resolve(context.Background(), alias)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	return current
+}
+
+func TestAdoptActivateRollback(t *testing.T) {
+	ctx := context.Background()
+	repo := newAliasRepository(t)
+	s := newService(t, repo)
+	fill(t, repo, testAlias, chunk(1, "chair", "chairs.md"), chunk(2, "table", "tables.md"))
+
+	legacy, err := s.Adopt(ctx, testAlias)
+	if err != nil {
+		t.Fatalf("Adopt: %v", err)
+	}
+	if legacy.Collection != "kb_v0" {
+		t.Fatalf("Adopt made %s, want kb_v0", legacy.Collection)
+	}
+	if got := resolve(t, s, testAlias); got != testAlias {
+		t.Fatalf("plain collection resolves to %q before Activate", got)
+	}
+	if _, ok, err := s.Active(ctx, testAlias); err != nil || ok {
+		t.Fatalf("Active before Activate = %v, %v; want no version", ok, err)
+	}
+
+	if err := s.Activate(ctx, testAlias, legacy); err != nil {
+		t.Fatalf("Activate: %v", err)
+	}
+	if got := resolve(t, s, testAlias); got != "kb_v0" {
+		t.Fatalf("alias resolves to %q, want kb_v0", got)
+	}
+	if got := resolve(t, s, retiredName(testAlias)); got != "" {
+		t.Errorf("the plain collection was kept as %s", got)
+	}
+	if n, err := repo.Count(ctx, testAlias); err != nil || n != 2 {
+		t.Errorf("alias counts %d, %v; want 2", n, err)
+	}
+
+	next := Version{Collection: VersionName(testAlias, "1")}
+	fill(t, repo, next.Collection, chunk(1, "chair", "chairs.md"), chunk(2, "table", "tables.md"))
+	if err := s.Activate(ctx, testAlias, next); err != nil {
+		t.Fatalf("Activate: %v", err)
+	}
+	if v, ok, err := s.Active(ctx, testAlias); err != nil || !ok || v.Collection != next.Collection {
+		t.Fatalf("Active = %v, %v, %v; want %s", v.Collection, ok, err, next.Collection)
+	}
+	if err := s.Drop(ctx, testAlias, next.Collection); err == nil {
+		t.Error("Drop removed the active version")
+	}
+	if alias, ok, err := s.Owner(next.Collection); err != nil || !ok || alias != testAlias {
+		t.Errorf("Owner(%s) = %q, %v, %v; want %s", next.Collection, alias, ok, err, testAlias)
+	}
+	if _, ok, err := s.Owner("kb_vectors"); err != nil || ok {
+		t.Errorf("Owner(kb_vectors) = %v, %v; want no alias", ok, err)
+	}
+
+	prev, err := s.Rollback(ctx, testAlias)
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if prev.Collection != "kb_v0" || resolve(t, s, testAlias) != "kb_v0" {
+		t.Fatalf("Rollback returned %s, alias resolves to %s; want kb_v0", prev.Collection, resolve(t, s, testAlias))
+	}
+	if err := s.Drop(ctx, testAlias, next.Collection); err != nil {
+		t.Fatalf("Drop: %v", err)
+	}
+	if got := resolve(t, s, next.Collection); got != "" {
+		t.Errorf("Drop kept %s", got)
+	}
+	if _, err := s.Rollback(ctx, testAlias); err == nil {
+		t.Error("Rollback went past the first version")
+	}
+}
+
+func TestActivateKeepsPlainCollection(t *testing.T) {
+	ctx := context.Background()
+	repo := newAliasRepository(t)
+	s := newService(t, repo)
+	fill(t, repo, testAlias, chunk(1, "chair", "chairs.md"))
+
+	legacy, err := s.Adopt(ctx, testAlias)
+	if err != nil {
+		t.Fatalf("Adopt: %v", err)
+	}
+	repo.failSwap = errors.New("alias refused")
+	if err := s.Activate(ctx, testAlias, legacy); !errors.Is(err, repo.failSwap) {
+		t.Fatalf("Activate = %v, want %v", err, repo.failSwap)
+	}
+	if got := resolve(t, s, testAlias); got != testAlias {
+		t.Fatalf("%s resolves to %q after a failed Activate, want the plain collection", testAlias, got)
+	}
+	if n, err := repo.Count(ctx, testAlias); err != nil || n != 1 {
+		t.Errorf("plain collection counts %d, %v; want 1", n, err)
+	}
+
+	repo.failSwap = nil
+	if err := s.Activate(ctx, testAlias, legacy); err != nil {
+		t.Fatalf("Activate: %v", err)
+	}
+	if got := resolve(t, s, testAlias); got != legacy.Collection {
+		t.Errorf("alias resolves to %q, want %s", got, legacy.Collection)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+	repo := newAliasRepository(t)
+	s := newService(t, repo)
+	fill(t, repo, "kb_v0", chunk(1, "chair", "chairs.md"), chunk(2, "table", "tables.md"))
+	old := Target{Collection: "kb_v0", Embedder: embedder{}}
+
+	fill(t, repo, "kb_v1", chunk(11, "chair", "chairs.md"), chunk(12, "table", "tables.md"))
+	v, err := s.Validate(ctx, old, Target{Collection: "kb_v1", Embedder: embedder{}}, []int64{11, 12})
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if !v.OK() || v.Queries != 2 || v.Overlap != 1 {
+		t.Errorf("Validate = %+v, want a pass with full overlap", v)
+	}
+
+	// The chairs moved to another document and the tables are gone.
+	fill(t, repo, "kb_v2", chunk(21, "chair", "furniture.md"))
+	v, err = s.Validate(ctx, old, Target{Collection: "kb_v2", Embedder: embedder{}}, []int64{21})
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if len(v.Problems) != 2 {
+		t.Errorf("Validate found %q, want the count drop and the overlap", v.Problems)
+	}
+
+	v, err = s.Validate(ctx, Target{}, Target{Collection: "kb_v2", Embedder: embedder{}}, nil)
+	if err != nil || !v.OK() {
+		t.Errorf("Validate of a first version = %+v, %v; want a pass", v, err)
+	}
+}
@@ -0,0 +1,272 @@
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	milvusrepo "rag-test/internal/repository/milvus"
+)
+
+// Format is the encoding of the record files. JSONL is readable with any
+// tool; binary keeps the vectors as raw float32 and is about a third of the
+// size.
+type Format string
+
+const (
+	FormatJSONL  Format = "jsonl"
+	FormatBinary Format = "binary"
+)
+
+// binaryMagic starts every binary record file.
+var binaryMagic = []byte("RAGSNAP\x01")
+
+const (
+	maxBinaryDim   = 1 << 16
+	maxBinaryAttrs = 1 << 26
+)
+
+func (f Format) validate() error {
+	switch f {
+	case FormatJSONL, FormatBinary:
+		return nil
+	default:
+		return fmt.Errorf("unknown snapshot format %q", f)
+	}
+}
+
+func (f Format) extension() string {
+	if f == FormatBinary {
+		return ".bin"
+	}
+	return ".jsonl"
+}
+
+// record is one stored item in a JSONL file; the binary format keeps the
+// same fields, with the vector outside the JSON part.
+type record struct {
+	ID         int64                    `json:"id"`
+	Embedding  []float32                `json:"embedding,omitempty"`
+	Payload    string                   `json:"payload"`
+	DataSource string                   `json:"data_source"`
+	Metadata   milvusrepo.ChunkMetadata `json:"metadata"`
+}
+
+type section struct {
+	ID   int64  `json:"id"`
+	Text string `json:"text"`
+}
+
+// fileWriter writes one snapshot file and hashes it on the way.
+type fileWriter struct {
+	file  *os.File
+	buf   *bufio.Writer
+	hash  hash.Hash
+	count int64
+}
+
+func createFile(path string) (*fileWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	return &fileWriter{file: file, buf: bufio.NewWriter(io.MultiWriter(file, h)), hash: h}, nil
+}
+
+// close syncs the file and describes it for the manifest.
+func (w *fileWriter) close(name, kind string) (File, error) {
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return File{}, err
+	}
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return File{}, err
+	}
+	info, err := w.file.Stat()
+	if err != nil {
+		w.file.Close()
+		return File{}, err
+	}
+	if err := w.file.Close(); err != nil {
+		return File{}, err
+	}
+
+	return File{
+		Name:   name,
+		Kind:   kind,
+		Count:  w.count,
+		Bytes:  info.Size(),
+		SHA256: hex.EncodeToString(w.hash.Sum(nil)),
+	}, nil
+}
+
+type recordWriter struct {
+	*fileWriter
+	format Format
+	enc    *json.Encoder
+}
+
+func newRecordWriter(path string, format Format) (*recordWriter, error) {
+	w, err := createFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rw := &recordWriter{fileWriter: w, format: format, enc: json.NewEncoder(w.buf)}
+	if format == FormatBinary {
+		if _, err := w.buf.Write(binaryMagic); err != nil {
+			w.file.Close()
+			return nil, err
+		}
+	}
+	return rw, nil
+}
+
+func (w *recordWriter) write(item milvusrepo.VectorItem) error {
+	w.count++
+	if w.format == FormatJSONL {
+		return w.enc.Encode(record{
+			ID:         item.ID,
+			Embedding:  item.Embedding,
+			Payload:    item.Payload,
+			DataSource: item.DataSource,
+			Metadata:   item.Metadata,
+		})
+	}
+
+	attrs, err := json.Marshal(record{Payload: item.Payload, DataSource: item.DataSource, Metadata: item.Metadata})
+	if err != nil {
+		return err
+	}
+	var header [12]byte
+	binary.LittleEndian.PutUint64(header[0:8], uint64(item.ID))
+	binary.LittleEndian.PutUint32(header[8:12], uint32(len(item.Embedding)))
+	if _, err := w.buf.Write(header[:]); err != nil {
+		return err
+	}
+	if err := binary.Write(w.buf, binary.LittleEndian, item.Embedding); err != nil {
+		return err
+	}
+	if err := binary.Write(w.buf, binary.LittleEndian, uint32(len(attrs))); err != nil {
+		return err
+	}
+	_, err = w.buf.Write(attrs)
+	return err
+}
+
+type recordReader struct {
+	file   *os.File
+	buf    *bufio.Reader
+	format Format
+	dec    *json.Decoder
+}
+
+func openRecordReader(path string, format Format) (*recordReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &recordReader{file: file, buf: bufio.NewReader(file), format: format}
+	if format == FormatJSONL {
+		r.dec = json.NewDecoder(r.buf)
+		return r, nil
+	}
+
+	magic := make([]byte, len(binaryMagic))
+	if _, err := io.ReadFull(r.buf, magic); err != nil || !bytes.Equal(magic, binaryMagic) {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a binary snapshot file", path)
+	}
+	return r, nil
+}
+
+// read returns io.EOF after the last record.
+func (r *recordReader) read() (milvusrepo.VectorItem, error) {
+	var rec record
+	if r.format == FormatJSONL {
+		if err := r.dec.Decode(&rec); err != nil {
+			return milvusrepo.VectorItem{}, err
+		}
+		return rec.item(), nil
+	}
+
+	var header [12]byte
+	if _, err := io.ReadFull(r.buf, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return milvusrepo.VectorItem{}, fmt.Errorf("truncated record: %w", err)
+		}
+		return milvusrepo.VectorItem{}, err
+	}
+	dim := binary.LittleEndian.Uint32(header[8:12])
+	if dim > maxBinaryDim {
+		return milvusrepo.VectorItem{}, fmt.Errorf("record has dim %d", dim)
+	}
+	embedding := make([]float32, dim)
+	if err := binary.Read(r.buf, binary.LittleEndian, embedding); err != nil {
+		return milvusrepo.VectorItem{}, fmt.Errorf("truncated record: %w", err)
+	}
+	var size uint32
+	if err := binary.Read(r.buf, binary.LittleEndian, &size); err != nil {
+		return milvusrepo.VectorItem{}, fmt.Errorf("truncated record: %w", err)
+	}
+	if size > maxBinaryAttrs {
+		return milvusrepo.VectorItem{}, fmt.Errorf("record has %d bytes of attributes", size)
+	}
+	attrs := make([]byte, size)
+	if _, err := io.ReadFull(r.buf, attrs); err != nil {
+		return milvusrepo.VectorItem{}, fmt.Errorf("truncated record: %w", err)
+	}
+	if err := json.Unmarshal(attrs, &rec); err != nil {
+		return milvusrepo.VectorItem{}, err
+	}
+
+	rec.ID = int64(binary.LittleEndian.Uint64(header[0:8]))
+	rec.Embedding = embedding
+	return rec.item(), nil
+}
+
+func (r *recordReader) close() error {
+	return r.file.Close()
+}
+
+func (rec record) item() milvusrepo.VectorItem {
+	return milvusrepo.VectorItem{
+		ID:         rec.ID,
+		Embedding:  rec.Embedding,
+		Payload:    rec.Payload,
+		DataSource: rec.DataSource,
+		Metadata:   rec.Metadata,
+	}
+}
+
+// verify checks a file against its manifest entry before anything is
+// loaded from it.
+func verify(path string, want File) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return err
+	}
+	if size != want.Bytes {
+		return fmt.Errorf("%w: %s has %d bytes, manifest says %d", ErrChecksum, want.Name, size, want.Bytes)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != want.SHA256 {
+		return fmt.Errorf("%w: %s has sha256 %s, manifest says %s", ErrChecksum, want.Name, sum, want.SHA256)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/storage/atomicfile"
+)
+
+const (
+	manifestVersion  = 1
+	manifestFileName = "snapshot.json"
+)
+
+// Kinds of snapshot files.
+const (
+	KindChunks         = "chunks"
+	KindQuestions      = "questions"
+	KindSections       = "sections"
+	KindIngestManifest = "ingest_manifest"
+)
+
+var ErrChecksum = errors.New("snapshot file does not match its checksum")
+
+// Manifest describes a snapshot directory. It is written last, so a
+// directory without one is an export that did not finish. EmbeddingModel,
+// Dim and Metric tell which model queries against the imported collection
+// have to be embedded with.
+type Manifest struct {
+	Version        int               `json:"version"`
+	Format         Format            `json:"format"`
+	CreatedAt      time.Time         `json:"created_at"`
+	Collection     string            `json:"collection"`
+	EmbeddingModel string            `json:"embedding_model,omitempty"`
+	Dim            int               `json:"dim"`
+	Metric         milvusrepo.Metric `json:"metric"`
+	Files          []File            `json:"files"`
+}
+
+// File is one file of a snapshot with its checksum. Record files carry the
+// configuration their collection is recreated with.
+type File struct {
+	Name       string      `json:"name"`
+	Kind       string      `json:"kind"`
+	Count      int64       `json:"count"`
+	Bytes      int64       `json:"bytes"`
+	SHA256     string      `json:"sha256"`
+	Collection *Collection `json:"collection,omitempty"`
+}
+
+type Collection struct {
+	Dim             int                  `json:"dim"`
+	MaxPayloadBytes int                  `json:"max_payload_bytes"`
+	Metric          milvusrepo.Metric    `json:"metric"`
+	Index           milvusrepo.IndexType `json:"index"`
+	NList           int                  `json:"nlist,omitempty"`
+	M               int                  `json:"m,omitempty"`
+	EfConstruction  int                  `json:"ef_construction,omitempty"`
+}
+
+func collectionOf(cfg milvusrepo.CollectionConfig) *Collection {
+	return &Collection{
+		Dim:             cfg.Dim,
+		MaxPayloadBytes: cfg.MaxPayloadBytes,
+		Metric:          cfg.Metric,
+		Index:           cfg.Index,
+		NList:           cfg.NList,
+		M:               cfg.M,
+		EfConstruction:  cfg.EfConstruction,
+	}
+}
+
+func (c *Collection) config() milvusrepo.CollectionConfig {
+	return milvusrepo.CollectionConfig{
+		Dim:             c.Dim,
+		MaxPayloadBytes: c.MaxPayloadBytes,
+		Metric:          c.Metric,
+		Index:           c.Index,
+		NList:           c.NList,
+		M:               c.M,
+		EfConstruction:  c.EfConstruction,
+	}
+}
+
+// ReadManifest loads the manifest of the snapshot in dir.
+func ReadManifest(dir string) (*Manifest, error) {
+	path := filepath.Join(dir, manifestFileName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s has no %s, the export did not finish", dir, manifestFileName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("decode snapshot manifest %s: %w", path, err)
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("snapshot manifest %s has version %d, want %d", path, m.Version, manifestVersion)
+	}
+	if err := m.Format.validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func writeManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.Write(filepath.Join(dir, manifestFileName), data)
+}
@@ -0,0 +1,522 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"rag-test/internal/repository/manifest"
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/repository/textstore"
+	"rag-test/internal/service/reindex"
+)
+
+const (
+	exportBatchSize = 1000
+	importBatchSize = 500
+)
+
+// ExportOptions selects the collection and the record format. An empty
+// EmbeddingModel is taken from the ingestion manifest.
+type ExportOptions struct {
+	Collection     string
+	Format         Format
+	EmbeddingModel string
+}
+
+// ImportOptions names the collection the snapshot is loaded into, by
+// default the one it was exported from. An existing collection and its
+// questions collection are only replaced with Replace.
+type ImportOptions struct {
+	Collection string
+	Replace    bool
+}
+
+// Service moves a knowledge base between environments without converting
+// and embedding the documents again: the chunks and questions with their
+// vectors, the parent sections and the ingestion manifest, so the next
+// ingestion run sees every document as up to date.
+type Service struct {
+	vectorRepo   milvusrepo.VectorRepository
+	sectionStore *textstore.Store
+	manifestRepo *manifest.Repository
+	// versions puts a replacing import in place behind the alias of the
+	// target; nil for the stores without aliases, where the imported
+	// collection is copied over the target instead.
+	versions *reindex.Service
+}
+
+func NewService(vectorRepo milvusrepo.VectorRepository, sectionStore *textstore.Store, manifestRepo *manifest.Repository, versions *reindex.Service) *Service {
+	return &Service{
+		vectorRepo:   vectorRepo,
+		sectionStore: sectionStore,
+		manifestRepo: manifestRepo,
+		versions:     versions,
+	}
+}
+
+// Export streams the collection into dir, which must not hold a snapshot
+// yet. The vector repository should return full payloads, i.e. be wrapped
+// in the text store.
+func (s *Service) Export(ctx context.Context, dir string, opts ExportOptions) (*Manifest, error) {
+	if err := opts.Format.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, manifestFileName)); err == nil {
+		return nil, fmt.Errorf("%s already holds a snapshot", dir)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	info, err := s.vectorRepo.DescribeCollection(ctx, opts.Collection)
+	if err != nil {
+		return nil, err
+	}
+	ingested, err := s.manifestRepo.Load(opts.Collection)
+	if errors.Is(err, manifest.ErrMismatch) {
+		// The manifest tracks another collection, e.g. the active version
+		// while an older one is exported; this one goes without its
+		// sections and ingestion state.
+		slog.Warn("manifest belongs to another collection, exporting without it", slog.String("collection", opts.Collection), slog.String("manifest", s.manifestRepo.Path()))
+		ingested, err = manifest.New(opts.Collection), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Version:        manifestVersion,
+		Format:         opts.Format,
+		CreatedAt:      time.Now().UTC(),
+		Collection:     opts.Collection,
+		EmbeddingModel: opts.EmbeddingModel,
+		Dim:            info.Config.Dim,
+		Metric:         info.Config.Metric,
+	}
+	for _, entry := range ingested.Files {
+		if m.EmbeddingModel == "" {
+			m.EmbeddingModel = entry.EmbeddingModel
+		}
+		break
+	}
+
+	file, err := s.exportRecords(ctx, dir, opts.Collection, KindChunks, opts.Format, info.Config)
+	if err != nil {
+		return nil, err
+	}
+	m.Files = append(m.Files, file)
+
+	questions := milvusrepo.QuestionsCollection(opts.Collection)
+	names, err := s.vectorRepo.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(names, questions) {
+		questionsInfo, err := s.vectorRepo.DescribeCollection(ctx, questions)
+		if err != nil {
+			return nil, err
+		}
+		file, err := s.exportRecords(ctx, dir, questions, KindQuestions, opts.Format, questionsInfo.Config)
+		if err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, file)
+	}
+
+	if len(ingested.Files) > 0 {
+		file, err := s.exportSections(dir, opts.Collection, ingested)
+		if err != nil {
+			return nil, err
+		}
+		if file.Count > 0 {
+			m.Files = append(m.Files, file)
+		}
+
+		file, err = exportIngestManifest(dir, ingested)
+		if err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, file)
+	}
+
+	if err := writeManifest(dir, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (s *Service) exportRecords(ctx context.Context, dir, collection, kind string, format Format, cfg milvusrepo.CollectionConfig) (File, error) {
+	name := kind + format.extension()
+	w, err := newRecordWriter(filepath.Join(dir, name), format)
+	if err != nil {
+		return File{}, err
+	}
+
+	req := milvusrepo.ScanRequest{BatchSize: exportBatchSize, Embeddings: true}
+	err = s.vectorRepo.Scan(ctx, collection, req, func(items []milvusrepo.VectorItem) error {
+		for _, item := range items {
+			if err := w.write(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		w.file.Close()
+		return File{}, err
+	}
+
+	file, err := w.close(name, kind)
+	if err != nil {
+		return File{}, err
+	}
+	file.Collection = collectionOf(cfg)
+	slog.Info("collection exported", slog.String("collection", collection), slog.Int64("records", file.Count))
+	return file, nil
+}
+
+// exportSections writes the parent sections the manifest lists; they are
+// kept outside the vector store.
+func (s *Service) exportSections(dir, collection string, ingested *manifest.Manifest) (File, error) {
+	ids := make([]int64, 0)
+	for _, entry := range ingested.Files {
+		ids = append(ids, entry.SectionIDs...)
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	name := KindSections + ".jsonl"
+	w, err := createFile(filepath.Join(dir, name))
+	if err != nil {
+		return File{}, err
+	}
+	enc := json.NewEncoder(w.buf)
+	namespace := textstore.SectionsNamespace(collection)
+	for batch := range slices.Chunk(ids, exportBatchSize) {
+		texts, err := s.sectionStore.Get(namespace, batch)
+		if err != nil {
+			w.file.Close()
+			return File{}, err
+		}
+		for _, id := range batch {
+			text, ok := texts[id]
+			if !ok {
+				slog.Warn("section is missing from the text store", slog.Int64("id", id))
+				continue
+			}
+			if err := enc.Encode(section{ID: id, Text: text}); err != nil {
+				w.file.Close()
+				return File{}, err
+			}
+			w.count++
+		}
+	}
+
+	file, err := w.close(name, KindSections)
+	if err != nil {
+		return File{}, err
+	}
+	if file.Count == 0 {
+		return file, os.Remove(filepath.Join(dir, name))
+	}
+	return file, nil
+}
+
+func exportIngestManifest(dir string, ingested *manifest.Manifest) (File, error) {
+	name := KindIngestManifest + ".json"
+	w, err := createFile(filepath.Join(dir, name))
+	if err != nil {
+		return File{}, err
+	}
+	enc := json.NewEncoder(w.buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(ingested); err != nil {
+		w.file.Close()
+		return File{}, err
+	}
+	w.count = int64(len(ingested.Files))
+	return w.close(name, KindIngestManifest)
+}
+
+// Import verifies every file of the snapshot in dir against its checksum,
+// then creates the collections and bulk-loads them. Nothing is changed when
+// a file does not match.
+//
+// A replacing import loads into a staging collection first and only puts it
+// in place of the target once every record is in, so a failed import leaves
+// the target as it was. With aliases the staging collection becomes the
+// version behind the target's alias and the replaced one is dropped.
+func (s *Service) Import(ctx context.Context, dir string, opts ImportOptions) (*Manifest, error) {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range m.Files {
+		if err := verify(filepath.Join(dir, file.Name), file); err != nil {
+			return nil, err
+		}
+	}
+
+	target := opts.Collection
+	if target == "" {
+		target = m.Collection
+	}
+	exists, err := s.exists(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		ingested, err := s.load(ctx, dir, m, target)
+		if err != nil {
+			return nil, err
+		}
+		return m, saveIngestManifest(s.manifestRepo, target, ingested)
+	}
+	if !opts.Replace {
+		return nil, fmt.Errorf("collection %s already exists, import with replace to overwrite it", target)
+	}
+
+	staging := target + "_import"
+	if s.versions != nil {
+		staging = reindex.VersionName(target, time.Now().UTC().Format("20060102150405"))
+	}
+	// Left over by an import that failed before.
+	if err := s.dropCollections(ctx, staging); err != nil {
+		return nil, err
+	}
+	ingested, err := s.load(ctx, dir, m, staging)
+	if err != nil {
+		if dropErr := s.dropCollections(ctx, staging); dropErr != nil {
+			slog.Warn("failed to drop staging collection", slog.String("collection", staging), slog.String("err", dropErr.Error()))
+		}
+		return nil, fmt.Errorf("load into %s, %s is unchanged: %w", staging, target, err)
+	}
+
+	if s.versions != nil {
+		return m, s.activate(ctx, target, staging, m, ingested)
+	}
+	return m, s.copyOver(ctx, target, staging, ingested)
+}
+
+// exists reports whether target or its questions collection is stored,
+// directly or behind an alias.
+func (s *Service) exists(ctx context.Context, target string) (bool, error) {
+	names, err := s.vectorRepo.ListCollections(ctx)
+	if err != nil {
+		return false, err
+	}
+	if slices.Contains(names, target) || slices.Contains(names, milvusrepo.QuestionsCollection(target)) {
+		return true, nil
+	}
+	if s.versions == nil {
+		return false, nil
+	}
+	current, err := s.versions.Resolve(ctx, target)
+	return current != "", err
+}
+
+// load writes the records and sections of the snapshot into collection
+// and returns its ingestion manifest, nil when it has none.
+func (s *Service) load(ctx context.Context, dir string, m *Manifest, collection string) (*manifest.Manifest, error) {
+	if tracker, ok := s.vectorRepo.(interface{ Track(string) }); ok {
+		tracker.Track(collection)
+	}
+
+	var ingested *manifest.Manifest
+	for _, file := range m.Files {
+		path := filepath.Join(dir, file.Name)
+		switch file.Kind {
+		case KindChunks, KindQuestions:
+			name := collection
+			if file.Kind == KindQuestions {
+				name = milvusrepo.QuestionsCollection(collection)
+			}
+			if err := s.importRecords(ctx, path, name, m.Format, file); err != nil {
+				return nil, err
+			}
+		case KindSections:
+			if err := s.importSections(path, collection); err != nil {
+				return nil, err
+			}
+		case KindIngestManifest:
+			var err error
+			if ingested, err = readIngestManifest(path); err != nil {
+				return nil, err
+			}
+		default:
+			slog.Warn("unknown snapshot file skipped", slog.String("name", file.Name), slog.String("kind", file.Kind))
+		}
+	}
+	return ingested, nil
+}
+
+// activate makes staging the version behind the target alias.
+func (s *Service) activate(ctx context.Context, target, staging string, m *Manifest, ingested *manifest.Manifest) error {
+	manifestRepo, err := s.versions.ManifestRepository(staging)
+	if err != nil {
+		return err
+	}
+	if err := saveIngestManifest(manifestRepo, staging, ingested); err != nil {
+		return err
+	}
+
+	v := reindex.Version{Collection: staging, EmbeddingModel: m.EmbeddingModel, Dim: m.Dim}
+	if err := s.versions.Replace(ctx, target, v); err != nil {
+		return err
+	}
+	slog.Info("imported version activated", slog.String("alias", target), slog.String("collection", staging))
+	return nil
+}
+
+// copyOver replaces target with a copy of staging for the stores without
+// aliases, which serve a single process: target is only missing between
+// its drop and the copy.
+func (s *Service) copyOver(ctx context.Context, target, staging string, ingested *manifest.Manifest) error {
+	if err := s.dropCollections(ctx, target); err != nil {
+		return err
+	}
+
+	names, err := s.vectorRepo.ListCollections(ctx)
+	if err != nil {
+		return err
+	}
+	for _, pair := range [][2]string{{staging, target}, {milvusrepo.QuestionsCollection(staging), milvusrepo.QuestionsCollection(target)}} {
+		if !slices.Contains(names, pair[0]) {
+			continue
+		}
+		if err := milvusrepo.CopyCollection(ctx, s.vectorRepo, pair[0], pair[1]); err != nil {
+			return fmt.Errorf("copy %s to %s: %w", pair[0], pair[1], err)
+		}
+	}
+	if err := s.sectionStore.Copy(textstore.SectionsNamespace(staging), textstore.SectionsNamespace(target)); err != nil {
+		return err
+	}
+
+	if err := s.dropCollections(ctx, staging); err != nil {
+		return err
+	}
+	return saveIngestManifest(s.manifestRepo, target, ingested)
+}
+
+// dropCollections drops collection and its questions collection where they
+// exist, with their local stores.
+func (s *Service) dropCollections(ctx context.Context, collection string) error {
+	names, err := s.vectorRepo.ListCollections(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{collection, milvusrepo.QuestionsCollection(collection)} {
+		if !slices.Contains(names, name) {
+			continue
+		}
+		if err := s.vectorRepo.DropCollection(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) importRecords(ctx context.Context, path, collection string, format Format, file File) error {
+	if file.Collection == nil {
+		return fmt.Errorf("snapshot file %s has no collection configuration", file.Name)
+	}
+	cfg := file.Collection.config()
+	if err := s.vectorRepo.EnsureCollection(ctx, collection, cfg); err != nil {
+		return err
+	}
+
+	r, err := openRecordReader(path, format)
+	if err != nil {
+		return err
+	}
+	defer r.close()
+
+	var (
+		count int64
+		batch = make([]milvusrepo.VectorItem, 0, importBatchSize)
+	)
+	for {
+		item, err := r.read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", file.Name, err)
+		}
+		if len(item.Embedding) != cfg.Dim {
+			return fmt.Errorf("record %d of %s has dim %d, want %d", item.ID, file.Name, len(item.Embedding), cfg.Dim)
+		}
+		batch = append(batch, item)
+		count++
+		if len(batch) == importBatchSize {
+			if err := s.vectorRepo.Upsert(ctx, collection, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := s.vectorRepo.Upsert(ctx, collection, batch); err != nil {
+		return err
+	}
+	if count != file.Count {
+		return fmt.Errorf("%s holds %d records, manifest says %d", file.Name, count, file.Count)
+	}
+
+	slog.Info("collection imported", slog.String("collection", collection), slog.Int64("records", count))
+	return nil
+}
+
+func (s *Service) importSections(path, collection string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	namespace := textstore.SectionsNamespace(collection)
+	dec := json.NewDecoder(f)
+	for {
+		var sec section
+		err := dec.Decode(&sec)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read sections: %w", err)
+		}
+		if err := s.sectionStore.Put(namespace, sec.ID, sec.Text); err != nil {
+			return err
+		}
+	}
+}
+
+func readIngestManifest(path string) (*manifest.Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ingested manifest.Manifest
+	if err := json.Unmarshal(data, &ingested); err != nil {
+		return nil, fmt.Errorf("decode ingestion manifest: %w", err)
+	}
+	return &ingested, nil
+}
+
+// saveIngestManifest stores the ingestion manifest of collection. Without
+// one in the snapshot an old manifest would claim documents the imported
+// collection does not have, so it is removed.
+func saveIngestManifest(repo *manifest.Repository, collection string, ingested *manifest.Manifest) error {
+	if ingested == nil {
+		return repo.Remove(collection)
+	}
+	ingested.Collection = collection
+	return repo.Save(ingested)
+}
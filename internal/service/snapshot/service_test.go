@@ -0,0 +1,295 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"rag-test/internal/repository/manifest"
+	"rag-test/internal/repository/memory"
+	milvusrepo "rag-test/internal/repository/milvus"
+	"rag-test/internal/repository/textstore"
+)
+
+const testCollection = "kb"
+
+// environment is one deployment: a vector store wrapped in the text store,
+// the section store and the ingestion manifest.
+type environment struct {
+	vectors   milvusrepo.VectorRepository
+	sections  *textstore.Store
+	manifests *manifest.Repository
+	service   *Service
+}
+
+func newEnvironment(t *testing.T) *environment {
+	t.Helper()
+	dir := t.TempDir()
+	inner, err := memory.NewRepository(memory.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts, err := textstore.NewStore(filepath.Join(dir, "texts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := manifest.NewRepository(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &environment{
+		vectors:   textstore.NewRepository(inner, texts),
+		sections:  texts,
+		manifests: manifests,
+	}
+	env.service = NewService(env.vectors, texts, manifests, nil)
+	return env
+}
+
+// seed fills the environment with chunks of the given payload, a question
+// and a parent section, as an ingestion run would.
+func (env *environment) seed(t *testing.T, payload string) []milvusrepo.VectorItem {
+	t.Helper()
+	ctx := context.Background()
+	cfg := milvusrepo.CollectionConfig{Dim: 3, Metric: milvusrepo.MetricCosine, MaxPayloadBytes: 64}
+	for _, name := range []string{testCollection, milvusrepo.QuestionsCollection(testCollection)} {
+		if err := env.vectors.EnsureCollection(ctx, name, cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items := []milvusrepo.VectorItem{
+		{
+			ID: 1, Embedding: []float32{1, 0, 0}, Payload: payload, DataSource: "Цены.docx",
+			Metadata: milvusrepo.ChunkMetadata{DocType: "docx", Tags: []string{"price"}, UpdatedAt: 100},
+		},
+		{
+			ID: 2, Embedding: []float32{0, 0.5, -0.25}, Payload: strings.Repeat("длинный текст ", 20), DataSource: "Цены.docx",
+			Metadata: milvusrepo.ChunkMetadata{DocType: "docx", UpdatedAt: 100},
+		},
+	}
+	if err := env.vectors.Upsert(ctx, testCollection, items); err != nil {
+		t.Fatal(err)
+	}
+	question := milvusrepo.VectorItem{ID: 10, Embedding: []float32{0, 1, 0}, Payload: "Сколько стоит стул?", DataSource: "Цены.docx"}
+	if err := env.vectors.Upsert(ctx, milvusrepo.QuestionsCollection(testCollection), []milvusrepo.VectorItem{question}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.sections.Put(textstore.SectionsNamespace(testCollection), 100, "# Цены\nраздел"); err != nil {
+		t.Fatal(err)
+	}
+
+	ingested := manifest.New(testCollection)
+	ingested.Files["Цены.docx"] = manifest.FileEntry{
+		Path: "Цены.docx", Hash: "abc", ChunkIDs: []int64{1, 2}, QuestionIDs: []int64{10},
+		SectionIDs: []int64{100}, EmbeddingModel: "test-embed", Dim: 3,
+	}
+	if err := env.manifests.Save(ingested); err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+func (env *environment) items(t *testing.T, collection string) []milvusrepo.VectorItem {
+	t.Helper()
+	var items []milvusrepo.VectorItem
+	req := milvusrepo.ScanRequest{Embeddings: true}
+	err := env.vectors.Scan(context.Background(), collection, req, func(batch []milvusrepo.VectorItem) error {
+		items = append(items, batch...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan %s: %v", collection, err)
+	}
+	slices.SortFunc(items, func(a, b milvusrepo.VectorItem) int { return int(a.ID - b.ID) })
+	return items
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		replace bool
+	}{
+		{name: "jsonl", format: FormatJSONL},
+		{name: "binary", format: FormatBinary},
+		{name: "jsonl over an existing collection", format: FormatJSONL, replace: true},
+		{name: "binary over an existing collection", format: FormatBinary, replace: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			source := newEnvironment(t)
+			want := source.seed(t, "Стул офисный — 4 990 ₽")
+
+			dir := filepath.Join(t.TempDir(), "snapshot")
+			m, err := source.service.Export(ctx, dir, ExportOptions{Collection: testCollection, Format: tt.format})
+			if err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			if m.EmbeddingModel != "test-embed" || m.Dim != 3 || m.Metric != milvusrepo.MetricCosine {
+				t.Errorf("manifest = %+v, want the model, dim and metric of the collection", m)
+			}
+			kinds := make([]string, 0, len(m.Files))
+			for _, file := range m.Files {
+				kinds = append(kinds, file.Kind)
+			}
+			if wantKinds := []string{KindChunks, KindQuestions, KindSections, KindIngestManifest}; !slices.Equal(kinds, wantKinds) {
+				t.Errorf("files = %v, want %v", kinds, wantKinds)
+			}
+			if _, err := source.service.Export(ctx, dir, ExportOptions{Collection: testCollection, Format: tt.format}); err == nil {
+				t.Error("Export into a directory with a snapshot succeeded")
+			}
+
+			target := newEnvironment(t)
+			if tt.replace {
+				target.seed(t, "старая цена")
+				if _, err := target.service.Import(ctx, dir, ImportOptions{}); err == nil {
+					t.Fatal("Import over an existing collection without replace succeeded")
+				}
+			}
+			if _, err := target.service.Import(ctx, dir, ImportOptions{Replace: tt.replace}); err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+
+			if got := target.items(t, testCollection); !reflect.DeepEqual(got, want) {
+				t.Errorf("imported chunks = %+v, want %+v", got, want)
+			}
+			if got := target.items(t, milvusrepo.QuestionsCollection(testCollection)); len(got) != 1 || got[0].Payload != "Сколько стоит стул?" {
+				t.Errorf("imported questions = %+v", got)
+			}
+			sections, err := target.sections.Get(textstore.SectionsNamespace(testCollection), []int64{100})
+			if err != nil || sections[100] != "# Цены\nраздел" {
+				t.Errorf("imported sections = %v, %v", sections, err)
+			}
+			ingested, err := target.manifests.Load(testCollection)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry := ingested.Files["Цены.docx"]; !slices.Equal(entry.ChunkIDs, []int64{1, 2}) || entry.EmbeddingModel != "test-embed" {
+				t.Errorf("imported manifest entry = %+v", entry)
+			}
+
+			names, err := target.vectors.ListCollections(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{testCollection, milvusrepo.QuestionsCollection(testCollection)}; !sameElements(names, want) {
+				t.Errorf("collections = %v, want %v", names, want)
+			}
+		})
+	}
+}
+
+// TestExportOtherCollection exports a collection the ingestion manifest
+// does not track, which goes without sections and manifest.
+func TestExportOtherCollection(t *testing.T) {
+	ctx := context.Background()
+	source := newEnvironment(t)
+	want := source.seed(t, "Стул офисный — 4 990 ₽")
+	if err := milvusrepo.CopyCollection(ctx, source.vectors, testCollection, "kb_v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "snapshot")
+	m, err := source.service.Export(ctx, dir, ExportOptions{Collection: "kb_v1", Format: FormatJSONL, EmbeddingModel: "test-embed"})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if len(m.Files) != 1 || m.Files[0].Kind != KindChunks || m.Collection != "kb_v1" {
+		t.Errorf("manifest = %+v, want only the chunks of kb_v1", m)
+	}
+
+	target := newEnvironment(t)
+	if _, err := target.service.Import(ctx, dir, ImportOptions{}); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if got := target.items(t, "kb_v1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("imported chunks = %+v, want %+v", got, want)
+	}
+}
+
+// TestImportChecksum corrupts an exported snapshot and checks that the
+// import is refused before anything is written.
+func TestImportChecksum(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, dir string, m *Manifest)
+	}{
+		{
+			name: "changed byte",
+			corrupt: func(t *testing.T, dir string, m *Manifest) {
+				path := filepath.Join(dir, m.Files[0].Name)
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data[len(data)/2] ^= 0x01
+				if err := os.WriteFile(path, data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "truncated file",
+			corrupt: func(t *testing.T, dir string, m *Manifest) {
+				path := filepath.Join(dir, m.Files[0].Name)
+				if err := os.Truncate(path, m.Files[0].Bytes-1); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "wrong checksum in the manifest",
+			corrupt: func(t *testing.T, dir string, m *Manifest) {
+				m.Files[len(m.Files)-1].SHA256 = strings.Repeat("0", 64)
+				if err := writeManifest(dir, m); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			source := newEnvironment(t)
+			source.seed(t, "Стул офисный — 4 990 ₽")
+			dir := filepath.Join(t.TempDir(), "snapshot")
+			m, err := source.service.Export(ctx, dir, ExportOptions{Collection: testCollection, Format: FormatJSONL})
+			if err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			tt.corrupt(t, dir, m)
+
+			target := newEnvironment(t)
+			_, err = target.service.Import(ctx, dir, ImportOptions{})
+			if !errors.Is(err, ErrChecksum) {
+				t.Fatalf("Import error = %v, want %v", err, ErrChecksum)
+			}
+			names, err := target.vectors.ListCollections(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(names) != 0 {
+				t.Errorf("collections after a refused import = %v", names)
+			}
+			if _, err := os.Stat(target.manifests.Path()); !os.IsNotExist(err) {
+				t.Errorf("ingestion manifest written by a refused import: %v", err)
+			}
+		})
+	}
+}
+
+func sameElements(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
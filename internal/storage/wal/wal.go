@@ -0,0 +1,191 @@
+// Package wal keeps an append-only log of JSON records next to a snapshot
+// or index file. The file stores write through it and fold it into their
+// snapshot from time to time; after a crash the records written since are
+// replayed on open.
+package wal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"rag-test/internal/storage/atomicfile"
+)
+
+const (
+	headerSize    = 8
+	maxRecordSize = 256 << 20
+)
+
+// Log is a log file of records, each framed by its length and CRC-32, so a
+// write torn by a crash is detected and cut off when the log is replayed.
+// It is not safe for concurrent use.
+type Log struct {
+	path string
+	file *os.File
+}
+
+// New returns the log at path. The file and its directory are created by
+// the first Append.
+func New(path string) *Log {
+	return &Log{path: path}
+}
+
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Replay decodes the intact records of l in order and calls apply with
+// each, then truncates the file after the last one. It returns the number
+// of records applied; a missing file has none.
+func Replay[T any](l *Log, apply func(T)) (int, error) {
+	f, err := os.OpenFile(l.path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var (
+		offset  int64
+		records int
+		header  = make([]byte, headerSize)
+	)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, nil
+			}
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				return records, err
+			}
+			break
+		}
+
+		size := binary.LittleEndian.Uint32(header[:4])
+		if size > maxRecordSize {
+			break
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			if !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+				return records, err
+			}
+			break
+		}
+		if crc32.ChecksumIEEE(data) != binary.LittleEndian.Uint32(header[4:]) {
+			break
+		}
+
+		var rec T
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return records, fmt.Errorf("decode record at %d of %s: %w", offset, l.path, err)
+		}
+		apply(rec)
+
+		offset += int64(headerSize + len(data))
+		records++
+	}
+
+	slog.Warn("truncating torn tail of log", slog.String("path", l.path), slog.Int64("offset", offset))
+	if err := f.Truncate(offset); err != nil {
+		return records, err
+	}
+	return records, f.Sync()
+}
+
+// Append writes rec and syncs it. A failed write is cut off again, so later
+// records do not land behind a torn one.
+func (l *Log) Append(rec any) error {
+	var buf bytes.Buffer
+	if err := encode(&buf, rec); err != nil {
+		return err
+	}
+
+	if l.file == nil {
+		if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		l.file = f
+	}
+
+	info, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(buf.Bytes()); err != nil {
+		return errors.Join(err, l.file.Truncate(info.Size()))
+	}
+	if err := l.file.Sync(); err != nil {
+		return errors.Join(err, l.file.Truncate(info.Size()))
+	}
+	return nil
+}
+
+// Rewrite replaces the log with recs through a temporary file, for stores
+// whose log is their only copy of the data.
+func (l *Log) Rewrite(recs ...any) error {
+	var buf bytes.Buffer
+	for _, rec := range recs {
+		if err := encode(&buf, rec); err != nil {
+			return err
+		}
+	}
+
+	if err := l.Close(); err != nil {
+		return err
+	}
+	return atomicfile.Write(l.path, buf.Bytes())
+}
+
+// Remove deletes the log once its records are folded into a snapshot. The
+// next Append starts a new file.
+func (l *Log) Remove() error {
+	if err := l.Close(); err != nil {
+		return err
+	}
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Log) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func encode(w io.Writer, rec any) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, headerSize)
+	binary.LittleEndian.PutUint32(header[:4], uint32(len(data)))
+	binary.LittleEndian.PutUint32(header[4:], crc32.ChecksumIEEE(data))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
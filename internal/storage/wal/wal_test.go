@@ -0,0 +1,161 @@
+package wal
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+type record struct {
+	Op  string  `json:"op"`
+	IDs []int64 `json:"ids"`
+}
+
+func replayAll(t *testing.T, l *Log) []record {
+	t.Helper()
+	var got []record
+	n, err := Replay(l, func(rec record) { got = append(got, rec) })
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if n != len(got) {
+		t.Errorf("Replay returned %d, applied %d records", n, len(got))
+	}
+	return got
+}
+
+func ops(recs []record) []string {
+	out := make([]string, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, rec.Op)
+	}
+	return out
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name string
+		// damage changes the file after the records a, b and c were
+		// appended.
+		damage func(t *testing.T, path string)
+		want   []string
+	}{
+		{name: "intact", damage: func(*testing.T, string) {}, want: []string{"a", "b", "c"}},
+		{name: "torn header", damage: func(t *testing.T, path string) { appendBytes(t, path, []byte{9, 0, 0}) }, want: []string{"a", "b", "c"}},
+		{
+			name:   "torn record",
+			damage: func(t *testing.T, path string) { appendBytes(t, path, []byte{100, 0, 0, 0, 1, 2, 3, 4, '{', '"'}) },
+			want:   []string{"a", "b", "c"},
+		},
+		{name: "last record cut short", damage: func(t *testing.T, path string) { truncateBy(t, path, 3) }, want: []string{"a", "b"}},
+		{name: "checksum mismatch", damage: func(t *testing.T, path string) { corruptLastRecord(t, path) }, want: []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state", "vectors.log")
+			l := New(path)
+			for _, op := range []string{"a", "b", "c"} {
+				if err := l.Append(record{Op: op, IDs: []int64{1, 2}}); err != nil {
+					t.Fatalf("Append: %v", err)
+				}
+			}
+			if err := l.Close(); err != nil {
+				t.Fatal(err)
+			}
+			tt.damage(t, path)
+
+			reopened := New(path)
+			if got := ops(replayAll(t, reopened)); !slices.Equal(got, tt.want) {
+				t.Fatalf("replayed %v, want %v", got, tt.want)
+			}
+
+			// New records go after the last intact one.
+			if err := reopened.Append(record{Op: "d"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := reopened.Close(); err != nil {
+				t.Fatal(err)
+			}
+			want := append(slices.Clone(tt.want), "d")
+			if got := ops(replayAll(t, New(path))); !slices.Equal(got, want) {
+				t.Errorf("replayed %v after appending, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRewriteAndRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.log")
+	l := New(path)
+	if n, err := Replay(l, func(record) {}); err != nil || n != 0 {
+		t.Fatalf("Replay of a missing log = %d, %v; want 0, nil", n, err)
+	}
+
+	for _, op := range []string{"a", "b"} {
+		if err := l.Append(record{Op: op}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Rewrite(record{Op: "x"}, record{Op: "y"}); err != nil {
+		t.Fatalf("Rewrite: %v", err)
+	}
+	if err := l.Append(record{Op: "z"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ops(replayAll(t, New(path))); !slices.Equal(got, []string{"x", "y", "z"}) {
+		t.Errorf("replayed %v after Rewrite, want [x y z]", got)
+	}
+
+	if err := l.Remove(); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("log still exists after Remove: %v", err)
+	}
+	if err := l.Remove(); err != nil {
+		t.Errorf("Remove of a missing log: %v", err)
+	}
+	if err := l.Append(record{Op: "n"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ops(replayAll(t, New(path))); !slices.Equal(got, []string{"n"}) {
+		t.Errorf("replayed %v after Remove, want [n]", got)
+	}
+}
+
+func appendBytes(t *testing.T, path string, data []byte) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func truncateBy(t *testing.T, path string, n int64) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-n); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func corruptLastRecord(t *testing.T, path string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}